@@ -0,0 +1,223 @@
+package audit
+
+// Append-only audit log of purge candidates and deletions. Entries are written as JSON lines to the configured audit
+// log file and kept in memory, so that they can be retrieved through the API.
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ActionCandidate the file would have been purged, but purging runs in dry-run mode
+	ActionCandidate = "candidate"
+	// ActionDeleted the file has been purged
+	ActionDeleted = "deleted"
+	// ActionMoved the file has been purged by moving it to the purge target
+	ActionMoved = "moved"
+	// ActionLocked the file has not been purged as it is locked, e.g. through S3 Object Lock
+	ActionLocked = "locked"
+	// ActionFailed purging the file failed
+	ActionFailed = "failed"
+)
+
+// maximum number of entries kept in memory
+const maxEntries = 1000
+
+// size of the chunks in which the audit log file is read from its end
+const readChunkSize = 64 * 1024
+
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	DryRun      bool      `json:"dry_run"`
+	Environment string    `json:"environment"`
+	Disk        string    `json:"disk"`
+	Directory   string    `json:"directory"`
+	File        string    `json:"file"`
+	Group       string    `json:"group"`
+	Name        string    `json:"name"`
+	Parent      string    `json:"parent"`
+	Size        int64     `json:"size"`
+	SortTime    time.Time `json:"sort_time"`
+	Target      string    `json:"target,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+var (
+	mutex   = &sync.Mutex{}
+	entries []Entry
+)
+
+// Log appends the entry to the audit log file, if configured, and to the in-memory log
+func Log(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	entries = append(entries, entry)
+
+	if len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+
+	path := config.GetInstance().Global().PurgeAuditLog()
+
+	if path == "" {
+		return
+	}
+
+	if err := appendTo(path, entry); err != nil {
+		log.Errorf("Failed to write purge audit log entry to %s: %s", path, err)
+	}
+}
+
+func appendTo(path string, entry Entry) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+
+	if err != nil {
+		return err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+
+	return err
+}
+
+// Entries returns the latest `limit` entries matching the filter, oldest first. If an audit log file is configured, the
+// entries are read from that file so that they survive restarts.
+func Entries(limit int, filter func(*Entry) bool) ([]Entry, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	path := config.GetInstance().Global().PurgeAuditLog()
+
+	if path != "" {
+		return readFrom(path, limit, filter)
+	}
+
+	var r []Entry
+
+	for i := range entries {
+		if filter == nil || filter(&entries[i]) {
+			r = append(r, entries[i])
+		}
+	}
+
+	if limit > 0 && len(r) > limit {
+		r = r[len(r)-limit:]
+	}
+
+	return r, nil
+}
+
+// readFrom reads the file backwards until `limit` entries match the filter, so that the latest entries of a large audit
+// log are found without reading all of it; see #27. A limit of 0 reads all entries.
+func readFrom(path string, limit int, filter func(*Entry) bool) ([]Entry, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read purge audit log: %s", err)
+	}
+
+	var r []Entry
+	complete := func() bool {
+		return limit > 0 && len(r) >= limit
+	}
+
+	parse := func(line []byte, offset int64) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			return
+		}
+
+		var entry Entry
+
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Warnf("Ignoring line at byte %d of purge audit log %s: %s", offset, path, err)
+			return
+		}
+
+		if filter == nil || filter(&entry) {
+			r = append(r, entry)
+		}
+	}
+
+	offset := info.Size()
+	// the start of the line which continues at the beginning of the previously read chunk
+	var partial []byte
+	chunk := make([]byte, readChunkSize)
+
+	for offset > 0 && !complete() {
+		size := int64(len(chunk))
+
+		if offset < size {
+			size = offset
+		}
+
+		offset -= size
+
+		if _, err := file.ReadAt(chunk[:size], offset); err != nil {
+			return nil, fmt.Errorf("failed to read purge audit log: %s", err)
+		}
+
+		data := append(append([]byte(nil), chunk[:size]...), partial...)
+		end := int64(len(data))
+
+		for !complete() {
+			newline := bytes.LastIndexByte(data[:end], '\n')
+
+			if newline < 0 {
+				break
+			}
+
+			parse(data[newline+1:end], offset+int64(newline)+1)
+			end = int64(newline)
+		}
+
+		partial = data[:end]
+	}
+
+	if !complete() {
+		parse(partial, 0)
+	}
+
+	// the entries have been read newest first
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+
+	return r, nil
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH27_readFrom_readsLatestEntriesFromTheEnd(t *testing.T) {
+	assertion := assert.New(t)
+	path := filepath.Join(t.TempDir(), "purge.jsonl")
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+
+	// spans several chunks
+	for i := 0; i < 1000; i++ {
+		action := ActionDeleted
+
+		if i%2 == 1 {
+			action = ActionCandidate
+		}
+
+		assertion.NoError(appendTo(path, Entry{Time: now.Add(time.Duration(i) * time.Minute), Action: action, Name: fmt.Sprintf("backup-%04d.sql", i)}))
+
+		if i == 500 {
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0640)
+			assertion.NoError(err)
+			_, _ = file.WriteString("{invalid\n")
+			_ = file.Close()
+		}
+	}
+
+	latest, err := readFrom(path, 3, nil)
+	assertion.NoError(err)
+	assertion.Len(latest, 3)
+	assertion.Equal("backup-0997.sql", latest[0].Name)
+	assertion.Equal("backup-0999.sql", latest[2].Name)
+
+	deleted, err := readFrom(path, 2, func(entry *Entry) bool { return entry.Action == ActionDeleted })
+	assertion.NoError(err)
+	assertion.Equal([]string{"backup-0996.sql", "backup-0998.sql"}, []string{deleted[0].Name, deleted[1].Name})
+
+	all, err := readFrom(path, 0, nil)
+	assertion.NoError(err)
+	assertion.Len(all, 1000)
+	assertion.Equal("backup-0000.sql", all[0].Name)
+	assertion.Equal("backup-0999.sql", all[999].Name)
+
+	missing, err := readFrom(filepath.Join(t.TempDir(), "missing.jsonl"), 0, nil)
+	assertion.NoError(err)
+	assertion.Empty(missing)
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dreitier/backmon/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// TokenPrefix of API tokens, which are composed of the prefix, the name of the token as id and the secret; see #48
+const TokenPrefix = "bm_"
+
+// SessionCookie contains the ID token of users signed in to the web UI through OIDC; see #49
+const SessionCookie = "backmon_session"
+
+// Authenticator authenticates requests by basic auth, bearer token or client certificate against the configured users,
+// API tokens, JWTs of an OIDC issuer and client certificates. The single user of `basic_auth` is an admin.
+type Authenticator struct {
+	basicAuth *config.BasicAuthConfiguration
+	users     map[string]*credential
+	// API tokens by their id
+	tokens map[string]*credential
+	oidc      *oidcVerifier
+	// client certificates; see #50
+	certificates *certificateVerifier
+	// principals of verified credentials, so that the costly bcrypt comparison is only done once per credential
+	mutex    sync.Mutex
+	verified map[[sha256.Size]byte]*Principal
+}
+
+type credential struct {
+	hash      []byte
+	principal *Principal
+}
+
+// NewAuthenticator Return the authenticator of the configuration. Invalid users, API tokens and OIDC configurations are
+// ignored, while invalid client certificate mappings are an error: the client certificates may be the only
+// authentication of the API.
+func NewAuthenticator(cfg *config.HttpConfiguration) (*Authenticator, error) {
+	r := &Authenticator{
+		basicAuth: cfg.BasicAuth,
+		users:     make(map[string]*credential),
+		tokens:    make(map[string]*credential),
+		verified:  make(map[[sha256.Size]byte]*Principal),
+	}
+
+	for _, user := range cfg.Users {
+		parsed, err := newCredential(user)
+
+		if err != nil {
+			log.Errorf("Ignoring user '%s': %s", user.Name, err)
+			continue
+		}
+
+		r.users[user.Name] = parsed
+	}
+
+	for _, token := range cfg.Tokens {
+		if strings.Contains(token.Name, "_") {
+			log.Errorf("Ignoring API token '%s': the name is the id of the token and must not contain '_'", token.Name)
+			continue
+		}
+
+		parsed, err := newCredential(token)
+
+		if err != nil {
+			log.Errorf("Ignoring API token '%s': %s", token.Name, err)
+			continue
+		}
+
+		r.tokens[token.Name] = parsed
+	}
+
+	if cfg.Tls != nil && cfg.Tls.ClientCaPath != "" && len(cfg.Tls.Clients) > 0 {
+		verifier, err := newCertificateVerifier(cfg.Tls)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificates: %w", err)
+		}
+
+		r.certificates = verifier
+	}
+
+	if cfg.Oidc != nil {
+		verifier, err := newOidcVerifier(cfg.Oidc)
+
+		if err != nil {
+			log.Errorf("Ignoring OIDC configuration: %s", err)
+		} else {
+			r.oidc = verifier
+		}
+	}
+
+	return r, nil
+}
+
+func newCredential(cfg *config.CredentialConfiguration) (*credential, error) {
+	role, err := ParseRole(cfg.Role)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = bcrypt.Cost([]byte(cfg.Hash)); err != nil {
+		return nil, fmt.Errorf("invalid bcrypt hash: %w", err)
+	}
+
+	return &credential{
+		hash: []byte(cfg.Hash),
+		principal: &Principal{
+			Name:         cfg.Name,
+			Role:         role,
+			Environments: cfg.Environments,
+			Disks:        cfg.Disks,
+		},
+	}, nil
+}
+
+// Enabled Return whether any credentials have been configured; otherwise, requests are not authenticated
+func (a *Authenticator) Enabled() bool {
+	return a.basicAuth != nil || len(a.users) > 0 || len(a.tokens) > 0 || a.oidc != nil || a.certificates != nil
+}
+
+// Authenticate Return the principal of the basic auth credentials, the bearer token, the session cookie or the client
+// certificate of the request. Bearer tokens in the format of a JWT are verified against the OIDC issuer, if configured.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		if a.oidc != nil && isJWT(token) {
+			return a.oidc.authenticate(r.Context(), token)
+		}
+
+		return a.authenticateToken(token)
+	}
+
+	if username, password, ok := r.BasicAuth(); ok {
+		return a.authenticateUser(username, password)
+	}
+
+	if session, err := r.Cookie(SessionCookie); err == nil && a.oidc != nil {
+		return a.oidc.authenticate(r.Context(), session.Value)
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && a.certificates != nil {
+		return a.certificates.authenticate(r.TLS)
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// LoginEnabled Return whether users can sign in to the web UI through the OIDC authorization code flow
+func (a *Authenticator) LoginEnabled() bool {
+	return a.oidc != nil && a.oidc.cfg.Login
+}
+
+// OAuth2Config Return the configuration of the authorization code flow; the endpoints are discovered if not configured
+func (a *Authenticator) OAuth2Config(ctx context.Context, redirectUrl string) (*oauth2.Config, error) {
+	if !a.LoginEnabled() {
+		return nil, errors.New("login through OIDC is not configured")
+	}
+
+	endpoint, err := a.oidc.endpoint(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := a.oidc.cfg
+
+	if cfg.RedirectUrl != "" {
+		redirectUrl = cfg.RedirectUrl
+	}
+
+	return &oauth2.Config{
+		ClientID:     cfg.ClientId,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  redirectUrl,
+		Scopes:       cfg.Scopes,
+	}, nil
+}
+
+// AuthenticateIDToken Return the principal of the ID token received through the authorization code flow
+func (a *Authenticator) AuthenticateIDToken(ctx context.Context, raw string) (*Principal, error) {
+	if a.oidc == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.oidc.authenticate(ctx, raw)
+}
+
+func (a *Authenticator) authenticateUser(username string, password string) (*Principal, error) {
+	if a.basicAuth != nil &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(a.basicAuth.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(a.basicAuth.Password)) == 1 {
+		return &Principal{Name: username, Role: RoleAdmin}, nil
+	}
+
+	user, exists := a.users[username]
+
+	if !exists {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.verify(user, "user\x00"+username+"\x00"+password, password)
+}
+
+// authenticateToken Return the principal of an API token in the format bm_<id>_<secret>. Only the secret is hashed; the
+// id selects the single credential to compare it with, so that unknown tokens do not cost a bcrypt comparison each.
+func (a *Authenticator) authenticateToken(token string) (*Principal, error) {
+	id, secret, found := strings.Cut(strings.TrimPrefix(token, TokenPrefix), "_")
+
+	if !strings.HasPrefix(token, TokenPrefix) || !found {
+		return nil, ErrInvalidCredentials
+	}
+
+	candidate, exists := a.tokens[id]
+
+	if !exists {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.verify(candidate, "token\x00"+token, secret)
+}
+
+// verify Return the principal of the credential if it matches the secret
+func (a *Authenticator) verify(candidate *credential, key string, secret string) (*Principal, error) {
+	digest := sha256.Sum256([]byte(key))
+
+	a.mutex.Lock()
+	principal, verified := a.verified[digest]
+	a.mutex.Unlock()
+
+	if verified {
+		return principal, nil
+	}
+
+	if bcrypt.CompareHashAndPassword(candidate.hash, []byte(secret)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	a.mutex.Lock()
+	a.verified[digest] = candidate.principal
+	a.mutex.Unlock()
+
+	return candidate.principal, nil
+}
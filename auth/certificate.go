@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/dreitier/backmon/config"
+)
+
+// certificateVerifier maps client certificates of the client CA to principals; see #50
+type certificateVerifier struct {
+	roots    *x509.CertPool
+	mappings []*certificateMapping
+}
+
+type certificateMapping struct {
+	*config.ClientCertificateConfiguration
+	role Role
+}
+
+func newCertificateVerifier(cfg *config.TlsConfiguration) (*certificateVerifier, error) {
+	roots, err := ReadCertPool(cfg.ClientCaPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certificateVerifier{roots: roots}
+
+	for _, client := range cfg.Clients {
+		role, err := ParseRole(client.Role)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.mappings = append(r.mappings, &certificateMapping{ClientCertificateConfiguration: client, role: role})
+	}
+
+	return r, nil
+}
+
+// ReadCertPool Return the pool of the PEM encoded certificates in the file
+func ReadCertPool(path string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := x509.NewCertPool()
+
+	if !r.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("file '%s' contains no PEM encoded certificates", path)
+	}
+
+	return r, nil
+}
+
+// authenticate Return the principal of the connection's client certificate. Unless the certificate has already been
+// verified during the TLS handshake, it is verified against the client CA.
+func (v *certificateVerifier) authenticate(state *tls.ConnectionState) (*Principal, error) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	leaf := state.PeerCertificates[0]
+
+	if len(state.VerifiedChains) == 0 {
+		intermediates := x509.NewCertPool()
+
+		for _, certificate := range state.PeerCertificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         v.roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+
+		if err != nil {
+			return nil, ErrInvalidCredentials
+		}
+	}
+
+	for _, mapping := range v.mappings {
+		if !mapping.matches(leaf) {
+			continue
+		}
+
+		principal := &Principal{
+			Name:         leaf.Subject.CommonName,
+			Role:         mapping.role,
+			Environments: mapping.Environments,
+			Disks:        mapping.Disks,
+		}
+
+		if principal.Name == "" {
+			principal.Name = leaf.Subject.String()
+		}
+
+		return principal, nil
+	}
+
+	return nil, ErrNoRole
+}
+
+// matches Return whether the certificate has the subject or the SAN of the mapping
+func (m *certificateMapping) matches(certificate *x509.Certificate) bool {
+	if m.Subject != "" && m.Subject != certificate.Subject.String() && m.Subject != certificate.Subject.CommonName {
+		return false
+	}
+
+	if m.San == "" {
+		return true
+	}
+
+	var sans []string
+	sans = append(sans, certificate.DNSNames...)
+	sans = append(sans, certificate.EmailAddresses...)
+
+	for _, ip := range certificate.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	for _, uri := range certificate.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	for _, san := range sans {
+		if san == m.San {
+			return true
+		}
+	}
+
+	return false
+}
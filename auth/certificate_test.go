@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Internal CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, _ := x509.ParseCertificate(der)
+
+	return &testCA{certificate: certificate, key: key}
+}
+
+// issue Return a client certificate of the CA
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, dnsNames ...string) *x509.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, _ := x509.ParseCertificate(der)
+
+	return certificate
+}
+
+func (ca *testCA) write(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_GH50_Authenticator_mapsClientCertificates(t *testing.T) {
+	assertion := assert.New(t)
+	ca := newTestCA(t)
+	sut, err := NewAuthenticator(&config.HttpConfiguration{
+		Tls: &config.TlsConfiguration{
+			ClientCaPath: ca.write(t),
+			ClientAuth:   config.ClientAuthRequest,
+			Clients: []*config.ClientCertificateConfiguration{
+				{Subject: "CN=prometheus,O=Monitoring"},
+				{San: "backup-tool.internal", Role: "operator", Environments: []string{"prod"}},
+			},
+		},
+	})
+	assertion.NoError(err)
+
+	assertion.True(sut.Enabled())
+
+	request := func(certificates ...*x509.Certificate) (*Principal, error) {
+		r := httptest.NewRequest(http.MethodGet, "/api", nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: certificates}
+		return sut.Authenticate(r)
+	}
+
+	principal, err := request(ca.issue(t, pkix.Name{CommonName: "prometheus", Organization: []string{"Monitoring"}}))
+	assertion.NoError(err)
+	assertion.Equal("prometheus", principal.Name)
+	assertion.Equal(RoleViewer, principal.Role)
+
+	principal, err = request(ca.issue(t, pkix.Name{CommonName: "tool"}, "tool.internal", "backup-tool.internal"))
+	assertion.NoError(err)
+	assertion.Equal(RoleOperator, principal.Role)
+	assertion.Equal([]string{"prod"}, principal.Environments)
+
+	_, err = request(ca.issue(t, pkix.Name{CommonName: "prometheus", Organization: []string{"Other"}}))
+	assertion.ErrorIs(err, ErrNoRole)
+
+	// certificates of other CAs are rejected, even if they have not been verified during the handshake
+	_, err = request(newTestCA(t).issue(t, pkix.Name{CommonName: "prometheus", Organization: []string{"Monitoring"}}))
+	assertion.ErrorIs(err, ErrInvalidCredentials)
+
+	_, err = request()
+	assertion.ErrorIs(err, ErrNoCredentials)
+
+	// an invalid mapping must not leave the API without authentication
+	_, err = NewAuthenticator(&config.HttpConfiguration{
+		Tls: &config.TlsConfiguration{
+			ClientCaPath: ca.write(t),
+			Clients:      []*config.ClientCertificateConfiguration{{Subject: "prometheus", Role: "superuser"}},
+		},
+	})
+	assertion.Error(err)
+}
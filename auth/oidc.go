@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dreitier/backmon/config"
+	"github.com/go-jose/go-jose/v4"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// ErrNoRole the token is valid, but none of its claims is mapped to a role
+var ErrNoRole = errors.New("no role has been mapped to the token")
+
+// the algorithms of static and remote key sets; a discovered issuer announces its own
+var signingAlgorithms = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+	oidc.EdDSA,
+}
+
+// oidcVerifier validates JWTs of an OpenID Connect issuer and maps their claims to principals; see #49
+type oidcVerifier struct {
+	cfg   *config.OidcConfiguration
+	roles []*roleMapping
+	// the issuer is discovered on first use, so that backmon starts even if the issuer is unavailable
+	mutex    sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+type roleMapping struct {
+	*config.OidcRoleMapping
+	role Role
+}
+
+func newOidcVerifier(cfg *config.OidcConfiguration) (*oidcVerifier, error) {
+	r := &oidcVerifier{cfg: cfg}
+
+	if cfg.DefaultRole != "" {
+		if _, err := ParseRole(cfg.DefaultRole); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, mapping := range cfg.Roles {
+		role, err := ParseRole(mapping.Role)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.roles = append(r.roles, &roleMapping{OidcRoleMapping: mapping, role: role})
+	}
+
+	verifierConfig := &oidc.Config{
+		// the audiences are checked afterwards, as there may be more than one
+		SkipClientIDCheck:    true,
+		SupportedSigningAlgs: signingAlgorithms,
+	}
+
+	switch {
+	case cfg.JwksFile != "":
+		keys, err := readKeySet(cfg.JwksFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.verifier = oidc.NewVerifier(cfg.Issuer, &oidc.StaticKeySet{PublicKeys: keys}, verifierConfig)
+	case cfg.JwksUrl != "":
+		r.verifier = oidc.NewVerifier(cfg.Issuer, oidc.NewRemoteKeySet(context.Background(), cfg.JwksUrl), verifierConfig)
+	}
+
+	return r, nil
+}
+
+// readKeySet Return the public keys of the JWKS file
+func readKeySet(path string) ([]crypto.PublicKey, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet jose.JSONWebKeySet
+
+	if err = json.Unmarshal(content, &keySet); err != nil {
+		return nil, fmt.Errorf("invalid JWKS file '%s': %w", path, err)
+	}
+
+	r := make([]crypto.PublicKey, 0, len(keySet.Keys))
+
+	for _, key := range keySet.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		r = append(r, key.Public().Key)
+	}
+
+	if len(r) == 0 {
+		return nil, fmt.Errorf("JWKS file '%s' contains no signing keys", path)
+	}
+
+	return r, nil
+}
+
+// discover Return the provider of the issuer; it is discovered once
+func (v *oidcVerifier) discover(ctx context.Context) (*oidc.Provider, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.provider != nil {
+		return v.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, v.cfg.Issuer)
+
+	if err != nil {
+		return nil, fmt.Errorf("discovery of issuer '%s' failed: %w", v.cfg.Issuer, err)
+	}
+
+	v.provider = provider
+
+	if v.verifier == nil {
+		// the keys are fetched independently of the request which triggered the discovery
+		v.verifier = provider.Verifier(&oidc.Config{SkipClientIDCheck: true})
+	}
+
+	return provider, nil
+}
+
+func (v *oidcVerifier) tokenVerifier(ctx context.Context) (*oidc.IDTokenVerifier, error) {
+	v.mutex.Lock()
+	verifier := v.verifier
+	v.mutex.Unlock()
+
+	if verifier != nil {
+		return verifier, nil
+	}
+
+	if _, err := v.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.verifier, nil
+}
+
+// endpoint Return the authorization and token endpoint of the issuer; without configured URLs, they are discovered
+func (v *oidcVerifier) endpoint(ctx context.Context) (oauth2.Endpoint, error) {
+	if v.cfg.AuthorizationUrl != "" && v.cfg.TokenUrl != "" {
+		return oauth2.Endpoint{AuthURL: v.cfg.AuthorizationUrl, TokenURL: v.cfg.TokenUrl}, nil
+	}
+
+	provider, err := v.discover(ctx)
+
+	if err != nil {
+		return oauth2.Endpoint{}, err
+	}
+
+	return provider.Endpoint(), nil
+}
+
+// authenticate Return the principal of the JWT
+func (v *oidcVerifier) authenticate(ctx context.Context, raw string) (*Principal, error) {
+	verifier, err := v.tokenVerifier(ctx)
+
+	if err != nil {
+		log.Errorf("Unable to verify JWT: %s", err)
+		return nil, ErrInvalidCredentials
+	}
+
+	token, err := verifier.Verify(ctx, raw)
+
+	if err != nil {
+		log.Debugf("Rejecting JWT: %s", err)
+		return nil, ErrInvalidCredentials
+	}
+
+	if !v.hasAudience(token.Audience) {
+		log.Debugf("Rejecting JWT of subject '%s': audience %v is not accepted", token.Subject, token.Audience)
+		return nil, ErrInvalidCredentials
+	}
+
+	var claims map[string]interface{}
+
+	if err = token.Claims(&claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	principal := &Principal{Name: token.Subject}
+
+	if name, ok := claim(claims, v.cfg.UsernameClaim).(string); ok && name != "" {
+		principal.Name = name
+	}
+
+	var granted *roleMapping
+
+	for _, mapping := range v.roles {
+		if matches(claim(claims, mapping.Claim), mapping.Value) && (granted == nil || mapping.role > granted.role) {
+			granted = mapping
+		}
+	}
+
+	switch {
+	case granted != nil:
+		principal.Role = granted.role
+		principal.Environments = granted.Environments
+		principal.Disks = granted.Disks
+	case v.cfg.DefaultRole != "":
+		principal.Role, _ = ParseRole(v.cfg.DefaultRole)
+	default:
+		return nil, ErrNoRole
+	}
+
+	return principal, nil
+}
+
+func (v *oidcVerifier) hasAudience(audiences []string) bool {
+	for _, audience := range audiences {
+		for _, accepted := range v.cfg.Audiences {
+			if audience == accepted {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// claim Return the value of the claim; nested claims are separated by a dot
+func claim(claims map[string]interface{}, name string) interface{} {
+	var r interface{} = claims
+
+	for _, key := range strings.Split(name, ".") {
+		object, ok := r.(map[string]interface{})
+
+		if !ok {
+			return nil
+		}
+
+		r = object[key]
+	}
+
+	return r
+}
+
+// matches Return whether the claim is the value or a list containing it
+func matches(claim interface{}, value string) bool {
+	switch typed := claim.(type) {
+	case []interface{}:
+		for _, elem := range typed {
+			if matches(elem, value) {
+				return true
+			}
+		}
+
+		return false
+	case nil:
+		return false
+	default:
+		return fmt.Sprint(typed) == value
+	}
+}
+
+// isJWT Return whether the token looks like a JWT rather than an API token
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
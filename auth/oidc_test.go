@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockIssuer an in-process OIDC issuer signing JWTs with an RSA key
+type mockIssuer struct {
+	key    *rsa.PrivateKey
+	server *httptest.Server
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                r.server.URL,
+			"jwks_uri":                              r.server.URL + "/jwks",
+			"authorization_endpoint":                r.server.URL + "/authorize",
+			"token_endpoint":                        r.server.URL + "/token",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(r.keySet())
+	})
+
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+
+	return r
+}
+
+func (m *mockIssuer) keySet() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &m.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}}
+}
+
+// sign Return a JWT of the issuer with the claims; missing registered claims are set to valid values
+func (m *mockIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	defaults := map[string]interface{}{
+		"iss": m.server.URL,
+		"sub": "0815",
+		"aud": "backmon",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+
+	for name, value := range defaults {
+		if _, exists := claims[name]; !exists {
+			claims[name] = value
+		}
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: m.key, KeyID: "test"}}, (&jose.SignerOptions{}).WithType("JWT"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, _ := json.Marshal(claims)
+	signed, err := signer.Sign(payload)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := signed.CompactSerialize()
+
+	return r
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return r
+}
+
+func Test_GH49_Authenticator_verifiesJWTsOfDiscoveredIssuer(t *testing.T) {
+	assertion := assert.New(t)
+	issuer := newMockIssuer(t)
+	sut, err := NewAuthenticator(&config.HttpConfiguration{
+		Tokens: []*config.CredentialConfiguration{{Name: "ci", Hash: hash(t, "token-1"), Role: "downloader"}},
+		Oidc: &config.OidcConfiguration{
+			Issuer:        issuer.server.URL,
+			Audiences:     []string{"backmon"},
+			UsernameClaim: "preferred_username",
+			Roles: []*config.OidcRoleMapping{
+				{Claim: "groups", Value: "backmon-ops", Role: "operator", Environments: []string{"prod"}},
+				{Claim: "realm_access.roles", Value: "backmon-admin", Role: "admin"},
+			},
+		},
+	})
+	assertion.NoError(err)
+
+	principal, err := sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{
+		"preferred_username": "alice",
+		"groups":             []string{"staff", "backmon-ops"},
+	})))
+	assertion.NoError(err)
+	assertion.Equal("alice", principal.Name)
+	assertion.Equal(RoleOperator, principal.Role)
+	assertion.Equal([]string{"prod"}, principal.Environments)
+
+	// the mapping with the highest role applies
+	principal, err = sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{
+		"groups":       []string{"backmon-ops"},
+		"realm_access": map[string]interface{}{"roles": []string{"backmon-admin"}},
+	})))
+	assertion.NoError(err)
+	assertion.Equal("0815", principal.Name)
+	assertion.Equal(RoleAdmin, principal.Role)
+	assertion.False(principal.Scoped())
+
+	_, err = sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{"groups": "staff"})))
+	assertion.ErrorIs(err, ErrNoRole)
+
+	_, err = sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{"groups": "backmon-ops", "aud": "other"})))
+	assertion.ErrorIs(err, ErrInvalidCredentials)
+
+	_, err = sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{"groups": "backmon-ops", "exp": time.Now().Add(-time.Minute).Unix()})))
+	assertion.ErrorIs(err, ErrInvalidCredentials)
+
+	_, err = sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{"groups": "backmon-ops", "iss": "https://sso.invalid"})))
+	assertion.ErrorIs(err, ErrInvalidCredentials)
+
+	// API tokens are still accepted
+	principal, err = sut.Authenticate(bearer("bm_ci_token-1"))
+	assertion.NoError(err)
+	assertion.Equal("ci", principal.Name)
+
+	// session of the web UI
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookie, Value: issuer.sign(t, map[string]interface{}{"groups": "backmon-ops"})})
+	principal, err = sut.Authenticate(r)
+	assertion.NoError(err)
+	assertion.Equal(RoleOperator, principal.Role)
+}
+
+func Test_GH49_Authenticator_verifiesJWTsWithLocalKeySet(t *testing.T) {
+	assertion := assert.New(t)
+	issuer := newMockIssuer(t)
+	// air-gapped: the issuer is never requested
+	issuer.server.Close()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	content, _ := json.Marshal(issuer.keySet())
+
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sut, err := NewAuthenticator(&config.HttpConfiguration{
+		Oidc: &config.OidcConfiguration{
+			Issuer:      issuer.server.URL,
+			Audiences:   []string{"backmon", "backmon-cli"},
+			JwksFile:    path,
+			DefaultRole: "viewer",
+		},
+	})
+	assertion.NoError(err)
+
+	assertion.True(sut.Enabled())
+	assertion.False(sut.LoginEnabled())
+
+	principal, err := sut.Authenticate(bearer(issuer.sign(t, map[string]interface{}{"aud": []string{"account", "backmon-cli"}})))
+	assertion.NoError(err)
+	assertion.Equal(RoleViewer, principal.Role)
+
+	other := newMockIssuer(t)
+	token := other.sign(t, map[string]interface{}{"iss": issuer.server.URL})
+	_, err = sut.Authenticate(bearer(token))
+	assertion.ErrorIs(err, ErrInvalidCredentials)
+}
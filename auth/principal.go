@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal an authenticated user or API token
+type Principal struct {
+	Name string
+	Role Role
+	// the principal may only access these environments and disks; empty if it is not restricted
+	Environments []string
+	Disks        []string
+}
+
+type contextKey struct{}
+
+// WithPrincipal Return a copy of the context with the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext Return the principal of the context; nil if the request has not been authenticated, e.g. because
+// authentication is disabled
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
+
+// Has Return whether the principal has at least the role; a nil principal is not restricted
+func (p *Principal) Has(role Role) bool {
+	return p == nil || p.Role >= role
+}
+
+// Scoped Return whether the principal is restricted to some environments or disks
+func (p *Principal) Scoped() bool {
+	return p != nil && (len(p.Environments) > 0 || len(p.Disks) > 0)
+}
+
+// CanAccess Return whether the principal may access the disk of the environment. An empty disk stands for all disks of
+// the environment, an empty environment for all environments; a nil principal is not restricted.
+func (p *Principal) CanAccess(environment string, disk string) bool {
+	if p == nil {
+		return true
+	}
+
+	if len(p.Environments) > 0 && !slices.Contains(p.Environments, environment) {
+		return false
+	}
+
+	return len(p.Disks) == 0 || slices.Contains(p.Disks, disk)
+}
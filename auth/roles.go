@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"fmt"
+)
+
+// Role of a user or API token; each role includes the permissions of the roles before it. See #48.
+type Role int
+
+const (
+	// RoleViewer may read the status of the backups
+	RoleViewer Role = iota + 1
+	// RoleDownloader may additionally download backups and create download links
+	RoleDownloader
+	// RoleOperator may additionally trigger rescans, silence alerts and send heartbeats
+	RoleOperator
+	// RoleAdmin may additionally pause and resume purging
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:     "viewer",
+	RoleDownloader: "downloader",
+	RoleOperator:   "operator",
+	RoleAdmin:      "admin",
+}
+
+// ParseRole Return the role of the name; without name, the least privileged role is returned
+func ParseRole(name string) (Role, error) {
+	if name == "" {
+		return RoleViewer, nil
+	}
+
+	for role, roleName := range roleNames {
+		if roleName == name {
+			return role, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown role '%s', must be one of viewer, downloader, operator or admin", name)
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
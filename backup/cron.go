@@ -56,3 +56,14 @@ func findPreviousInRange(cron *cronexpr.Expression, low time.Time, high time.Tim
 		}
 	}
 }
+
+// IsLate Return true if the schedule expected a backup run after the given time of the latest backup
+func IsLate(cron *cronexpr.Expression, latest time.Time, moment time.Time) bool {
+	expected := FindPrevious(cron, moment)
+
+	if expected.IsZero() {
+		return false
+	}
+
+	return latest.Before(expected)
+}
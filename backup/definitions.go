@@ -22,6 +22,11 @@ const (
 	SubstitutionMarker = '%'
 )
 
+const (
+	PurgeActionDelete = "delete"
+	PurgeActionMove   = "move"
+)
+
 const (
 	SortByInterpolation = iota
 	SortByBornAt        = iota
@@ -164,6 +169,14 @@ func parseFiles(raw map[string]*RawFile, variableOffsets map[string]uint) []*Fil
 
 		sortBy := parseSortBy(rawFile.Sort)
 
+		purge := rawFile.Purge
+		purgeAction := parsePurgeAction(rawFile.PurgeAction)
+
+		if purge && purgeAction == PurgeActionMove && rawFile.PurgeTarget == "" {
+			log.Errorf("File '%s' has 'purge-action: move' but no 'purge-target'; disabling purge", rawPattern)
+			purge = false
+		}
+
 		var alias string
 		var safeAlias string
 
@@ -188,16 +201,30 @@ func parseFiles(raw map[string]*RawFile, variableOffsets map[string]uint) []*Fil
 		aliases[alias] = empty{}
 
 		file := &FileDefinition{
-			Pattern:         rawPattern,
-			Filter:          pattern,
-			VariableMapping: variables,
-			Alias:           alias,
-			SafeAlias:       safeAlias,
-			Schedule:        rawFile.Schedule,
-			SortBy:          sortBy,
-			Purge:           rawFile.Purge,
-			RetentionCount:  retentionCount,
-			RetentionAge:    retentionAge,
+			Pattern:              rawPattern,
+			Filter:               pattern,
+			VariableMapping:      variables,
+			Alias:                alias,
+			SafeAlias:            safeAlias,
+			Schedule:             rawFile.Schedule,
+			ScheduleExpression:   rawFile.ScheduleExpression,
+			SortBy:               sortBy,
+			Purge:                purge,
+			PurgeDryRun:          rawFile.PurgeDryRun,
+			PurgeMaxFiles:        rawFile.PurgeMaxFiles,
+			PurgeMaxBytesPercent: rawFile.PurgeMaxBytesPercent,
+			PurgeAction:          purgeAction,
+			PurgeTarget:          rawFile.PurgeTarget,
+			PurgeTargetDisk:      rawFile.PurgeTargetDisk,
+			ImmutableMode:        strings.ToUpper(rawFile.ImmutableMode),
+			ImmutableRetention:   rawFile.ImmutableRetention,
+			ImmutableLegalHold:   rawFile.ImmutableLegalHold,
+			RetentionCount:       retentionCount,
+			RetentionAge:         retentionAge,
+			RetentionDaily:       rawFile.RetentionDaily,
+			RetentionWeekly:      rawFile.RetentionWeekly,
+			RetentionMonthly:     rawFile.RetentionMonthly,
+			RetentionYearly:      rawFile.RetentionYearly,
 		}
 
 		files = append(files, file)
@@ -261,6 +288,18 @@ func parseSortBy(op string) int {
 	}
 }
 
+func parsePurgeAction(action string) string {
+	switch action {
+	case PurgeActionMove:
+		return PurgeActionMove
+	case PurgeActionDelete, "":
+		return PurgeActionDelete
+	default:
+		log.Warnf("Unknown 'purge-action' parameter '%s', defaulting to '%s'", action, PurgeActionDelete)
+		return PurgeActionDelete
+	}
+}
+
 func parseVariableOperation(op string) func(string) string {
 	switch op {
 	case "lower":
@@ -311,6 +350,11 @@ func retentionOrDefault(file *RawFile) (uint64, time.Duration) {
 		return 3, file.RetentionAge
 	}
 
+	// #26: grandfather-father-son buckets are a retention of their own
+	if file.RetentionDaily > 0 || file.RetentionWeekly > 0 || file.RetentionMonthly > 0 || file.RetentionYearly > 0 {
+		return 0, 0
+	}
+
 	log.Warn("Purge is enabled, but no retention is specified; defaulting to 'count: 3' and 'age: 7d'")
 
 	return 3, config.Week
@@ -623,16 +667,35 @@ type VariableDefinition struct {
 }
 
 type FileDefinition struct {
-	Pattern         string
-	Filter          *regexp.Regexp
-	VariableMapping []VariableReference
-	Alias           string
-	SafeAlias       string
-	Schedule        *cronexpr.Expression
-	SortBy          int
-	Purge           bool
-	RetentionCount  uint64
-	RetentionAge    time.Duration
+	Pattern              string
+	Filter               *regexp.Regexp
+	VariableMapping      []VariableReference
+	Alias                string
+	SafeAlias            string
+	Schedule             *cronexpr.Expression
+	ScheduleExpression   string
+	SortBy               int
+	Purge                bool
+	PurgeDryRun          bool
+	PurgeMaxFiles        uint64
+	PurgeMaxBytesPercent float64
+	PurgeAction          string
+	PurgeTarget          string
+	PurgeTargetDisk      string
+	ImmutableMode        string
+	ImmutableRetention   time.Duration
+	ImmutableLegalHold   bool
+	RetentionCount       uint64
+	RetentionAge         time.Duration
+	RetentionDaily       uint64
+	RetentionWeekly      uint64
+	RetentionMonthly     uint64
+	RetentionYearly      uint64
+}
+
+// RequiresImmutability Return true if the latest file has to be locked against deletion
+func (file *FileDefinition) RequiresImmutability() bool {
+	return file.ImmutableMode != "" || file.ImmutableRetention > 0 || file.ImmutableLegalHold
 }
 
 func (file *FileDefinition) MarshalJSON() ([]byte, error) {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -320,3 +321,68 @@ func Test_parsePatternWithWildcard(t *testing.T) {
 	}
 
 }
+
+func Test_GH26_parseRetentionBuckets(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, err := ParseRawDefinitions(strings.NewReader(`
+directories:
+  backups:
+    defaults:
+      schedule: 0 2 * * *
+      retention-daily: 7
+      retention-weekly: 4
+    files:
+      dump-%Y%M%D.sql:
+        purge: true
+        retention-monthly: 12
+        retention-yearly: 3
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var def Definition
+	def.Directories, err = parseDirectories(raw)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := def.Directories[0].Files[0]
+
+	assertion.Equal(uint64(7), file.RetentionDaily)
+	assertion.Equal(uint64(4), file.RetentionWeekly)
+	assertion.Equal(uint64(12), file.RetentionMonthly)
+	assertion.Equal(uint64(3), file.RetentionYearly)
+	// buckets replace the default retention of purged files
+	assertion.Equal(uint64(0), file.RetentionCount)
+	assertion.Equal(4, len(file.RetentionBuckets()))
+}
+
+func Test_GH27_parsePurgeDryRun(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, err := ParseRawDefinitions(strings.NewReader(`
+directories:
+  backups:
+    defaults:
+      schedule: 0 2 * * *
+      purge: dry-run
+    files:
+      dump-%Y%M%D.sql:
+      dump-%Y%M%D.tar:
+        purge: true
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertion.True(raw.directories["backups"].Defaults.Purge)
+	assertion.True(raw.directories["backups"].Defaults.PurgeDryRun)
+	assertion.True(raw.directories["backups"].Files["dump-%Y%M%D.sql"].PurgeDryRun)
+	assertion.True(raw.directories["backups"].Files["dump-%Y%M%D.tar"].Purge)
+	assertion.False(raw.directories["backups"].Files["dump-%Y%M%D.tar"].PurgeDryRun)
+}
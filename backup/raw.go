@@ -27,20 +27,48 @@ type RawDirectory struct {
 }
 
 type Defaults struct {
-	Schedule       *cronexpr.Expression
-	Sort           string
-	RetentionCount uint64
-	RetentionAge   time.Duration
-	Purge          bool
+	Schedule             *cronexpr.Expression
+	ScheduleExpression   string
+	Sort                 string
+	RetentionCount       uint64
+	RetentionAge         time.Duration
+	RetentionDaily       uint64
+	RetentionWeekly      uint64
+	RetentionMonthly     uint64
+	RetentionYearly      uint64
+	Purge                bool
+	PurgeDryRun          bool
+	PurgeMaxFiles        uint64
+	PurgeMaxBytesPercent float64
+	PurgeAction          string
+	PurgeTarget          string
+	PurgeTargetDisk      string
+	ImmutableMode        string
+	ImmutableRetention   time.Duration
+	ImmutableLegalHold   bool
 }
 
 type RawFile struct {
-	Alias          string
-	Schedule       *cronexpr.Expression
-	Sort           string
-	RetentionCount uint64
-	RetentionAge   time.Duration
-	Purge          bool
+	Alias                string
+	Schedule             *cronexpr.Expression
+	ScheduleExpression   string
+	Sort                 string
+	RetentionCount       uint64
+	RetentionAge         time.Duration
+	RetentionDaily       uint64
+	RetentionWeekly      uint64
+	RetentionMonthly     uint64
+	RetentionYearly      uint64
+	Purge                bool
+	PurgeDryRun          bool
+	PurgeMaxFiles        uint64
+	PurgeMaxBytesPercent float64
+	PurgeAction          string
+	PurgeTarget          string
+	PurgeTargetDisk      string
+	ImmutableMode        string
+	ImmutableRetention   time.Duration
+	ImmutableLegalHold   bool
 }
 
 func ParseRawDefinitions(definitionsReader io.Reader) (*RawDefinition, error) {
@@ -120,10 +148,24 @@ func parseFileSection(cfg config.Raw, defaults *Defaults) (*RawFile, error) {
 
 	if defaults != nil {
 		file.Schedule = defaults.Schedule
+		file.ScheduleExpression = defaults.ScheduleExpression
 		file.Sort = defaults.Sort
 		file.Purge = defaults.Purge
+		file.PurgeDryRun = defaults.PurgeDryRun
+		file.PurgeMaxFiles = defaults.PurgeMaxFiles
+		file.PurgeMaxBytesPercent = defaults.PurgeMaxBytesPercent
+		file.PurgeAction = defaults.PurgeAction
+		file.PurgeTarget = defaults.PurgeTarget
+		file.PurgeTargetDisk = defaults.PurgeTargetDisk
+		file.ImmutableMode = defaults.ImmutableMode
+		file.ImmutableRetention = defaults.ImmutableRetention
+		file.ImmutableLegalHold = defaults.ImmutableLegalHold
 		file.RetentionCount = defaults.RetentionCount
 		file.RetentionAge = defaults.RetentionAge
+		file.RetentionDaily = defaults.RetentionDaily
+		file.RetentionWeekly = defaults.RetentionWeekly
+		file.RetentionMonthly = defaults.RetentionMonthly
+		file.RetentionYearly = defaults.RetentionYearly
 	}
 
 	if cfg.Has("schedule") {
@@ -134,6 +176,7 @@ func parseFileSection(cfg config.Raw, defaults *Defaults) (*RawFile, error) {
 		}
 
 		file.Schedule = schedule
+		file.ScheduleExpression = cfg.String("schedule")
 	}
 
 	if cfg.Has("sort") {
@@ -141,7 +184,42 @@ func parseFileSection(cfg config.Raw, defaults *Defaults) (*RawFile, error) {
 	}
 
 	if cfg.Has("purge") {
-		file.Purge = cfg.Bool("purge")
+		file.Purge, file.PurgeDryRun = parsePurge(cfg)
+	}
+
+	// #28: guards which refuse a purge run
+	if cfg.Has("purge-max-files") {
+		file.PurgeMaxFiles = cfg.Uint64("purge-max-files")
+	}
+
+	if cfg.Has("purge-max-bytes-percent") {
+		file.PurgeMaxBytesPercent = cfg.Float64("purge-max-bytes-percent")
+	}
+
+	// #29: move purged files to an archive instead of deleting them
+	if cfg.Has("purge-action") {
+		file.PurgeAction = cfg.String("purge-action")
+	}
+
+	if cfg.Has("purge-target") {
+		file.PurgeTarget = cfg.String("purge-target")
+	}
+
+	if cfg.Has("purge-target-disk") {
+		file.PurgeTargetDisk = cfg.String("purge-target-disk")
+	}
+
+	// #30: required immutability of the latest file, e.g. through S3 Object Lock
+	if cfg.Has("immutable-mode") {
+		file.ImmutableMode = cfg.String("immutable-mode")
+	}
+
+	if cfg.Has("immutable-retention") {
+		file.ImmutableRetention = cfg.Duration("immutable-retention")
+	}
+
+	if cfg.Has("immutable-legal-hold") {
+		file.ImmutableLegalHold = cfg.Bool("immutable-legal-hold")
 	}
 
 	if cfg.Has("retention-count") {
@@ -152,6 +230,23 @@ func parseFileSection(cfg config.Raw, defaults *Defaults) (*RawFile, error) {
 		file.RetentionAge = cfg.Duration("retention-age")
 	}
 
+	// grandfather-father-son buckets, see #26
+	if cfg.Has("retention-daily") {
+		file.RetentionDaily = cfg.Uint64("retention-daily")
+	}
+
+	if cfg.Has("retention-weekly") {
+		file.RetentionWeekly = cfg.Uint64("retention-weekly")
+	}
+
+	if cfg.Has("retention-monthly") {
+		file.RetentionMonthly = cfg.Uint64("retention-monthly")
+	}
+
+	if cfg.Has("retention-yearly") {
+		file.RetentionYearly = cfg.Uint64("retention-yearly")
+	}
+
 	return file, nil
 }
 
@@ -169,13 +264,38 @@ func parseDefaults(cfg config.Raw) (*Defaults, error) {
 		return nil, err
 	}
 
+	purge, purgeDryRun := parsePurge(cfg)
+
 	defaults := &Defaults{
-		Schedule:       schedule,
-		Sort:           cfg.String("sort"),
-		RetentionCount: cfg.Uint64("retention-count"),
-		RetentionAge:   cfg.Duration("retention-age"),
-		Purge:          cfg.Bool("purge"),
+		Schedule:             schedule,
+		ScheduleExpression:   cronExprString,
+		Sort:                 cfg.String("sort"),
+		RetentionCount:       cfg.Uint64("retention-count"),
+		RetentionAge:         cfg.Duration("retention-age"),
+		RetentionDaily:       cfg.Uint64("retention-daily"),
+		RetentionWeekly:      cfg.Uint64("retention-weekly"),
+		RetentionMonthly:     cfg.Uint64("retention-monthly"),
+		RetentionYearly:      cfg.Uint64("retention-yearly"),
+		Purge:                purge,
+		PurgeDryRun:          purgeDryRun,
+		PurgeMaxFiles:        cfg.Uint64("purge-max-files"),
+		PurgeMaxBytesPercent: cfg.Float64("purge-max-bytes-percent"),
+		PurgeAction:          cfg.String("purge-action"),
+		PurgeTarget:          cfg.String("purge-target"),
+		PurgeTargetDisk:      cfg.String("purge-target-disk"),
+		ImmutableMode:        cfg.String("immutable-mode"),
+		ImmutableRetention:   cfg.Duration("immutable-retention"),
+		ImmutableLegalHold:   cfg.Bool("immutable-legal-hold"),
 	}
 
 	return defaults, nil
 }
+
+// #27: `purge` is either a boolean or `dry-run`, which only reports the files that would have been purged
+func parsePurge(cfg config.Raw) (purge bool, dryRun bool) {
+	if config.IsDryRun(cfg.String("purge")) {
+		return true, true
+	}
+
+	return cfg.Bool("purge"), false
+}
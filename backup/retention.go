@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+)
+
+// names of the retention buckets, used as metric label values
+const (
+	RetentionBucketLatest  = "latest"
+	RetentionBucketDaily   = "daily"
+	RetentionBucketWeekly  = "weekly"
+	RetentionBucketMonthly = "monthly"
+	RetentionBucketYearly  = "yearly"
+)
+
+// RetentionBucket describes one grandfather-father-son bucket: the newest file of each of the last `Count` periods is kept
+type RetentionBucket struct {
+	Name  string
+	Count uint64
+	// Key returns an identifier of the period the given time belongs to
+	Key func(time.Time) string
+}
+
+// HasRetention returns true if the file definition limits the amount or the age of its files. Without a retention policy,
+// no file is in excess.
+func (file *FileDefinition) HasRetention() bool {
+	return file.RetentionCount > 0 || file.RetentionAge > 0 || len(file.RetentionBuckets()) > 0
+}
+
+// RetentionBuckets returns all configured grandfather-father-son buckets of the file definition, from the shortest to the longest period
+func (file *FileDefinition) RetentionBuckets() []RetentionBucket {
+	var r []RetentionBucket
+
+	if file.RetentionDaily > 0 {
+		r = append(r, RetentionBucket{Name: RetentionBucketDaily, Count: file.RetentionDaily, Key: dailyKey})
+	}
+
+	if file.RetentionWeekly > 0 {
+		r = append(r, RetentionBucket{Name: RetentionBucketWeekly, Count: file.RetentionWeekly, Key: weeklyKey})
+	}
+
+	if file.RetentionMonthly > 0 {
+		r = append(r, RetentionBucket{Name: RetentionBucketMonthly, Count: file.RetentionMonthly, Key: monthlyKey})
+	}
+
+	if file.RetentionYearly > 0 {
+		r = append(r, RetentionBucket{Name: RetentionBucketYearly, Count: file.RetentionYearly, Key: yearlyKey})
+	}
+
+	return r
+}
+
+func dailyKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// weeks are ISO 8601 weeks, starting on Monday
+func weeklyKey(t time.Time) string {
+	year, week := t.UTC().ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+func monthlyKey(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+func yearlyKey(t time.Time) string {
+	return t.UTC().Format("2006")
+}
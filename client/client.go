@@ -0,0 +1,378 @@
+package client
+
+// Typed client of the backmon HTTP API, as described by /api/openapi.json; see #42. Automation, e.g. backup jobs
+// sending heartbeats or scripts triggering rescans, can use it instead of building requests by hand.
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	// e.g. http://localhost:8080
+	BaseURL    string
+	HTTPClient *http.Client
+	// credentials of the HTTP Basic Auth of /api
+	Username string
+	Password string
+	// sent as bearer token, e.g. the heartbeat token, an API token or a JWT of the OIDC issuer; only used if no Basic Auth
+	// credentials are set
+	Token string
+}
+
+// APIError is returned for each response which is not successful. Errors of /api/v2 contain a machine-readable code;
+// for all other endpoints, the message is the plain text body of the response.
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("backmon: %d %s: %s", e.Status, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("backmon: %d: %s", e.Status, e.Message)
+}
+
+// HistoryQuery filters the recorded scans and observed files; zero values are ignored
+type HistoryQuery struct {
+	Environment string
+	Disk        string
+	Directory   string
+	File        string
+	Group       string
+	From        time.Time
+	To          time.Time
+	Limit       int
+}
+
+// AuditQuery filters the purge audit log; zero values are ignored
+type AuditQuery struct {
+	Disk      string
+	Directory string
+	File      string
+	Group     string
+	Action    string
+	Limit     int
+}
+
+// ReportQuery restricts the SLA report; zero values are ignored
+type ReportQuery struct {
+	Environment string
+	Disk        string
+	Directory   string
+	File        string
+	Days        int
+	Grace       time.Duration
+}
+
+// Heartbeat is the optional data sent with a heartbeat of a backup job
+type Heartbeat struct {
+	Duration time.Duration
+	Size     int64
+	// excerpt of the log of the backup job
+	Log string
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Environments returns all environments with the names of their disks
+func (c *Client) Environments(ctx context.Context) ([]*Environment, error) {
+	var r []*Environment
+	return r, c.get(ctx, "/api/v2/environments", nil, &r)
+}
+
+// Disks returns all disks without their directories
+func (c *Client) Disks(ctx context.Context) ([]*Disk, error) {
+	var r []*Disk
+	return r, c.get(ctx, "/api/v2/disks", nil, &r)
+}
+
+func (c *Client) Disk(ctx context.Context, disk string) (*Disk, error) {
+	var r Disk
+	return &r, c.get(ctx, path("/api/v2/disks", disk), nil, &r)
+}
+
+func (c *Client) Directory(ctx context.Context, disk, dir string) (*Directory, error) {
+	var r Directory
+	return &r, c.get(ctx, path("/api/v2/disks", disk, "directories", dir), nil, &r)
+}
+
+func (c *Client) FileDefinition(ctx context.Context, disk, dir, file string) (*FileDefinition, error) {
+	var r FileDefinition
+	return &r, c.get(ctx, path("/api/v2/disks", disk, "directories", dir, "files", file), nil, &r)
+}
+
+// Group returns a group with all of its backup files
+func (c *Client) Group(ctx context.Context, disk, dir, file, group string) (*Group, error) {
+	var r Group
+	return &r, c.get(ctx, path("/api/v2/disks", disk, "directories", dir, "files", file, "groups", group), nil, &r)
+}
+
+// Backups returns all retained backups of a group, newest first
+func (c *Client) Backups(ctx context.Context, disk, dir, file, group string) ([]*File, error) {
+	var r []*File
+	return r, c.get(ctx, path("/api", disk, dir, file, group, "files"), nil, &r)
+}
+
+// DownloadBackup downloads a retained backup of a group by its name or by a timestamp in seconds since the epoch or
+// RFC 3339, which selects the newest backup not younger than it; downloads must be enabled. The caller has to close
+// the returned reader.
+func (c *Client) DownloadBackup(ctx context.Context, disk, dir, file, group, name string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, path("/api", disk, dir, file, group, "files", name), nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, decodeError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// CreateDownloadLink creates a signed link of a backup, found by name or timestamp like DownloadBackup. Without
+// expiresIn, the link expires after the configured default lifetime.
+func (c *Client) CreateDownloadLink(ctx context.Context, disk, dir, file, group, name string, expiresIn time.Duration) (*DownloadLink, error) {
+	query := url.Values{}
+
+	if expiresIn > 0 {
+		query.Set("expires_in", expiresIn.String())
+	}
+
+	var r DownloadLink
+	return &r, c.do(ctx, http.MethodPost, path("/api", disk, dir, file, group, "files", name, "link"), query, nil, &r)
+}
+
+// Rescan triggers a scan of all disks, of all disks of an environment or of a single disk, depending on which of env
+// and disk are empty. With wait, it blocks until the scan has finished and returns its results; otherwise, the results
+// are nil.
+func (c *Client) Rescan(ctx context.Context, env, disk string, wait bool) ([]*Scan, error) {
+	target := "/api/rescan"
+
+	if env != "" {
+		target = path(target, env)
+
+		if disk != "" {
+			target = path(target, disk)
+		}
+	}
+
+	query := url.Values{}
+
+	if !wait {
+		return nil, c.do(ctx, http.MethodPost, target, query, nil, nil)
+	}
+
+	var r []*Scan
+	query.Set("wait", "true")
+	return r, c.do(ctx, http.MethodPost, target, query, nil, &r)
+}
+
+// SendHeartbeat reports the start (HeartbeatStart), success (HeartbeatSuccess) or failure (HeartbeatFail) of a backup
+// job. hb may be nil.
+func (c *Client) SendHeartbeat(ctx context.Context, disk, dir, file, group, kind string, hb *Heartbeat) (*JobStatus, error) {
+	query := url.Values{}
+	var body io.Reader
+
+	if hb != nil {
+		if hb.Duration > 0 {
+			query.Set("duration", hb.Duration.String())
+		}
+
+		if hb.Size > 0 {
+			query.Set("size", strconv.FormatInt(hb.Size, 10))
+		}
+
+		if hb.Log != "" {
+			body = strings.NewReader(hb.Log)
+		}
+	}
+
+	var r JobStatus
+	return &r, c.do(ctx, http.MethodPost, path("/api/heartbeats", disk, dir, file, group, kind), query, body, &r)
+}
+
+// Jobs returns the latest heartbeats of all backup jobs
+func (c *Client) Jobs(ctx context.Context) ([]*JobStatus, error) {
+	var r []*JobStatus
+	return r, c.get(ctx, "/api/heartbeats", nil, &r)
+}
+
+// Scans returns the recorded scans; fails with 404 if the history is disabled
+func (c *Client) Scans(ctx context.Context, q HistoryQuery) ([]*Scan, error) {
+	var r []*Scan
+	return r, c.get(ctx, "/api/history/scans", q.values(), &r)
+}
+
+// Observations returns the backup files which have existed in the time range of the query; fails with 404 if the
+// history is disabled
+func (c *Client) Observations(ctx context.Context, q HistoryQuery) ([]*Observation, error) {
+	var r []*Observation
+	return r, c.get(ctx, "/api/history/files", q.values(), &r)
+}
+
+func (c *Client) Audit(ctx context.Context, q AuditQuery) ([]*AuditEntry, error) {
+	query := url.Values{}
+	setNonEmpty(query, "disk", q.Disk)
+	setNonEmpty(query, "dir", q.Directory)
+	setNonEmpty(query, "file", q.File)
+	setNonEmpty(query, "group", q.Group)
+	setNonEmpty(query, "action", q.Action)
+
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var r []*AuditEntry
+	return r, c.get(ctx, "/api/audit", query, &r)
+}
+
+// Report returns the SLA report; fails with 404 if the history is disabled
+func (c *Client) Report(ctx context.Context, q ReportQuery) (*Report, error) {
+	query := url.Values{"format": {"json"}}
+	setNonEmpty(query, "env", q.Environment)
+	setNonEmpty(query, "disk", q.Disk)
+	setNonEmpty(query, "dir", q.Directory)
+	setNonEmpty(query, "file", q.File)
+
+	if q.Days > 0 {
+		query.Set("days", strconv.Itoa(q.Days))
+	}
+
+	if q.Grace > 0 {
+		query.Set("grace", q.Grace.String())
+	}
+
+	var r Report
+	return &r, c.get(ctx, "/api/report", query, &r)
+}
+
+func (q HistoryQuery) values() url.Values {
+	r := url.Values{}
+	setNonEmpty(r, "env", q.Environment)
+	setNonEmpty(r, "disk", q.Disk)
+	setNonEmpty(r, "dir", q.Directory)
+	setNonEmpty(r, "file", q.File)
+	setNonEmpty(r, "group", q.Group)
+
+	if !q.From.IsZero() {
+		r.Set("from", q.From.Format(time.RFC3339))
+	}
+
+	if !q.To.IsZero() {
+		r.Set("to", q.To.Format(time.RFC3339))
+	}
+
+	if q.Limit > 0 {
+		r.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	return r
+}
+
+func setNonEmpty(values url.Values, key string, value string) {
+	if value != "" {
+		values.Set(key, value)
+	}
+}
+
+// path joins the escaped segments to the prefix
+func path(prefix string, segments ...string) string {
+	r := prefix
+
+	for _, segment := range segments {
+		r += "/" + url.PathEscape(segment)
+	}
+
+	return r
+}
+
+func (c *Client) get(ctx context.Context, target string, query url.Values, result any) error {
+	return c.do(ctx, http.MethodGet, target, query, nil, result)
+}
+
+func (c *Client) do(ctx context.Context, method string, target string, query url.Values, body io.Reader, result any) error {
+	resp, err := c.send(ctx, method, target, query, body)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+
+	if result == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c *Client) send(ctx context.Context, method string, target string, query url.Values, body io.Reader) (*http.Response, error) {
+	u := c.BaseURL + target
+
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	} else if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return httpClient.Do(req)
+}
+
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var structured struct {
+		Error *APIError `json:"error"`
+	}
+
+	if json.Unmarshal(data, &structured) == nil && structured.Error != nil {
+		return structured.Error
+	}
+
+	return &APIError{
+		Status:  resp.StatusCode,
+		Message: strings.TrimSpace(string(data)),
+	}
+}
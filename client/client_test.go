@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH42_Client_Group_escapesPathAndDecodesResource(t *testing.T) {
+	assertion := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal("/api/v2/disks/my%2Fdisk/directories/dumps/files/pgdump/groups/db", r.URL.EscapedPath())
+
+		username, password, ok := r.BasicAuth()
+		assertion.True(ok)
+		assertion.Equal("admin", username)
+		assertion.Equal("secret", password)
+
+		_, _ = w.Write([]byte(`{"name":"db","health":"late","file_count":2,"young_count":1,"latest":{"name":"db-1.sql","size":42}}`))
+	}))
+	defer server.Close()
+
+	sut := New(server.URL + "/")
+	sut.Username = "admin"
+	sut.Password = "secret"
+
+	group, err := sut.Group(context.Background(), "my/disk", "dumps", "pgdump", "db")
+
+	assertion.NoError(err)
+	assertion.Equal(HealthLate, group.Health)
+	assertion.Equal(2, group.FileCount)
+	assertion.Equal(int64(42), group.Latest.Size)
+}
+
+func Test_GH42_Client_SendHeartbeat_sendsTokenAndLog(t *testing.T) {
+	assertion := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal(http.MethodPost, r.Method)
+		assertion.Equal("/api/heartbeats/disk/dumps/pgdump/db/success", r.URL.Path)
+		assertion.Equal("Bearer token", r.Header.Get("Authorization"))
+		assertion.Equal("1m30s", r.URL.Query().Get("duration"))
+		assertion.Equal("1024", r.URL.Query().Get("size"))
+
+		body, _ := io.ReadAll(r.Body)
+		assertion.Equal("done", string(body))
+
+		_, _ = w.Write([]byte(`{"status":"success","duration_seconds":90,"size":1024}`))
+	}))
+	defer server.Close()
+
+	sut := New(server.URL)
+	sut.Token = "token"
+
+	job, err := sut.SendHeartbeat(context.Background(), "disk", "dumps", "pgdump", "db", HeartbeatSuccess, &Heartbeat{
+		Duration: 90 * time.Second,
+		Size:     1024,
+		Log:      "done",
+	})
+
+	assertion.NoError(err)
+	assertion.Equal(HeartbeatSuccess, job.Status)
+	assertion.Equal(float64(90), job.DurationSeconds)
+}
+
+func Test_GH42_Client_Rescan_withoutWaitIgnoresBody(t *testing.T) {
+	assertion := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal("/api/rescan/prod", r.URL.Path)
+		assertion.False(r.URL.Query().Has("wait"))
+
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"accepted"}`))
+	}))
+	defer server.Close()
+
+	scans, err := New(server.URL).Rescan(context.Background(), "prod", "", false)
+
+	assertion.NoError(err)
+	assertion.Nil(scans)
+}
+
+func Test_GH42_Client_decodesErrors(t *testing.T) {
+	assertion := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/disks/unknown" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":404,"code":"disk_not_found","message":"disk not found"}}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("History is disabled.\n"))
+	}))
+	defer server.Close()
+
+	sut := New(server.URL)
+	var apiError *APIError
+
+	_, err := sut.Disk(context.Background(), "unknown")
+	assertion.True(errors.As(err, &apiError))
+	assertion.Equal("disk_not_found", apiError.Code)
+
+	_, err = sut.Scans(context.Background(), HistoryQuery{Limit: 10})
+	assertion.True(errors.As(err, &apiError))
+	assertion.Equal(http.StatusNotFound, apiError.Status)
+	assertion.Equal("History is disabled.", apiError.Message)
+}
+
+func Test_GH45_Client_DownloadBackup_streamsFileOfGroup(t *testing.T) {
+	assertion := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/disk/dumps/pgdump/db/files/2024-03-29T02:00:00Z" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("backup does not exist: 'dump-4.sql'"))
+			return
+		}
+
+		_, _ = w.Write([]byte("-- dump"))
+	}))
+	defer server.Close()
+
+	sut := New(server.URL)
+
+	reader, err := sut.DownloadBackup(context.Background(), "disk", "dumps", "pgdump", "db", "2024-03-29T02:00:00Z")
+	assertion.NoError(err)
+
+	data, _ := io.ReadAll(reader)
+	_ = reader.Close()
+	assertion.Equal("-- dump", string(data))
+
+	_, err = sut.DownloadBackup(context.Background(), "disk", "dumps", "pgdump", "db", "dump-4.sql")
+	var apiError *APIError
+	assertion.True(errors.As(err, &apiError))
+	assertion.Equal(http.StatusNotFound, apiError.Status)
+}
+
+func Test_GH47_Client_CreateDownloadLink_sendsExpiry(t *testing.T) {
+	assertion := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion.Equal(http.MethodPost, r.Method)
+		assertion.Equal("/api/disk/dumps/pgdump/db/files/dump-1.sql/link", r.URL.Path)
+		assertion.Equal("2h0m0s", r.URL.Query().Get("expires_in"))
+
+		_, _ = w.Write([]byte(`{"url":"https://backmon.example.com/download/disk/dumps/pgdump/db/dump-1.sql?expires=1711893600&signature=ab","expires_at":"2024-03-31T14:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	link, err := New(server.URL).CreateDownloadLink(context.Background(), "disk", "dumps", "pgdump", "db", "dump-1.sql", 2*time.Hour)
+
+	assertion.NoError(err)
+	assertion.Equal(time.Date(2024, 3, 31, 14, 0, 0, 0, time.UTC), link.ExpiresAt)
+	assertion.Contains(link.URL, "signature=ab")
+}
@@ -0,0 +1,184 @@
+package client
+
+import "time"
+
+// health of groups, file definitions, directories and disks, ordered by severity
+const (
+	HealthOk                 = "ok"
+	HealthLate               = "late"
+	HealthUnconfirmed        = "unconfirmed"
+	HealthFailed             = "failed"
+	HealthMissing            = "missing"
+	HealthDefinitionsMissing = "definitions_missing"
+)
+
+// kinds of heartbeats sent by backup jobs
+const (
+	HeartbeatStart   = "start"
+	HeartbeatSuccess = "success"
+	HeartbeatFail    = "fail"
+)
+
+type Environment struct {
+	Name  string   `json:"name"`
+	Disks []string `json:"disks"`
+}
+
+type Disk struct {
+	Environment string       `json:"environment"`
+	Name        string       `json:"name"`
+	Health      string       `json:"health"`
+	Quota       uint64       `json:"quota"`
+	LastScan    *Scan        `json:"last_scan"`
+	Directories []*Directory `json:"directories,omitempty"`
+}
+
+type Directory struct {
+	Alias     string            `json:"alias"`
+	Pattern   string            `json:"pattern"`
+	Variables []string          `json:"variables"`
+	Health    string            `json:"health"`
+	Files     []*FileDefinition `json:"files,omitempty"`
+}
+
+type Retention struct {
+	Count      uint64  `json:"count"`
+	AgeSeconds float64 `json:"age_seconds"`
+	Daily      uint64  `json:"daily"`
+	Weekly     uint64  `json:"weekly"`
+	Monthly    uint64  `json:"monthly"`
+	Yearly     uint64  `json:"yearly"`
+}
+
+type FileDefinition struct {
+	Alias       string    `json:"alias"`
+	Pattern     string    `json:"pattern"`
+	Schedule    string    `json:"schedule"`
+	ExpectedAt  time.Time `json:"expected_at"`
+	NextRunAt   time.Time `json:"next_run_at"`
+	Retention   Retention `json:"retention"`
+	Purge       bool      `json:"purge"`
+	PurgeAction string    `json:"purge_action"`
+	Health      string    `json:"health"`
+	Groups      []*Group  `json:"groups,omitempty"`
+}
+
+type Group struct {
+	Name       string  `json:"name"`
+	Health     string  `json:"health"`
+	FileCount  int     `json:"file_count"`
+	YoungCount uint64  `json:"young_count"`
+	Latest     *File   `json:"latest"`
+	Files      []*File `json:"files,omitempty"`
+}
+
+type File struct {
+	Name           string    `json:"name"`
+	Parent         string    `json:"parent"`
+	Size           int64     `json:"size"`
+	SortTime       time.Time `json:"sort_time"`
+	BornAt         time.Time `json:"born_at"`
+	ModifiedAt     time.Time `json:"modified_at"`
+	ArchivedAt     time.Time `json:"archived_at"`
+	InterpolatedAt time.Time `json:"interpolated_at,omitempty"`
+	Checksum       string    `json:"checksum,omitempty"`
+	StorageClass   string    `json:"storage_class,omitempty"`
+}
+
+type DefinitionUsage struct {
+	Directory string `json:"directory"`
+	File      string `json:"file"`
+	Files     uint64 `json:"files"`
+	Bytes     uint64 `json:"bytes"`
+}
+
+type Scan struct {
+	Time            time.Time          `json:"time"`
+	Environment     string             `json:"environment"`
+	Disk            string             `json:"disk"`
+	DurationSeconds float64            `json:"duration_seconds"`
+	Files           uint64             `json:"files"`
+	Bytes           uint64             `json:"bytes"`
+	Error           string             `json:"error,omitempty"`
+	Definitions     []*DefinitionUsage `json:"definitions,omitempty"`
+}
+
+type Observation struct {
+	Environment   string    `json:"environment"`
+	Disk          string    `json:"disk"`
+	Directory     string    `json:"directory"`
+	File          string    `json:"file"`
+	Group         string    `json:"group"`
+	Name          string    `json:"name"`
+	Parent        string    `json:"parent"`
+	Size          int64     `json:"size"`
+	SortTime      time.Time `json:"sort_time"`
+	BornAt        time.Time `json:"born_at"`
+	ModifiedAt    time.Time `json:"modified_at"`
+	ArchivedAt    time.Time `json:"archived_at"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+	DisappearedAt time.Time `json:"disappeared_at,omitempty"`
+}
+
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	DryRun      bool      `json:"dry_run"`
+	Environment string    `json:"environment"`
+	Disk        string    `json:"disk"`
+	Directory   string    `json:"directory"`
+	File        string    `json:"file"`
+	Group       string    `json:"group"`
+	Name        string    `json:"name"`
+	Parent      string    `json:"parent"`
+	Size        int64     `json:"size"`
+	SortTime    time.Time `json:"sort_time"`
+	Target      string    `json:"target,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type JobStatus struct {
+	Environment     string    `json:"environment"`
+	Disk            string    `json:"disk"`
+	Directory       string    `json:"directory"`
+	File            string    `json:"file"`
+	Group           string    `json:"group"`
+	Status          string    `json:"status"`
+	LastStart       time.Time `json:"last_start"`
+	LastSuccess     time.Time `json:"last_success"`
+	LastFailure     time.Time `json:"last_failure"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Size            int64     `json:"size"`
+	Log             string    `json:"log,omitempty"`
+	Unconfirmed     bool      `json:"unconfirmed"`
+}
+
+type Report struct {
+	From time.Time    `json:"from"`
+	To   time.Time    `json:"to"`
+	Rows []*ReportRow `json:"rows"`
+}
+
+type ReportRow struct {
+	Environment          string    `json:"environment"`
+	Disk                 string    `json:"disk"`
+	Directory            string    `json:"directory"`
+	File                 string    `json:"file"`
+	Groups               int       `json:"groups"`
+	From                 time.Time `json:"from"`
+	ExpectedRuns         int       `json:"expected_runs"`
+	OnTimeRuns           int       `json:"on_time_runs"`
+	LateRuns             int       `json:"late_runs"`
+	MissingRuns          int       `json:"missing_runs"`
+	OnTimeRate           *float64  `json:"on_time_rate"`
+	WorstLatenessSeconds float64   `json:"worst_lateness_seconds"`
+	AverageSize          float64   `json:"average_size_bytes"`
+	GrowthPercent        float64   `json:"growth_percent"`
+}
+
+// DownloadLink a signed link which downloads a backup without credentials until it expires
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
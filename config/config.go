@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -17,6 +21,9 @@ type Configuration struct {
 	http         *HttpConfiguration
 	downloads    *DownloadsConfiguration
 	environments []*EnvironmentConfiguration
+	replicas     []*ReplicaConfiguration
+	costs        *CostsConfiguration
+	notify       *NotificationsConfiguration
 }
 
 var (
@@ -27,6 +34,11 @@ var (
 	isRunningInBackgroundForced bool
 )
 
+const (
+	// PurgeDryRun value of `purge` to only report purge candidates
+	PurgeDryRun = "dry-run"
+)
+
 const (
 	CfgFileName = "config.yaml"
 	PathLocal   = "."
@@ -88,6 +100,20 @@ func (c *Configuration) Http() *HttpConfiguration {
 	return c.http
 }
 
+func (c *Configuration) Replicas() []*ReplicaConfiguration {
+	return c.replicas
+}
+
+// Notifications Return the built-in alerting; nil if no channels have been configured
+func (c *Configuration) Notifications() *NotificationsConfiguration {
+	return c.notify
+}
+
+// Costs Return the price tables; nil if no costs have been configured
+func (c *Configuration) Costs() *CostsConfiguration {
+	return c.costs
+}
+
 // CreateFromConfigurationFiles Create a new configuration from default configuration files
 func CreateFromConfigurationFiles() *Configuration {
 	var file *os.File = nil
@@ -134,12 +160,18 @@ func NewConfigurationInstance(cfg Raw) *Configuration {
 	var httpConfiguration = parseHttpSection(cfg.Sub("http"))
 	var downloadsConfiguration = parseDownloadsSection(cfg.Sub("downloads"))
 	var environmentsConfiguration = parseEnvironmentsSection(cfg.Sub("environments"))
+	var replicasConfiguration = parseReplicasSection(cfg.Sub("replicas"))
+	var costsConfiguration = parseCostsSection(cfg.Sub("costs"))
+	var notificationsConfiguration = parseNotificationsSection(cfg.Sub("notifications"))
 
 	r = &Configuration{
 		global:       globalConfiguration,
 		http:         httpConfiguration,
 		downloads:    downloadsConfiguration,
 		environments: environmentsConfiguration,
+		replicas:     replicasConfiguration,
+		costs:        costsConfiguration,
+		notify:       notificationsConfiguration,
 	}
 
 	return r
@@ -234,13 +266,139 @@ func parseHttpSection(cfg Raw) *HttpConfiguration {
 	log.Infof("Using TLS: %t", tlsConfiguration != nil)
 
 	r = &HttpConfiguration{
-		BasicAuth: basicAuthConfiguration,
-		Tls:       tlsConfiguration,
+		BasicAuth:   basicAuthConfiguration,
+		Tls:         tlsConfiguration,
+		Users:       parseCredentials(cfg.Sub("users"), "password"),
+		Tokens:      parseCredentials(cfg.Sub("tokens"), "hash"),
+		MetricsAuth: cfg.Bool("metrics_auth"),
 	}
 
+	log.Infof("Using %d user(s) and %d API token(s)", len(r.Users), len(r.Tokens))
+
+	if cfg.Has("oidc") {
+		oidc, err := parseOidcSection(cfg.Sub("oidc"))
+
+		if err != nil {
+			log.Errorf("OIDC could not be configured: %s", err)
+		} else {
+			r.Oidc = oidc
+		}
+	}
+
+	log.Infof("Using OIDC: %t", r.Oidc != nil)
+
 	return r
 }
 
+// Parses the `users:` or `tokens:` section, ordered by name; see #48
+func parseCredentials(cfg Raw, paramHash string) []*CredentialConfiguration {
+	names := make([]string, 0, len(cfg))
+
+	for name := range cfg {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	r := make([]*CredentialConfiguration, 0, len(names))
+
+	for _, name := range names {
+		credential := cfg.Sub(name)
+
+		if credential.String(paramHash) == "" {
+			log.Errorf("Credential '%s' has no '%s', ignoring it", name, paramHash)
+			continue
+		}
+
+		r = append(r, &CredentialConfiguration{
+			Name:         name,
+			Hash:         credential.String(paramHash),
+			Role:         credential.String("role"),
+			Environments: credential.StringSlice("environments"),
+			Disks:        credential.StringSlice("disks"),
+		})
+	}
+
+	return r
+}
+
+// Parses the `oidc:` section; see #49
+func parseOidcSection(cfg Raw) (*OidcConfiguration, error) {
+	r := &OidcConfiguration{
+		Issuer:           cfg.String("issuer"),
+		Audiences:        cfg.StringSlice("audience"),
+		JwksFile:         cfg.String("jwks_file"),
+		JwksUrl:          cfg.String("jwks_url"),
+		UsernameClaim:    "preferred_username",
+		DefaultRole:      cfg.String("default_role"),
+		Login:            cfg.String("client_secret") != "",
+		ClientId:         cfg.String("client_id"),
+		ClientSecret:     cfg.String("client_secret"),
+		RedirectUrl:      cfg.String("redirect_url"),
+		Scopes:           []string{"openid", "profile", "email"},
+		AuthorizationUrl: cfg.String("authorization_url"),
+		TokenUrl:         cfg.String("token_url"),
+	}
+
+	if r.Issuer == "" {
+		return nil, errors.New("'issuer' is required")
+	}
+
+	if r.Audiences == nil && cfg.String("audience") != "" {
+		r.Audiences = []string{cfg.String("audience")}
+	}
+
+	if r.Audiences == nil && r.ClientId != "" {
+		r.Audiences = []string{r.ClientId}
+	}
+
+	if len(r.Audiences) == 0 {
+		return nil, errors.New("either 'audience' or 'client_id' is required")
+	}
+
+	if cfg.Has("username_claim") {
+		r.UsernameClaim = cfg.String("username_claim")
+	}
+
+	// public clients can sign in without a secret
+	if cfg.Has("login") {
+		r.Login = cfg.Bool("login")
+	}
+
+	if r.Login && r.ClientId == "" {
+		return nil, errors.New("'client_id' is required for the login")
+	}
+
+	if cfg.Has("scopes") {
+		r.Scopes = cfg.StringSlice("scopes")
+	}
+
+	roles, _ := cfg["roles"].([]interface{})
+
+	for i, role := range roles {
+		roleCfg, ok := role.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("role mapping %d must be a map", i)
+		}
+
+		mapping := Raw(roleCfg)
+
+		r.Roles = append(r.Roles, &OidcRoleMapping{
+			Claim:        "groups",
+			Value:        mapping.String("value"),
+			Role:         mapping.String("role"),
+			Environments: mapping.StringSlice("environments"),
+			Disks:        mapping.StringSlice("disks"),
+		})
+
+		if mapping.Has("claim") {
+			r.Roles[i].Claim = mapping.String("claim")
+		}
+	}
+
+	return r, nil
+}
+
 func parseBasicAuthConfiguration(cfg Raw) *BasicAuthConfiguration {
 	var r *BasicAuthConfiguration
 
@@ -287,9 +445,63 @@ func parseTlsConfiguration(cfg Raw) *TlsConfiguration {
 		}
 	}
 
+	if r != nil && cfg.String("client_ca") != "" {
+		// #50: an invalid configuration must not fall back to accepting requests without client certificates
+		if err := parseClientAuth(cfg, r); err != nil {
+			log.Fatalf("Client certificates could not be configured: %s", err)
+		}
+	}
+
 	return r
 }
 
+// Parses the client certificate authentication of the `tls:` section; see #50
+func parseClientAuth(cfg Raw, tls *TlsConfiguration) error {
+	clientAuth := ClientAuthVerify
+
+	if cfg.Has("client_auth") {
+		clientAuth = cfg.String("client_auth")
+	}
+
+	switch clientAuth {
+	case ClientAuthRequest, ClientAuthRequire, ClientAuthVerify:
+	default:
+		return fmt.Errorf("unknown client_auth '%s', must be one of request, require or verify", clientAuth)
+	}
+
+	clients, _ := cfg["clients"].([]interface{})
+	var mappings []*ClientCertificateConfiguration
+
+	for i, client := range clients {
+		clientCfg, ok := client.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("client %d must be a map", i)
+		}
+
+		mapping := Raw(clientCfg)
+
+		if mapping.String("subject") == "" && mapping.String("san") == "" {
+			return fmt.Errorf("client %d requires either a 'subject' or a 'san'", i)
+		}
+
+		mappings = append(mappings, &ClientCertificateConfiguration{
+			Subject:      mapping.String("subject"),
+			San:          mapping.String("san"),
+			Role:         mapping.String("role"),
+			Environments: mapping.StringSlice("environments"),
+			Disks:        mapping.StringSlice("disks"),
+		})
+	}
+
+	tls.ClientCaPath = cfg.String("client_ca")
+	tls.ClientAuth = clientAuth
+	tls.Clients = mappings
+
+	log.Infof("Using client certificates (%s) with %d mapping(s)", clientAuth, len(mappings))
+
+	return nil
+}
+
 func parseDownloadsSection(cfg Raw) *DownloadsConfiguration {
 	var r *DownloadsConfiguration
 
@@ -303,7 +515,25 @@ func parseDownloadsSection(cfg Raw) *DownloadsConfiguration {
 	log.Infof("Downloads enabled: %t", enabled)
 
 	r = &DownloadsConfiguration{
-		Enabled: enabled,
+		Enabled:       enabled,
+		SigningKey:    cfg.String("signing_key"),
+		LinkExpiry:    time.Hour,
+		MaxLinkExpiry: 24 * time.Hour,
+		S3Redirect:    cfg.Bool("s3_redirect"),
+	}
+
+	// #47
+	if cfg.Has("link_expiry") {
+		r.LinkExpiry = cfg.Duration("link_expiry")
+	}
+
+	if cfg.Has("max_link_expiry") {
+		r.MaxLinkExpiry = cfg.Duration("max_link_expiry")
+	}
+
+	if r.LinkExpiry > r.MaxLinkExpiry {
+		log.Warnf("Parameter 'link_expiry' must not exceed 'max_link_expiry', using %s", r.MaxLinkExpiry)
+		r.LinkExpiry = r.MaxLinkExpiry
 	}
 
 	return r
@@ -341,13 +571,429 @@ func parseGlobalSection(cfg Raw) *GlobalConfiguration {
 		updateInterval = time.Hour
 	}
 
+	// #27: `purge: dry-run` only reports purge candidates, regardless of the definitions
+	purgeDryRun := false
+	if cfg.Has("purge") {
+		purgeDryRun = IsDryRun(cfg.String("purge"))
+
+		if !purgeDryRun {
+			log.Warnf("Unknown value '%s' for 'purge', only 'dry-run' is supported", cfg.String("purge"))
+		}
+	}
+
+	log.Infof("Purge dry-run enforced: %t", purgeDryRun)
+
 	return &GlobalConfiguration{
 		logLevel:       logLevel,
 		httpPort:       httpPort,
 		updateInterval: updateInterval,
+		purgeDryRun:    purgeDryRun,
+		purgeAuditLog:  cfg.String("purge_audit_log"),
+		purgeGuards:    parsePurgeGuardsSection(cfg.Sub("purge_guards")),
+		anomalies:      parseAnomaliesSection(cfg.Sub("anomalies")),
+		history:        parseHistorySection(cfg.Sub("history")),
+		forecast:       parseForecastSection(cfg.Sub("forecast")),
+		heartbeats:     parseHeartbeatsSection(cfg.Sub("heartbeats")),
 	}
 }
 
+func parsePurgeGuardsSection(cfg Raw) *PurgeGuardsConfiguration {
+	const paramMaxFiles = "max_files"
+	const paramMaxBytesPercent = "max_bytes_percent"
+	const paramDefinitionsGrace = "definitions_grace"
+
+	r := &PurgeGuardsConfiguration{
+		MaxFiles:         cfg.Uint64(paramMaxFiles),
+		MaxBytesPercent:  cfg.Float64(paramMaxBytesPercent),
+		DefinitionsGrace: cfg.Duration(paramDefinitionsGrace),
+	}
+
+	if r.MaxBytesPercent < 0 || r.MaxBytesPercent > 100 {
+		log.Warnf("Parameter '%s' must be between 0 and 100, ignoring it", paramMaxBytesPercent)
+		r.MaxBytesPercent = 0
+	}
+
+	return r
+}
+
+// Parses `anomalies:` section; see #32
+func parseAnomaliesSection(cfg Raw) *AnomaliesConfiguration {
+	const paramModifiedFiles = "modified_files"
+	const paramDeletedFiles = "deleted_files"
+	const paramShrunkFiles = "shrunk_files"
+	const paramRenamedFiles = "renamed_files"
+	const paramEntropy = "entropy"
+	const paramSampleSize = "sample_size"
+	const paramThreshold = "threshold"
+	const paramCompressibleExtensions = "compressible_extensions"
+
+	// #32: the heuristics are opt-in, as the thresholds depend on how the backups are written
+	r := &AnomaliesConfiguration{
+		ModifiedFiles:          cfg.Uint64(paramModifiedFiles),
+		DeletedFiles:           cfg.Uint64(paramDeletedFiles),
+		ShrunkFiles:            cfg.Uint64(paramShrunkFiles),
+		RenamedFiles:           cfg.Uint64(paramRenamedFiles),
+		EntropyThreshold:       7.5,
+		CompressibleExtensions: []string{".sql", ".csv", ".txt", ".json", ".xml", ".log", ".tar", ".ldif"},
+	}
+
+	entropy := cfg.Sub(paramEntropy)
+	r.EntropySampleSize = entropy.Bytes(paramSampleSize)
+
+	if entropy.Has(paramThreshold) {
+		r.EntropyThreshold = entropy.Float64(paramThreshold)
+	}
+
+	if r.EntropyThreshold <= 0 || r.EntropyThreshold > 8 {
+		log.Warnf("Parameter '%s' of '%s' must be between 0 and 8 bits per byte, defaulting to 7.5", paramThreshold, paramEntropy)
+		r.EntropyThreshold = 7.5
+	}
+
+	if entropy.Has(paramCompressibleExtensions) {
+		r.CompressibleExtensions = entropy.StringSlice(paramCompressibleExtensions)
+	}
+
+	return r
+}
+
+// Parses `history:` section; see #33
+func parseHistorySection(cfg Raw) *HistoryConfiguration {
+	r := &HistoryConfiguration{
+		Path:      cfg.String("path"),
+		Retention: Year,
+	}
+
+	if cfg.Has("retention") {
+		r.Retention = cfg.Duration("retention")
+	}
+
+	if r.Retention <= 0 {
+		log.Warn("History retention must be positive, defaulting to 1 year.")
+		r.Retention = Year
+	}
+
+	return r
+}
+
+// Parses `forecast:` section; see #35
+func parseForecastSection(cfg Raw) *ForecastConfiguration {
+	r := &ForecastConfiguration{
+		Window:  Month,
+		Horizon: Month,
+	}
+
+	if cfg.Has("window") {
+		r.Window = cfg.Duration("window")
+	}
+
+	if cfg.Has("horizon") {
+		r.Horizon = cfg.Duration("horizon")
+	}
+
+	if r.Window < Day {
+		log.Warn("Forecast window must not be less than 1 day, defaulting to 30 days.")
+		r.Window = Month
+	}
+
+	return r
+}
+
+// Parses `heartbeats:` section; see #38
+func parseHeartbeatsSection(cfg Raw) *HeartbeatsConfiguration {
+	r := &HeartbeatsConfiguration{
+		Token:      cfg.String("token"),
+		Grace:      15 * time.Minute,
+		MaxLogSize: 10 * 1024,
+	}
+
+	if cfg.Has("grace") {
+		r.Grace = cfg.Duration("grace")
+	}
+
+	if cfg.Has("max_log_size") {
+		r.MaxLogSize = cfg.Bytes("max_log_size")
+	}
+
+	return r
+}
+
+// Parses `costs:` section; see #36
+func parseCostsSection(cfg Raw) *CostsConfiguration {
+	if len(cfg) == 0 {
+		return nil
+	}
+
+	r := &CostsConfiguration{
+		Currency:     "USD",
+		Providers:    parsePriceTables(cfg.Sub("prices")),
+		Environments: parsePriceTables(cfg.Sub("environments")),
+	}
+
+	if cfg.Has("currency") {
+		r.Currency = cfg.String("currency")
+	}
+
+	for provider := range r.Providers {
+		if provider != ProviderS3 && provider != ProviderLocal {
+			log.Warnf("Unknown provider '%s' in costs, only '%s' and '%s' are supported", provider, ProviderS3, ProviderLocal)
+		}
+	}
+
+	return r
+}
+
+func parsePriceTables(cfg Raw) map[string]map[string]float64 {
+	r := make(map[string]map[string]float64, len(cfg))
+
+	for name := range cfg {
+		prices := cfg.Sub(name)
+		table := make(map[string]float64, len(prices))
+
+		for storageClass := range prices {
+			table[strings.ToUpper(storageClass)] = prices.Float64(storageClass)
+		}
+
+		r[name] = table
+	}
+
+	return r
+}
+
+// Parses `notifications:` section; see #37
+func parseNotificationsSection(cfg Raw) *NotificationsConfiguration {
+	channels := cfg.Sub("channels")
+
+	if len(channels) == 0 {
+		return nil
+	}
+
+	r := &NotificationsConfiguration{
+		RepeatInterval: 4 * Hour,
+		SendResolved:   true,
+		Channels:       make(map[string]*ChannelConfiguration),
+	}
+
+	if cfg.Has("repeat_interval") {
+		r.RepeatInterval = cfg.Duration("repeat_interval")
+	}
+
+	if cfg.Has("send_resolved") {
+		r.SendResolved = cfg.Bool("send_resolved")
+	}
+
+	for name := range channels {
+		channel, err := parseChannel(name, channels.Sub(name))
+
+		if err != nil {
+			log.Errorf("Notification channel '%s' could not be parsed: %s", name, err)
+			continue
+		}
+
+		r.Channels[name] = channel
+	}
+
+	routes, _ := cfg["routes"].([]interface{})
+
+	for i, route := range routes {
+		routeCfg, ok := route.(map[string]interface{})
+		if !ok {
+			log.Errorf("Notification route %d must be a map", i)
+			continue
+		}
+
+		parsed, err := parseRoute(routeCfg, r.Channels)
+		if err != nil {
+			log.Errorf("Notification route %d could not be parsed: %s", i, err)
+			continue
+		}
+
+		r.Routes = append(r.Routes, parsed)
+	}
+
+	return r
+}
+
+func parseChannel(name string, cfg Raw) (*ChannelConfiguration, error) {
+	r := &ChannelConfiguration{
+		Name:     name,
+		Type:     cfg.String("type"),
+		URL:      cfg.String("url"),
+		Headers:  make(map[string]string),
+		Method:   "POST",
+		Template: cfg.String("template"),
+		Host:     cfg.String("host"),
+		Port:     587,
+		Username: cfg.String("username"),
+		Password: cfg.String("password"),
+		From:     cfg.String("from"),
+		To:       cfg.StringSlice("to"),
+		Token:    cfg.String("token"),
+		Priority: cfg.String("priority"),
+	}
+
+	if cfg.Has("method") {
+		r.Method = strings.ToUpper(cfg.String("method"))
+	}
+
+	if cfg.Has("port") {
+		r.Port = int(cfg.Int64("port"))
+	}
+
+	if r.To == nil && cfg.Has("to") {
+		r.To = []string{cfg.String("to")}
+	}
+
+	headers := cfg.Sub("headers")
+	for header := range headers {
+		r.Headers[header] = headers.String(header)
+	}
+
+	if r.Template != "" {
+		if _, err := template.New(name).Parse(r.Template); err != nil {
+			return nil, fmt.Errorf("invalid template: %s", err)
+		}
+	}
+
+	switch r.Type {
+	case ChannelTypeWebhook, ChannelTypeSlack, ChannelTypeNtfy:
+		if r.URL == "" {
+			return nil, fmt.Errorf("channel of type '%s' requires an 'url'", r.Type)
+		}
+	case ChannelTypeSMTP:
+		if r.Host == "" || r.From == "" || len(r.To) == 0 {
+			return nil, errors.New("channel of type 'smtp' requires 'host', 'from' and 'to'")
+		}
+	default:
+		return nil, fmt.Errorf("unknown channel type '%s'", r.Type)
+	}
+
+	return r, nil
+}
+
+func parseRoute(cfg Raw, channels map[string]*ChannelConfiguration) (*RouteConfiguration, error) {
+	r := &RouteConfiguration{
+		Channels:       cfg.StringSlice("channels"),
+		RepeatInterval: cfg.Duration("repeat_interval"),
+		Continue:       cfg.Bool("continue"),
+	}
+
+	if len(r.Channels) == 0 {
+		return nil, errors.New("route requires at least one channel")
+	}
+
+	for _, channel := range r.Channels {
+		if _, exists := channels[channel]; !exists {
+			return nil, fmt.Errorf("unknown channel '%s'", channel)
+		}
+	}
+
+	match := cfg.Sub("match")
+
+	for label := range match {
+		// a list of values matches any of them
+		values := match.StringSlice(label)
+		value := match.String(label)
+
+		if values != nil {
+			quoted := make([]string, len(values))
+			for i, v := range values {
+				quoted[i] = regexp.QuoteMeta(v)
+			}
+
+			value = "/" + strings.Join(quoted, "|") + "/"
+		}
+
+		matcher := &LabelMatcher{Name: label, Value: value}
+
+		if len(value) > 1 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+			expr, err := regexp.Compile("^(?:" + strings.TrimSuffix(strings.TrimPrefix(value, "/"), "/") + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression for label '%s': %s", label, err)
+			}
+
+			matcher.Regexp = expr
+		}
+
+		r.Matchers = append(r.Matchers, matcher)
+	}
+
+	return r, nil
+}
+
+// Parses `replicas:` section; see #31
+func parseReplicasSection(cfg Raw) []*ReplicaConfiguration {
+	var replicas []*ReplicaConfiguration
+
+	const paramSource = "source"
+	const paramReplica = "replica"
+	const paramMaxLag = "max_lag"
+	const paramMatch = "match"
+
+	for name := range cfg {
+		replicaCfg := cfg.Sub(name)
+		source := parseReplicaEndpoint(replicaCfg.Sub(paramSource))
+		replica := parseReplicaEndpoint(replicaCfg.Sub(paramReplica))
+
+		if source == nil || replica == nil {
+			log.Errorf("Replica rule '%s' requires a '%s' and a '%s' with environment, disk, dir and file", name, paramSource, paramReplica)
+			continue
+		}
+
+		maxLag := 6 * Hour
+		if replicaCfg.Has(paramMaxLag) {
+			maxLag = replicaCfg.Duration(paramMaxLag)
+		}
+
+		r := &ReplicaConfiguration{
+			Name:    name,
+			Source:  source,
+			Replica: replica,
+			MaxLag:  maxLag,
+		}
+
+		// `match` is either a single value or a list
+		matches := replicaCfg.StringSlice(paramMatch)
+		if matches == nil && replicaCfg.Has(paramMatch) {
+			matches = []string{replicaCfg.String(paramMatch)}
+		}
+
+		for _, match := range matches {
+			switch match {
+			case "size":
+				r.MatchSize = true
+			case "checksum":
+				r.MatchChecksum = true
+			default:
+				log.Warnf("Unknown value '%s' for '%s' in replica rule '%s', ignoring it", match, paramMatch, name)
+			}
+		}
+
+		replicas = append(replicas, r)
+	}
+
+	return replicas
+}
+
+func parseReplicaEndpoint(cfg Raw) *ReplicaEndpointConfiguration {
+	r := &ReplicaEndpointConfiguration{
+		Environment: cfg.String("environment"),
+		Disk:        cfg.String("disk"),
+		Directory:   cfg.String("dir"),
+		File:        cfg.String("file"),
+	}
+
+	if r.Environment == "" || r.Disk == "" || r.Directory == "" || r.File == "" {
+		return nil
+	}
+
+	return r
+}
+
+// IsDryRun Return true if the given `purge` value requests the dry-run mode
+func IsDryRun(value string) bool {
+	return value == PurgeDryRun
+}
+
 func parseEnvironmentsSection(cfg Raw) []*EnvironmentConfiguration {
 	var envs []*EnvironmentConfiguration
 
@@ -4,6 +4,7 @@ import (
 	//	"github.com/davecgh/go-spew/spew"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func Test_GH29_PR31_NewConfigurationInstance_canDetectRegionForFirstEnvironment(t *testing.T) {
@@ -79,3 +80,350 @@ environments:
 	assertion.Equal(1, len(diskCfg.exclude))
 	assertion.Contains(diskCfg.exclude, "excluded-1")
 }
+
+func Test_GH27_NewConfigurationInstance_parsesGlobalPurgeDryRun(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+purge: dry-run
+purge_audit_log: /var/log/backmon/purge.jsonl
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw)
+
+	assertion.True(sut.Global().PurgeDryRun())
+	assertion.Equal("/var/log/backmon/purge.jsonl", sut.Global().PurgeAuditLog())
+}
+
+func Test_GH31_NewConfigurationInstance_parsesReplicas(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+environments:
+  default:
+    s3:
+replicas:
+  nas-to-s3:
+    source:
+      environment: nas
+      disk: /mnt/backup
+      dir: my-backups
+      file: pgdump
+    replica:
+      environment: aws
+      disk: my-bucket
+      dir: my-backups
+      file: pgdump
+    max_lag: 3h
+    match:
+      - size
+  incomplete:
+    source:
+      environment: nas
+`)
+	sut := NewConfigurationInstance(raw)
+
+	assertion.Equal(1, len(sut.Replicas()))
+	assertion.Equal("nas-to-s3", sut.Replicas()[0].Name)
+	assertion.Equal("my-bucket", sut.Replicas()[0].Replica.Disk)
+	assertion.Equal(3*Hour, sut.Replicas()[0].MaxLag)
+	assertion.True(sut.Replicas()[0].MatchSize)
+	assertion.False(sut.Replicas()[0].MatchChecksum)
+}
+
+func Test_GH32_NewConfigurationInstance_parsesAnomalies(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+anomalies:
+  modified_files: 5
+  entropy:
+    sample_size: 64KB
+    compressible_extensions: [.sql]
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw)
+	anomalies := sut.Global().Anomalies()
+
+	assertion.Equal(uint64(5), anomalies.ModifiedFiles)
+	assertion.Equal(uint64(0), anomalies.DeletedFiles)
+	assertion.Equal(uint64(0), anomalies.ShrunkFiles)
+	assertion.Equal(uint64(0), anomalies.RenamedFiles)
+	assertion.Equal(uint64(64*1024), anomalies.EntropySampleSize)
+	assertion.Equal(7.5, anomalies.EntropyThreshold)
+	assertion.Equal([]string{".sql"}, anomalies.CompressibleExtensions)
+}
+
+func Test_GH36_NewConfigurationInstance_parsesCosts(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+costs:
+  currency: EUR
+  prices:
+    s3:
+      standard: 0.023
+      default: 0.01
+  environments:
+    minio:
+      default: 0.005
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw)
+
+	assertion.Equal("EUR", sut.Costs().Currency)
+
+	price, _ := sut.Costs().Price("aws", ProviderS3, "STANDARD")
+	assertion.Equal(0.023, price)
+
+	price, _ = sut.Costs().Price("aws", ProviderS3, "GLACIER")
+	assertion.Equal(0.01, price)
+
+	price, _ = sut.Costs().Price("minio", ProviderS3, "STANDARD")
+	assertion.Equal(0.005, price)
+
+	_, exists := sut.Costs().Price("aws", ProviderLocal, "")
+	assertion.False(exists)
+}
+
+func Test_GH37_NewConfigurationInstance_parsesNotifications(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+notifications:
+  repeat_interval: 1h
+  send_resolved: false
+  channels:
+    ops:
+      type: slack
+      url: https://hooks.example.com/ops
+    mail:
+      type: smtp
+      host: mail.example.com
+      from: backmon@example.com
+      to: ops@example.com
+    broken:
+      type: pager
+  routes:
+    - match:
+        environment: /prod-.*/
+        status: [late, missing]
+      channels: [ops, mail]
+      repeat_interval: 30m
+    - match:
+        disk: other
+      channels: [unknown]
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw).Notifications()
+
+	assertion.Equal(time.Hour, sut.RepeatInterval)
+	assertion.False(sut.SendResolved)
+	assertion.Len(sut.Channels, 2)
+	assertion.Equal(587, sut.Channels["mail"].Port)
+	assertion.Equal([]string{"ops@example.com"}, sut.Channels["mail"].To)
+
+	// the route with the unknown channel is skipped
+	assertion.Len(sut.Routes, 1)
+	route := sut.Routes[0]
+	assertion.Equal(30*time.Minute, route.RepeatInterval)
+
+	assertion.True(route.Matches(map[string]string{"environment": "prod-eu", "status": "late"}))
+	assertion.True(route.Matches(map[string]string{"environment": "prod-eu", "status": "missing"}))
+	assertion.False(route.Matches(map[string]string{"environment": "prod-eu", "status": "definitions_missing"}))
+	assertion.False(route.Matches(map[string]string{"environment": "staging", "status": "late"}))
+}
+
+func Test_GH37_NewConfigurationInstance_withoutChannels_disablesNotifications(t *testing.T) {
+	raw, _ := ParseFromString(
+		`
+environments:
+  default:
+    s3:
+`)
+
+	assert.Nil(t, NewConfigurationInstance(raw).Notifications())
+}
+
+func Test_GH38_NewConfigurationInstance_parsesHeartbeats(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+heartbeats:
+  token: secret
+  grace: 1h
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw).Global().Heartbeats()
+
+	assertion.Equal("secret", sut.Token)
+	assertion.Equal(time.Hour, sut.Grace)
+	assertion.Equal(uint64(10*1024), sut.MaxLogSize)
+}
+
+func Test_GH47_NewConfigurationInstance_parsesDownloadLinks(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+downloads:
+  enabled: true
+  signing_key: secret
+  link_expiry: 48h
+  s3_redirect: true
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw).Downloads()
+
+	assertion.Equal("secret", sut.SigningKey)
+	assertion.True(sut.S3Redirect)
+	assertion.Equal(24*time.Hour, sut.MaxLinkExpiry)
+	// the default expiry is capped by the maximum
+	assertion.Equal(24*time.Hour, sut.LinkExpiry)
+}
+
+func Test_GH48_NewConfigurationInstance_parsesUsersAndTokens(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+http:
+  metrics_auth: true
+  users:
+    bob:
+      password: $2a$10$hash
+      role: operator
+      environments: [prod]
+    alice:
+      password: $2a$10$hash
+      disks: [db-backups]
+    missing:
+      role: admin
+  tokens:
+    ci:
+      hash: $2a$10$hash
+      role: downloader
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw).Http()
+
+	assertion.True(sut.MetricsAuth)
+	assertion.Len(sut.Users, 2)
+	assertion.Equal("alice", sut.Users[0].Name)
+	assertion.Equal([]string{"db-backups"}, sut.Users[0].Disks)
+	assertion.Equal("operator", sut.Users[1].Role)
+	assertion.Equal([]string{"prod"}, sut.Users[1].Environments)
+	assertion.Len(sut.Tokens, 1)
+	assertion.Equal("downloader", sut.Tokens[0].Role)
+}
+
+func Test_GH49_NewConfigurationInstance_parsesOidc(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+http:
+  oidc:
+    issuer: https://sso.example.com
+    client_id: backmon
+    jwks_file: /etc/backmon/jwks.json
+    roles:
+      - value: backmon-admins
+        role: admin
+      - claim: realm_access.roles
+        value: ops
+        role: operator
+        disks: [db-backups]
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw).Http().Oidc
+
+	assertion.Equal("https://sso.example.com", sut.Issuer)
+	assertion.Equal([]string{"backmon"}, sut.Audiences)
+	assertion.Equal("/etc/backmon/jwks.json", sut.JwksFile)
+	assertion.Equal("preferred_username", sut.UsernameClaim)
+	assertion.False(sut.Login)
+	assertion.Len(sut.Roles, 2)
+	assertion.Equal("groups", sut.Roles[0].Claim)
+	assertion.Equal("admin", sut.Roles[0].Role)
+	assertion.Equal("realm_access.roles", sut.Roles[1].Claim)
+	assertion.Equal([]string{"db-backups"}, sut.Roles[1].Disks)
+
+	// without an audience, every token of the issuer would be accepted
+	raw, _ = ParseFromString(
+		`
+http:
+  oidc:
+    issuer: https://sso.example.com
+environments:
+  default:
+    s3:
+`)
+	assertion.Nil(NewConfigurationInstance(raw).Http().Oidc)
+}
+
+func Test_GH50_NewConfigurationInstance_parsesClientCertificates(t *testing.T) {
+	assertion := assert.New(t)
+
+	raw, _ := ParseFromString(
+		`
+http:
+  tls:
+    certificate: server.crt
+    key: server.key
+    client_ca: ca.pem
+    clients:
+      - subject: CN=prometheus,O=Monitoring
+      - san: backup-tool.internal
+        role: operator
+        environments: [prod]
+environments:
+  default:
+    s3:
+`)
+	sut := NewConfigurationInstance(raw).Http().Tls
+
+	assertion.Equal("ca.pem", sut.ClientCaPath)
+	assertion.Equal(ClientAuthVerify, sut.ClientAuth)
+	assertion.Len(sut.Clients, 2)
+	assertion.Equal("CN=prometheus,O=Monitoring", sut.Clients[0].Subject)
+	assertion.Equal("backup-tool.internal", sut.Clients[1].San)
+	assertion.Equal([]string{"prod"}, sut.Clients[1].Environments)
+
+	raw, _ = ParseFromString(
+		`
+client_ca: ca.pem
+client_auth: optional
+`)
+	assertion.ErrorContains(parseClientAuth(raw, &TlsConfiguration{}), "unknown client_auth 'optional'")
+
+	raw, _ = ParseFromString(
+		`
+client_ca: ca.pem
+clients:
+  - role: admin
+`)
+	assertion.ErrorContains(parseClientAuth(raw, &TlsConfiguration{}), "requires either a 'subject' or a 'san'")
+}
@@ -0,0 +1,42 @@
+package config
+
+import "strings"
+
+// provider names of the price tables
+const (
+	ProviderS3    = "s3"
+	ProviderLocal = "local"
+)
+
+// storage class used if a price table has no price for a file's storage class
+const StorageClassDefault = "DEFAULT"
+
+// CostsConfiguration prices per GB-month (1 GB = 1024^3 bytes) of each storage class; see #36
+type CostsConfiguration struct {
+	Currency string
+	// price tables of each provider, by storage class
+	Providers map[string]map[string]float64
+	// price tables of single environments, e.g. for S3 compatible storages; they take precedence over the providers
+	Environments map[string]map[string]float64
+}
+
+// Price Return the price per GB-month of the storage class; false if there is no matching price table or price
+func (c *CostsConfiguration) Price(environment string, provider string, storageClass string) (float64, bool) {
+	table, exists := c.Environments[environment]
+
+	if !exists {
+		table, exists = c.Providers[provider]
+	}
+
+	if !exists {
+		return 0, false
+	}
+
+	if price, exists := table[strings.ToUpper(storageClass)]; exists && storageClass != "" {
+		return price, true
+	}
+
+	price, exists := table[StorageClassDefault]
+
+	return price, exists
+}
@@ -1,5 +1,14 @@
 package config
 
+import "time"
+
 type DownloadsConfiguration struct {
 	Enabled bool
+	// HMAC key of signed download links; if empty, a random key is used, which invalidates all links on restart. See #47
+	SigningKey string
+	// default and maximum lifetime of signed download links
+	LinkExpiry    time.Duration
+	MaxLinkExpiry time.Duration
+	// downloads of S3 objects are redirected to a presigned URL instead of streaming them through backmon
+	S3Redirect bool
 }
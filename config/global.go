@@ -9,6 +9,67 @@ type GlobalConfiguration struct {
 	logLevel       log.Level
 	httpPort       int
 	updateInterval time.Duration
+	purgeDryRun    bool
+	purgeAuditLog  string
+	purgeGuards    *PurgeGuardsConfiguration
+	anomalies      *AnomaliesConfiguration
+	history        *HistoryConfiguration
+	forecast       *ForecastConfiguration
+	heartbeats     *HeartbeatsConfiguration
+}
+
+// PurgeGuardsConfiguration global limits which refuse a purge run; see #28
+type PurgeGuardsConfiguration struct {
+	// maximum amount of files purged per group in one run; 0 means unlimited
+	MaxFiles uint64
+	// maximum percentage of a group's bytes purged in one run; 0 means unlimited
+	MaxBytesPercent float64
+	// no files are purged if the definitions file has changed within this duration
+	DefinitionsGrace time.Duration
+}
+
+// AnomaliesConfiguration thresholds of the heuristics which detect tampering with backup files; see #32
+type AnomaliesConfiguration struct {
+	// minimum amount of existing files modified since the previous scan; 0 disables the heuristic
+	ModifiedFiles uint64
+	// minimum amount of files which have been deleted since the previous scan, not counting purged files; 0 disables the heuristic
+	DeletedFiles uint64
+	// minimum amount of files which have shrunk since the previous scan; 0 disables the heuristic
+	ShrunkFiles uint64
+	// minimum amount of files which have been renamed by appending an extension; 0 disables the heuristic
+	RenamedFiles uint64
+	// sampled bytes of new or modified files with a compressible extension; 0 disables the entropy heuristic
+	EntropySampleSize uint64
+	// minimum entropy in bits per byte which is considered suspicious
+	EntropyThreshold float64
+	// extensions of files which should be compressible
+	CompressibleExtensions []string
+}
+
+// HistoryConfiguration persistent store of scans and observed backup files; see #33
+type HistoryConfiguration struct {
+	// path to the database file; empty if the history is disabled
+	Path string
+	// scans and disappeared files older than this duration are removed from the history
+	Retention time.Duration
+}
+
+// ForecastConfiguration growth forecasts of the disk usage; see #35
+type ForecastConfiguration struct {
+	// samples within this duration are used for the forecast
+	Window time.Duration
+	// the projected usage is computed for this duration in the future
+	Horizon time.Duration
+}
+
+// HeartbeatsConfiguration pings of backup jobs reporting their start, success or failure; see #38
+type HeartbeatsConfiguration struct {
+	// bearer token accepted by POST requests of the heartbeat endpoints additionally to the basic auth credentials; empty if none is accepted
+	Token string
+	// a file must have appeared within this duration after a job reported its success
+	Grace time.Duration
+	// log excerpts sent by jobs are truncated to this amount of bytes
+	MaxLogSize uint64
 }
 
 func (config *GlobalConfiguration) LogLevel() log.Level {
@@ -22,3 +83,33 @@ func (config *GlobalConfiguration) HttpPort() int {
 func (config *GlobalConfiguration) UpdateInterval() time.Duration {
 	return config.updateInterval
 }
+
+// PurgeDryRun Return true if purging has been globally set to `dry-run`
+func (config *GlobalConfiguration) PurgeDryRun() bool {
+	return config.purgeDryRun
+}
+
+// PurgeAuditLog Return the path to the append-only purge audit log; empty if none has been configured
+func (config *GlobalConfiguration) PurgeAuditLog() string {
+	return config.purgeAuditLog
+}
+
+func (config *GlobalConfiguration) PurgeGuards() *PurgeGuardsConfiguration {
+	return config.purgeGuards
+}
+
+func (config *GlobalConfiguration) Anomalies() *AnomaliesConfiguration {
+	return config.anomalies
+}
+
+func (config *GlobalConfiguration) History() *HistoryConfiguration {
+	return config.history
+}
+
+func (config *GlobalConfiguration) Forecast() *ForecastConfiguration {
+	return config.forecast
+}
+
+func (config *GlobalConfiguration) Heartbeats() *HeartbeatsConfiguration {
+	return config.heartbeats
+}
@@ -3,6 +3,13 @@ package config
 type HttpConfiguration struct {
 	BasicAuth *BasicAuthConfiguration
 	Tls       *TlsConfiguration
+	// users authenticating with basic auth and API tokens sent as bearer token; see #48
+	Users  []*CredentialConfiguration
+	Tokens []*CredentialConfiguration
+	// whether /metrics requires authentication like /api
+	MetricsAuth bool
+	// JWTs of an OpenID Connect provider
+	Oidc *OidcConfiguration
 }
 
 type BasicAuthConfiguration struct {
@@ -10,8 +17,45 @@ type BasicAuthConfiguration struct {
 	Password string
 }
 
+// CredentialConfiguration a user or an API token with its role and scope
+type CredentialConfiguration struct {
+	Name string
+	// bcrypt hash of the password or token
+	Hash string
+	Role string
+	// the credential is restricted to these environments and disks; empty if it is not restricted
+	Environments []string
+	Disks        []string
+}
+
 type TlsConfiguration struct {
 	CertificatePath string
 	PrivateKeyPath  string
 	IsStrict        bool
+	// client certificates issued by the CA authenticate requests; see #50
+	ClientCaPath string
+	ClientAuth   string
+	Clients      []*ClientCertificateConfiguration
+}
+
+// modes of requesting client certificates; see #50
+const (
+	// ClientAuthRequest requests a certificate, clients without one must authenticate otherwise
+	ClientAuthRequest = "request"
+	// ClientAuthRequire requires a certificate, but does not reject invalid ones during the TLS handshake
+	ClientAuthRequire = "require"
+	// ClientAuthVerify requires a certificate of the client CA during the TLS handshake
+	ClientAuthVerify = "verify"
+)
+
+// ClientCertificateConfiguration grants the role to client certificates with the subject or one of the SANs
+type ClientCertificateConfiguration struct {
+	// the distinguished name, e.g. CN=prometheus,O=Monitoring, or only the common name
+	Subject string
+	// a DNS name, email address, IP address or URI
+	San  string
+	Role string
+	// the certificate is restricted to these environments and disks; empty if it is not restricted
+	Environments []string
+	Disks        []string
 }
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"regexp"
+	"time"
+)
+
+// types of notification channels; see #37
+const (
+	ChannelTypeWebhook = "webhook"
+	ChannelTypeSMTP    = "smtp"
+	ChannelTypeSlack   = "slack"
+	ChannelTypeNtfy    = "ntfy"
+)
+
+// NotificationsConfiguration the built-in alerting; nil if no channels have been configured
+type NotificationsConfiguration struct {
+	// a firing alert is sent again after this interval
+	RepeatInterval time.Duration
+	// send a notification when an alert is resolved
+	SendResolved bool
+	Channels     map[string]*ChannelConfiguration
+	// without routes, each alert is sent to all channels
+	Routes []*RouteConfiguration
+}
+
+type ChannelConfiguration struct {
+	Name string
+	Type string
+	// webhook, slack and ntfy
+	URL     string
+	Headers map[string]string
+	// webhook: HTTP method, defaults to POST
+	Method string
+	// webhook: Go template of the request body; the notification is sent as JSON if empty
+	Template string
+	// smtp
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	// ntfy
+	Token    string
+	Priority string
+}
+
+// RouteConfiguration sends all alerts matching all label matchers to the channels
+type RouteConfiguration struct {
+	Matchers []*LabelMatcher
+	Channels []string
+	// overrides the global repeat interval if set
+	RepeatInterval time.Duration
+	// continue with the next route after this one has matched
+	Continue bool
+}
+
+// LabelMatcher matches the label either by its value or, if the configured value is enclosed in slashes, by a regular
+// expression
+type LabelMatcher struct {
+	Name   string
+	Value  string
+	Regexp *regexp.Regexp
+}
+
+func (m *LabelMatcher) Matches(labels map[string]string) bool {
+	if m.Regexp != nil {
+		return m.Regexp.MatchString(labels[m.Name])
+	}
+
+	return labels[m.Name] == m.Value
+}
+
+// Matches Return true if all matchers of the route match the labels
+func (r *RouteConfiguration) Matches(labels map[string]string) bool {
+	for _, matcher := range r.Matchers {
+		if !matcher.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
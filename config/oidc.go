@@ -0,0 +1,37 @@
+package config
+
+// OidcConfiguration of JWTs issued by an OpenID Connect provider, which are accepted as bearer tokens; see #49
+type OidcConfiguration struct {
+	Issuer string
+	// the token's audience must contain one of the audiences; defaults to the client ID
+	Audiences []string
+	// the public keys are read from a local JWKS file or fetched from the JWKS URL; otherwise, they are discovered
+	// through the issuer
+	JwksFile string
+	JwksUrl  string
+	// the claim containing the name of the principal; the subject is used if it is missing
+	UsernameClaim string
+	// the mapping of claims to roles; the first mapping with the highest role applies
+	Roles []*OidcRoleMapping
+	// role of tokens without a matching mapping; if empty, these tokens are rejected
+	DefaultRole string
+	// the web UI signs in users through the authorization code flow
+	Login            bool
+	ClientId         string
+	ClientSecret     string
+	RedirectUrl      string
+	Scopes           []string
+	AuthorizationUrl string
+	TokenUrl         string
+}
+
+// OidcRoleMapping grants the role if the claim equals the value or, if the claim is a list, contains it
+type OidcRoleMapping struct {
+	// name of the claim; nested claims are separated by a dot, e.g. realm_access.roles
+	Claim string
+	Value string
+	Role  string
+	// the principal is restricted to these environments and disks; empty if it is not restricted
+	Environments []string
+	Disks        []string
+}
@@ -120,6 +120,10 @@ func (c Raw) Int64(key string) int64 {
 	return asInt64(c[key])
 }
 
+func (c Raw) Float64(key string) float64 {
+	return asFloat64(c[key])
+}
+
 func (c Raw) Bytes(key string) uint64 {
 	val := c[key]
 	if val == nil {
@@ -264,6 +268,25 @@ func asInt64(val interface{}) int64 {
 	return 0
 }
 
+func asFloat64(val interface{}) float64 {
+	if val == nil {
+		return 0
+	}
+	switch v := val.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64)
+		if err == nil {
+			return f
+		}
+		return 0
+	}
+	return float64(asInt64(val))
+}
+
 func asBool(val interface{}) bool {
 	if val == nil {
 		return false
@@ -0,0 +1,24 @@
+package config
+
+import "time"
+
+// ReplicaConfiguration a rule which requires each backup of the source to be replicated to the replica
+type ReplicaConfiguration struct {
+	Name    string
+	Source  *ReplicaEndpointConfiguration
+	Replica *ReplicaEndpointConfiguration
+	// maximum time a backup may exist in the source without being replicated
+	MaxLag time.Duration
+	// additionally to the name or the interpolated timestamp, the sizes must be equal
+	MatchSize bool
+	// additionally to the name or the interpolated timestamp, the checksums must be equal
+	MatchChecksum bool
+}
+
+// ReplicaEndpointConfiguration references a file definition in a disk of an environment
+type ReplicaEndpointConfiguration struct {
+	Environment string
+	Disk        string
+	Directory   string
+	File        string
+}
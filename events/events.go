@@ -0,0 +1,167 @@
+package events
+
+// Typed events of backup changes between consecutive scans. Events are published to a broker which fans them out to
+// all subscribers, e.g. the Server-Sent Events of /api/events; see #40.
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// types of events
+const (
+	// a new latest backup file has appeared in a group
+	TypeLatestFile = "latest_file"
+	// a backup file has been purged
+	TypeFilePurged = "file_purged"
+	// a group has appeared or disappeared since the previous scan
+	TypeGroupAppeared    = "group_appeared"
+	TypeGroupDisappeared = "group_disappeared"
+	// the status of a group has changed, e.g. from ok to late
+	TypeStatusChanged = "status_changed"
+	// a backup file has been flagged as possible tampering
+	TypeAnomaly = "anomaly"
+)
+
+const (
+	// amount of published events kept for subscribers which reconnect
+	historySize = 100
+	// events which a subscriber has not yet received; if the buffer is full, further events are dropped
+	subscriberBuffer = 64
+)
+
+type Event struct {
+	ID          uint64    `json:"id"`
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	Environment string    `json:"environment"`
+	Disk        string    `json:"disk"`
+	Directory   string    `json:"dir,omitempty"`
+	File        string    `json:"file,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	// name of the backup file
+	Name           string `json:"name,omitempty"`
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Detail         string `json:"detail,omitempty"`
+}
+
+// Filter restricts the events received by a subscriber; empty fields match everything
+type Filter struct {
+	Disk      string
+	Directory string
+	File      string
+	Types     []string
+}
+
+func (f *Filter) Matches(event *Event) bool {
+	if (f.Disk != "" && f.Disk != event.Disk) ||
+		(f.Directory != "" && f.Directory != event.Directory) ||
+		(f.File != "" && f.File != event.File) {
+		return false
+	}
+
+	if len(f.Types) == 0 {
+		return true
+	}
+
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+type subscription struct {
+	filter Filter
+	events chan Event
+}
+
+type Broker struct {
+	mutex         sync.Mutex
+	lastID        uint64
+	history       []Event
+	subscriptions map[*subscription]bool
+}
+
+var (
+	instance *Broker
+	once     sync.Once
+)
+
+func GetInstance() *Broker {
+	once.Do(func() {
+		instance = NewBroker()
+	})
+
+	return instance
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscriptions: make(map[*subscription]bool)}
+}
+
+// Publish assigns the next ID to the event and sends it to all matching subscribers without blocking
+func (b *Broker) Publish(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	b.history = append(b.history, event)
+
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for s := range b.subscriptions {
+		if !s.filter.Matches(&event) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			log.Warnf("Dropping event %d for a slow subscriber", event.ID)
+		}
+	}
+}
+
+// Subscribe Return a channel receiving all matching events published after `lastID`, including the ones still kept
+// in the history. The returned function cancels the subscription and must be called.
+func (b *Broker) Subscribe(filter Filter, lastID uint64) (<-chan Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := &subscription{filter: filter, events: make(chan Event, subscriberBuffer+historySize)}
+
+	if lastID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastID && filter.Matches(&event) {
+				s.events <- event
+			}
+		}
+	}
+
+	b.subscriptions[s] = true
+
+	return s.events, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+
+		delete(b.subscriptions, s)
+	}
+}
+
+// Publish publishes the event to the global broker
+func Publish(event Event) {
+	GetInstance().Publish(event)
+}
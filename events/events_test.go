@@ -0,0 +1,44 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH40_Publish_sendsMatchingEventsOnly(t *testing.T) {
+	assertion := assert.New(t)
+	sut := NewBroker()
+
+	received, cancel := sut.Subscribe(Filter{Disk: "backups", Types: []string{TypeLatestFile}}, 0)
+	defer cancel()
+
+	sut.Publish(Event{Type: TypeLatestFile, Disk: "other"})
+	sut.Publish(Event{Type: TypeGroupAppeared, Disk: "backups"})
+	sut.Publish(Event{Type: TypeLatestFile, Disk: "backups", Name: "dump.sql"})
+
+	assertion.Len(received, 1)
+	event := <-received
+	assertion.Equal(uint64(3), event.ID)
+	assertion.Equal("dump.sql", event.Name)
+	assertion.False(event.Time.IsZero())
+}
+
+func Test_GH40_Subscribe_replaysEventsAfterLastID(t *testing.T) {
+	assertion := assert.New(t)
+	sut := NewBroker()
+
+	for i := 0; i < 3; i++ {
+		sut.Publish(Event{Type: TypeFilePurged})
+	}
+
+	received, cancel := sut.Subscribe(Filter{}, 1)
+	cancel()
+
+	assertion.Len(received, 2)
+	assertion.Equal(uint64(2), (<-received).ID)
+
+	// cancelled subscriptions don't receive any events
+	sut.Publish(Event{Type: TypeFilePurged})
+	assertion.Len(received, 1)
+}
@@ -0,0 +1,140 @@
+package forecast
+
+// Growth forecasts based upon a least squares linear regression of usage samples
+import (
+	"math"
+	"time"
+)
+
+const (
+	// minimum amount of samples required for a forecast
+	minSamples = 3
+	// minimum time span covered by the samples; scans in quick succession don't tell anything about growth
+	minSpan = time.Hour
+	// the series keeps one sample per resolution, so that frequent scans neither dominate the regression nor the memory
+	resolution = time.Hour
+)
+
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a sliding window of samples, ordered by time
+type Series struct {
+	points []Point
+	window time.Duration
+}
+
+func NewSeries(window time.Duration) *Series {
+	return &Series{window: window}
+}
+
+// Add appends the sample and drops all samples outside the window. A sample replaces the previous one of the same
+// resolution interval.
+func (s *Series) Add(point Point) {
+	if len(s.points) > 0 {
+		last := s.points[len(s.points)-1]
+
+		if point.Time.Before(last.Time) {
+			// samples must be added in chronological order
+			return
+		}
+
+		if point.Time.Truncate(resolution).Equal(last.Time.Truncate(resolution)) {
+			s.points = s.points[:len(s.points)-1]
+		}
+	}
+
+	s.points = append(s.points, point)
+	threshold := point.Time.Add(-s.window)
+	dropped := 0
+
+	for dropped < len(s.points) && s.points[dropped].Time.Before(threshold) {
+		dropped++
+	}
+
+	s.points = s.points[dropped:]
+}
+
+func (s *Series) Points() []Point {
+	return s.points
+}
+
+func (s *Series) Window() time.Duration {
+	return s.window
+}
+
+// Trend is a linear function of time
+type Trend struct {
+	origin time.Time
+	// growth per second
+	slope float64
+	// value at origin
+	intercept float64
+}
+
+// Fit Return the linear trend of the points; false if there are not enough points for a meaningful trend
+func Fit(points []Point) (*Trend, bool) {
+	if len(points) < minSamples || points[len(points)-1].Time.Sub(points[0].Time) < minSpan {
+		return nil, false
+	}
+
+	// relative to the first point, to keep the precision of the float64 values
+	origin := points[0].Time
+	n := float64(len(points))
+	var sumX, sumY, sumXY, sumXX float64
+
+	for _, point := range points {
+		x := point.Time.Sub(origin).Seconds()
+		sumX += x
+		sumY += point.Value
+		sumXY += x * point.Value
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+
+	if denominator == 0 {
+		return nil, false
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+
+	return &Trend{
+		origin:    origin,
+		slope:     slope,
+		intercept: (sumY - slope*sumX) / n,
+	}, true
+}
+
+// At Return the value of the trend at the given moment
+func (t *Trend) At(moment time.Time) float64 {
+	return t.intercept + t.slope*moment.Sub(t.origin).Seconds()
+}
+
+// PerDay Return the growth per day
+func (t *Trend) PerDay() float64 {
+	return t.slope * (24 * time.Hour).Seconds()
+}
+
+// Reaches Return the moment on which the trend reaches the value, but not before `now`. If the trend never reaches
+// the value, false is returned.
+func (t *Trend) Reaches(value float64, now time.Time) (time.Time, bool) {
+	if t.At(now) >= value {
+		return now, true
+	}
+
+	if t.slope <= 0 {
+		return time.Time{}, false
+	}
+
+	seconds := (value - t.intercept) / t.slope
+
+	// beyond the range of time.Duration, nobody cares
+	if seconds > math.MaxInt64/float64(time.Second) {
+		return time.Time{}, false
+	}
+
+	return t.origin.Add(time.Duration(seconds * float64(time.Second))), true
+}
@@ -0,0 +1,89 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dailyPoints(start time.Time, values ...float64) []Point {
+	r := make([]Point, len(values))
+
+	for i, value := range values {
+		r[i] = Point{Time: start.AddDate(0, 0, i), Value: value}
+	}
+
+	return r
+}
+
+func Test_GH35_Fit_computesLinearTrend(t *testing.T) {
+	assertion := assert.New(t)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	trend, ok := Fit(dailyPoints(start, 100, 110, 120, 130))
+
+	assertion.True(ok)
+	assertion.InDelta(10, trend.PerDay(), 0.0001)
+	assertion.InDelta(230, trend.At(start.AddDate(0, 0, 13)), 0.0001)
+
+	exhaustion, reached := trend.Reaches(200, start.AddDate(0, 0, 3))
+	assertion.True(reached)
+	assertion.Equal(start.AddDate(0, 0, 10), exhaustion)
+}
+
+func Test_GH35_Fit_requiresEnoughSamples(t *testing.T) {
+	assertion := assert.New(t)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	_, ok := Fit(dailyPoints(start, 100, 110))
+	assertion.False(ok)
+
+	_, ok = Fit([]Point{{Time: start, Value: 1}, {Time: start.Add(time.Minute), Value: 2}, {Time: start.Add(2 * time.Minute), Value: 3}})
+	assertion.False(ok)
+}
+
+func Test_GH35_Reaches_neverForShrinkingUsage(t *testing.T) {
+	assertion := assert.New(t)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	trend, _ := Fit(dailyPoints(start, 130, 120, 110))
+
+	_, reached := trend.Reaches(200, start.AddDate(0, 0, 2))
+	assertion.False(reached)
+
+	// already exhausted
+	exhaustion, reached := trend.Reaches(100, start.AddDate(0, 0, 2))
+	assertion.True(reached)
+	assertion.Equal(start.AddDate(0, 0, 2), exhaustion)
+}
+
+func Test_GH35_Series_dropsSamplesOutsideWindow(t *testing.T) {
+	assertion := assert.New(t)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	sut := NewSeries(48 * time.Hour)
+
+	for _, point := range dailyPoints(start, 1, 2, 3, 4) {
+		sut.Add(point)
+	}
+
+	// out of order
+	sut.Add(Point{Time: start, Value: 5})
+
+	assertion.Equal(dailyPoints(start.AddDate(0, 0, 1), 2, 3, 4), sut.Points())
+}
+
+func Test_GH35_Series_keepsOneSamplePerHour(t *testing.T) {
+	assertion := assert.New(t)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	sut := NewSeries(48 * time.Hour)
+
+	for i := 0; i < 180; i++ {
+		sut.Add(Point{Time: start.Add(time.Duration(i) * time.Minute), Value: float64(i)})
+	}
+
+	assertion.Equal([]Point{
+		{Time: start.Add(59 * time.Minute), Value: 59},
+		{Time: start.Add(119 * time.Minute), Value: 119},
+		{Time: start.Add(179 * time.Minute), Value: 179},
+	}, sut.Points())
+}
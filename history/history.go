@@ -0,0 +1,497 @@
+package history
+
+// Persistent history of scans and observed backup files, stored in an embedded bbolt database. In contrast to the
+// in-memory state of the storage package, the history survives restarts and keeps track of files which have already
+// been purged or deleted.
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketScans = []byte("scans")
+	bucketFiles = []byte("files")
+	// time of the latest complete scan of each disk
+	bucketDisks = []byte("disks")
+)
+
+// ErrDisabled no history has been configured
+var ErrDisabled = errors.New("history is disabled")
+
+// keySeparator separates the components of keys; it cannot be part of environment and disk names or paths
+const keySeparator = "\x00"
+
+// Observation is a backup file which has been observed by one or more scans
+type Observation struct {
+	Environment string    `json:"environment"`
+	Disk        string    `json:"disk"`
+	Directory   string    `json:"directory"`
+	File        string    `json:"file"`
+	Group       string    `json:"group"`
+	Name        string    `json:"name"`
+	Parent      string    `json:"parent"`
+	Size        int64     `json:"size"`
+	SortTime    time.Time `json:"sort_time"`
+	BornAt      time.Time `json:"born_at"`
+	ModifiedAt  time.Time `json:"modified_at"`
+	ArchivedAt  time.Time `json:"archived_at"`
+	// first scan which has observed the file
+	FirstSeen time.Time `json:"first_seen"`
+	// latest scan which has observed the file; for existing files, this is the latest complete scan of the disk
+	LastSeen time.Time `json:"last_seen"`
+	// first scan which has not observed the file anymore; nil as long as the file exists
+	DisappearedAt *time.Time `json:"disappeared_at,omitempty"`
+}
+
+// Scan is the result of scanning a single disk
+type Scan struct {
+	Time            time.Time `json:"time"`
+	Environment     string    `json:"environment"`
+	Disk            string    `json:"disk"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// total amount of files and bytes in the disk
+	Files uint64 `json:"files"`
+	Bytes uint64 `json:"bytes"`
+	// the files of the disk could not be listed or the backup definitions are missing
+	Error       string            `json:"error,omitempty"`
+	Definitions []DefinitionUsage `json:"definitions,omitempty"`
+}
+
+// DefinitionUsage amount of files and bytes matched by a file definition in all groups
+type DefinitionUsage struct {
+	Directory string `json:"directory"`
+	File      string `json:"file"`
+	Files     uint64 `json:"files"`
+	Bytes     uint64 `json:"bytes"`
+}
+
+// Query restricts the returned scans or observations; empty fields match everything
+type Query struct {
+	Environment string
+	Disk        string
+	Directory   string
+	File        string
+	Group       string
+	From        time.Time
+	To          time.Time
+	// only the latest `Limit` results are returned; 0 means unlimited
+	Limit int
+}
+
+type Store struct {
+	db        *bolt.DB
+	retention time.Duration
+}
+
+var (
+	instance *Store
+	mutex    = &sync.Mutex{}
+)
+
+// Open opens the configured history database; without a configured path, the history stays disabled
+func Open(cfg *config.HistoryConfiguration) error {
+	return open(cfg, false)
+}
+
+// OpenReadOnly opens the configured history database without modifying it, e.g. for reports. The database can't be
+// opened while another process, e.g. a running backmon instance, has opened it for writing.
+func OpenReadOnly(cfg *config.HistoryConfiguration) error {
+	return open(cfg, true)
+}
+
+func open(cfg *config.HistoryConfiguration, readOnly bool) error {
+	if cfg == nil || cfg.Path == "" {
+		log.Info("No history path configured, history is disabled")
+		return nil
+	}
+
+	store, err := openStore(cfg.Path, cfg.Retention, readOnly)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	instance = store
+	log.Infof("Recording history in %s", cfg.Path)
+
+	return nil
+}
+
+// GetInstance Return the opened history or nil if the history is disabled
+func GetInstance() *Store {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return instance
+}
+
+// OpenStore opens or creates the history database at the given path
+func OpenStore(path string, retention time.Duration) (*Store, error) {
+	return openStore(path, retention, false)
+}
+
+func openStore(path string, retention time.Duration, readOnly bool) (*Store, error) {
+	db, err := bolt.Open(path, 0640, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, err
+	}
+
+	if readOnly {
+		return &Store{db: db, retention: retention}, nil
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketScans, bucketFiles, bucketDisks} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db, retention: retention}, nil
+}
+
+// Close closes the opened history; the history is disabled afterwards
+func Close() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if instance == nil {
+		return
+	}
+
+	if err := instance.Close(); err != nil {
+		log.Errorf("Unable to close history: %s", err)
+	}
+
+	instance = nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores the scan and the backup files observed by it. Files are only written if they have been changed since
+// their previous observation; complete scans update the last observation of all existing files at once. If the scan is
+// complete, all files of the disk which have not been observed are marked as disappeared.
+func (s *Store) Record(scan Scan, observations []Observation, complete bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(scan)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Bucket(bucketScans).Put(scanKey(scan), data); err != nil {
+			return err
+		}
+
+		files := tx.Bucket(bucketFiles)
+		seen := make(map[string]bool, len(observations))
+
+		for _, observation := range observations {
+			key := fileKey(&observation)
+			seen[string(key)] = true
+			observation.FirstSeen = scan.Time
+			observation.LastSeen = scan.Time
+
+			if existing := files.Get(key); existing != nil {
+				var previous Observation
+
+				if err := json.Unmarshal(existing, &previous); err == nil {
+					observation.FirstSeen = previous.FirstSeen
+					observation.LastSeen = previous.LastSeen
+
+					// #33: unchanged files are not written again during each scan
+					if unchanged, err := json.Marshal(observation); err == nil && bytes.Equal(unchanged, existing) {
+						continue
+					}
+
+					observation.LastSeen = scan.Time
+				}
+			}
+
+			if err := put(files, key, &observation); err != nil {
+				return err
+			}
+		}
+
+		if !complete {
+			return nil
+		}
+
+		disks := tx.Bucket(bucketDisks)
+		// the existing files have been observed by the previous complete scan for the last time
+		previousScan := append([]byte{}, disks.Get(diskKey(scan.Environment, scan.Disk))...)
+
+		if err := disks.Put(diskKey(scan.Environment, scan.Disk), timeKey(scan.Time)); err != nil {
+			return err
+		}
+
+		prefix := append(diskKey(scan.Environment, scan.Disk), []byte(keySeparator)...)
+		cursor := files.Cursor()
+
+		// the observations can't be updated while iterating over them
+		var disappeared []Observation
+
+		for key, value := cursor.Seek(prefix); key != nil && strings.HasPrefix(string(key), string(prefix)); key, value = cursor.Next() {
+			if seen[string(key)] {
+				continue
+			}
+
+			var observation Observation
+			if err := json.Unmarshal(value, &observation); err != nil || observation.DisappearedAt != nil {
+				continue
+			}
+
+			disappearedAt := scan.Time
+			observation.DisappearedAt = &disappearedAt
+			observation.LastSeen = lastSeen(&observation, previousScan)
+			disappeared = append(disappeared, observation)
+		}
+
+		for i := range disappeared {
+			if err := put(files, fileKey(&disappeared[i]), &disappeared[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Prune removes all scans and disappeared files which are older than the retention
+func (s *Store) Prune(now time.Time) error {
+	threshold := now.Add(-s.retention)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		scans := tx.Bucket(bucketScans).Cursor()
+
+		// scans are ordered by time
+		for key, _ := scans.First(); key != nil && timeOfScanKey(key).Before(threshold); key, _ = scans.First() {
+			if err := scans.Delete(); err != nil {
+				return err
+			}
+		}
+
+		files := tx.Bucket(bucketFiles)
+		var expired [][]byte
+
+		err := files.ForEach(func(key []byte, value []byte) error {
+			var observation Observation
+
+			if err := json.Unmarshal(value, &observation); err != nil {
+				return nil
+			}
+
+			if observation.DisappearedAt != nil && observation.DisappearedAt.Before(threshold) {
+				expired = append(expired, append([]byte{}, key...))
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := files.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Scans Return the scans matching the query, oldest first
+func (s *Store) Scans(query Query) ([]Scan, error) {
+	var r []Scan
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketScans).Cursor()
+		key, value := cursor.First()
+
+		if !query.From.IsZero() {
+			key, value = cursor.Seek(timeKey(query.From))
+		}
+
+		for ; key != nil; key, value = cursor.Next() {
+			if !query.To.IsZero() && timeOfScanKey(key).After(query.To) {
+				break
+			}
+
+			var scan Scan
+			if err := json.Unmarshal(value, &scan); err != nil {
+				log.Warnf("Ignoring unreadable scan in history: %s", err)
+				continue
+			}
+
+			if matches(query.Environment, scan.Environment) && matches(query.Disk, scan.Disk) {
+				r = append(r, scan)
+			}
+		}
+
+		return nil
+	})
+
+	if query.Limit > 0 && len(r) > query.Limit {
+		r = r[len(r)-query.Limit:]
+	}
+
+	return r, err
+}
+
+// Files Return the observed backup files matching the query which have existed between `From` and `To`, ordered by
+// their sort time
+func (s *Store) Files(query Query) ([]Observation, error) {
+	var r []Observation
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketFiles).ForEach(func(key []byte, value []byte) error {
+			var observation Observation
+			if err := json.Unmarshal(value, &observation); err != nil {
+				log.Warnf("Ignoring unreadable file in history: %s", err)
+				return nil
+			}
+
+			if observation.DisappearedAt == nil {
+				observation.LastSeen = lastSeen(&observation, latestScan(tx, &observation))
+			}
+
+			if !matches(query.Environment, observation.Environment) ||
+				!matches(query.Disk, observation.Disk) ||
+				!matches(query.Directory, observation.Directory) ||
+				!matches(query.File, observation.File) ||
+				!matches(query.Group, observation.Group) {
+				return nil
+			}
+
+			if !query.To.IsZero() && observation.FirstSeen.After(query.To) {
+				return nil
+			}
+
+			if !query.From.IsZero() && observation.DisappearedAt != nil && observation.DisappearedAt.Before(query.From) {
+				return nil
+			}
+
+			r = append(r, observation)
+
+			return nil
+		})
+	})
+
+	sort.SliceStable(r, func(i int, j int) bool {
+		return r[i].SortTime.Before(r[j].SortTime)
+	})
+
+	if query.Limit > 0 && len(r) > query.Limit {
+		r = r[len(r)-query.Limit:]
+	}
+
+	return r, err
+}
+
+// Summarize Return the amount of files and bytes per file definition
+func Summarize(observations []Observation) []DefinitionUsage {
+	var r []DefinitionUsage
+	index := make(map[string]int)
+
+	for _, observation := range observations {
+		key := observation.Directory + keySeparator + observation.File
+		i, exists := index[key]
+
+		if !exists {
+			i = len(r)
+			index[key] = i
+			r = append(r, DefinitionUsage{Directory: observation.Directory, File: observation.File})
+		}
+
+		r[i].Files++
+		r[i].Bytes += uint64(observation.Size)
+	}
+
+	return r
+}
+
+func matches(expected string, actual string) bool {
+	return expected == "" || expected == actual
+}
+
+func put(bucket *bolt.Bucket, key []byte, observation *Observation) error {
+	data, err := json.Marshal(observation)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(key, data)
+}
+
+func fileKey(observation *Observation) []byte {
+	return []byte(observation.Environment + keySeparator + observation.Disk + keySeparator + observation.Parent + "/" + observation.Name)
+}
+
+func diskKey(environment string, disk string) []byte {
+	return []byte(environment + keySeparator + disk)
+}
+
+// latestScan Return the key of the latest complete scan of the disk of the observation; nil if there is none or the
+// database has been created by a previous version and is opened read-only
+func latestScan(tx *bolt.Tx, observation *Observation) []byte {
+	disks := tx.Bucket(bucketDisks)
+
+	if disks == nil {
+		return nil
+	}
+
+	return disks.Get(diskKey(observation.Environment, observation.Disk))
+}
+
+// lastSeen Return the latest observation of an existing file; this is either the given complete scan of the disk or the
+// stored observation, whichever is later
+func lastSeen(observation *Observation, latestScan []byte) time.Time {
+	if len(latestScan) < 8 {
+		return observation.LastSeen
+	}
+
+	if scannedAt := timeOfScanKey(latestScan); scannedAt.After(observation.LastSeen) {
+		return scannedAt
+	}
+
+	return observation.LastSeen
+}
+
+// scans are keyed by their big-endian timestamp, so that they are ordered by time
+func timeKey(moment time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(moment.UnixNano()))
+
+	return key
+}
+
+func scanKey(scan Scan) []byte {
+	return append(timeKey(scan.Time), []byte(scan.Environment+keySeparator+scan.Disk)...)
+}
+
+func timeOfScanKey(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8]))).UTC()
+}
@@ -0,0 +1,178 @@
+package history
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T) *Store {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "history.db"), 30*24*time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func observation(name string, size int64) Observation {
+	return Observation{Environment: "env", Disk: "disk", Directory: "dir", File: "dump", Group: ".", Name: name, Parent: "/backups", Size: size}
+}
+
+func Test_GH33_Record_tracksFirstSeenAndDisappearance(t *testing.T) {
+	assertion := assert.New(t)
+	store := openTestStore(t)
+	first := time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	third := second.Add(time.Hour)
+
+	assertion.NoError(store.Record(Scan{Time: first, Environment: "env", Disk: "disk"}, []Observation{observation("a", 1), observation("b", 2)}, true))
+	assertion.NoError(store.Record(Scan{Time: second, Environment: "env", Disk: "disk"}, []Observation{observation("b", 2), observation("c", 3)}, true))
+	// an incomplete scan must not mark any file as disappeared
+	assertion.NoError(store.Record(Scan{Time: third, Environment: "env", Disk: "disk"}, nil, false))
+
+	files, err := store.Files(Query{})
+	assertion.NoError(err)
+	assertion.Equal(3, len(files))
+
+	byName := make(map[string]Observation)
+	for _, file := range files {
+		byName[file.Name] = file
+	}
+
+	assertion.Equal(first, byName["a"].FirstSeen)
+	assertion.Equal(second, *byName["a"].DisappearedAt)
+	assertion.Equal(first, byName["b"].FirstSeen)
+	assertion.Equal(second, byName["b"].LastSeen)
+	assertion.Nil(byName["b"].DisappearedAt)
+	assertion.Equal(second, byName["c"].FirstSeen)
+
+	// only files existing in the time range are returned
+	files, err = store.Files(Query{From: second.Add(time.Minute)})
+	assertion.NoError(err)
+	assertion.Equal(2, len(files))
+}
+
+func Test_GH33_Scans_filtersByTimeRange(t *testing.T) {
+	assertion := assert.New(t)
+	store := openTestStore(t)
+	start := time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		assertion.NoError(store.Record(Scan{Time: start.Add(time.Duration(i) * time.Hour), Environment: "env", Disk: "disk", Bytes: uint64(i)}, nil, false))
+	}
+
+	scans, err := store.Scans(Query{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)})
+	assertion.NoError(err)
+	assertion.Equal(3, len(scans))
+	assertion.Equal(uint64(1), scans[0].Bytes)
+
+	scans, err = store.Scans(Query{Limit: 2})
+	assertion.NoError(err)
+	assertion.Equal(2, len(scans))
+	assertion.Equal(uint64(4), scans[1].Bytes)
+
+	scans, err = store.Scans(Query{Disk: "other"})
+	assertion.NoError(err)
+	assertion.Empty(scans)
+}
+
+func Test_GH33_Prune_removesExpiredEntries(t *testing.T) {
+	assertion := assert.New(t)
+	store := openTestStore(t)
+	old := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)
+
+	assertion.NoError(store.Record(Scan{Time: old, Environment: "env", Disk: "disk"}, []Observation{observation("a", 1), observation("b", 1)}, true))
+	assertion.NoError(store.Record(Scan{Time: old.Add(time.Hour), Environment: "env", Disk: "disk"}, []Observation{observation("b", 1)}, true))
+	assertion.NoError(store.Record(Scan{Time: recent, Environment: "env", Disk: "disk"}, []Observation{observation("b", 1)}, true))
+
+	assertion.NoError(store.Prune(recent))
+
+	scans, _ := store.Scans(Query{})
+	files, _ := store.Files(Query{})
+
+	assertion.Equal(1, len(scans))
+	assertion.Equal(1, len(files))
+	assertion.Equal("b", files[0].Name)
+}
+
+func Test_GH33_Summarize(t *testing.T) {
+	assertion := assert.New(t)
+	other := observation("c", 5)
+	other.File = "other"
+
+	usage := Summarize([]Observation{observation("a", 1), observation("b", 2), other})
+
+	assertion.Equal([]DefinitionUsage{
+		{Directory: "dir", File: "dump", Files: 2, Bytes: 3},
+		{Directory: "dir", File: "other", Files: 1, Bytes: 5},
+	}, usage)
+}
+
+func Test_GH33_Record_writesOnlyChangedFiles(t *testing.T) {
+	assertion := assert.New(t)
+	store := openTestStore(t)
+	first := time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)
+	stored := func() Observation {
+		var r Observation
+
+		_ = store.db.View(func(tx *bolt.Tx) error {
+			o := observation("a", 1)
+			return json.Unmarshal(tx.Bucket(bucketFiles).Get(fileKey(&o)), &r)
+		})
+
+		return r
+	}
+
+	for i := 0; i < 3; i++ {
+		assertion.NoError(store.Record(Scan{Time: first.Add(time.Duration(i) * time.Hour), Environment: "env", Disk: "disk"}, []Observation{observation("a", 1)}, true))
+	}
+
+	assertion.Equal(first, stored().LastSeen)
+
+	files, err := store.Files(Query{})
+	assertion.NoError(err)
+	assertion.Equal(first.Add(2*time.Hour), files[0].LastSeen)
+
+	// modified files are written again
+	assertion.NoError(store.Record(Scan{Time: first.Add(3 * time.Hour), Environment: "env", Disk: "disk"}, []Observation{observation("a", 2)}, true))
+	assertion.Equal(first.Add(3*time.Hour), stored().LastSeen)
+	assertion.Equal(int64(2), stored().Size)
+
+	// disappeared files have been seen by the previous scan for the last time
+	assertion.NoError(store.Record(Scan{Time: first.Add(4 * time.Hour), Environment: "env", Disk: "disk"}, nil, true))
+	assertion.NoError(store.Record(Scan{Time: first.Add(5 * time.Hour), Environment: "env", Disk: "disk"}, nil, true))
+
+	files, err = store.Files(Query{})
+	assertion.NoError(err)
+	assertion.Equal(first.Add(3*time.Hour), files[0].LastSeen)
+	assertion.Equal(first.Add(4*time.Hour), *files[0].DisappearedAt)
+}
+
+func Test_GH34_openStore_readsReadOnly(t *testing.T) {
+	assertion := assert.New(t)
+	path := filepath.Join(t.TempDir(), "history.db")
+	now := time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)
+
+	store, err := OpenStore(path, time.Hour)
+	assertion.NoError(err)
+	assertion.NoError(store.Record(Scan{Time: now, Environment: "env", Disk: "disk"}, []Observation{observation("a", 1)}, true))
+	assertion.NoError(store.Close())
+
+	store, err = openStore(path, time.Hour, true)
+	assertion.NoError(err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	files, err := store.Files(Query{})
+	assertion.NoError(err)
+	assertion.Len(files, 1)
+	assertion.Error(store.Record(Scan{Time: now.Add(time.Hour), Environment: "env", Disk: "disk"}, nil, true))
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/history"
 	"github.com/dreitier/backmon/metrics"
+	"github.com/dreitier/backmon/notify"
+	"github.com/dreitier/backmon/report"
 	"github.com/dreitier/backmon/storage"
+	"github.com/dreitier/backmon/tui"
 	"github.com/dreitier/backmon/web"
-	termbox "github.com/nsf/termbox-go"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -32,6 +36,13 @@ func printVersion() {
 
 func main() {
 	flag.Parse()
+
+	// #34: `backmon report` only prints the SLA report
+	if flag.Arg(0) == "report" {
+		configureLogger()
+		os.Exit(runReport(flag.Args()[1:]))
+	}
+
 	configureLogger()
 	configureTerminal()
 	configureSignals()
@@ -45,7 +56,17 @@ func main() {
 	// #13: update number of total environments
 	metrics.GetApplicationMetrics().EnvironmentsTotal.Set(config.GetInstance().TotalEnvironments())
 
+	// #33: the history is optional, backmon works without it
+	if err := history.Open(config.GetInstance().Global().History()); err != nil {
+		log.Errorf("Unable to open history, continuing without it: %s", err)
+	}
+
+	// #33: pending writes must be flushed and the lock of the database released
+	defer history.Close()
+
 	storage.InitializeConfiguration()
+	// #37: must be registered before the first update
+	notify.Start(config.GetInstance().Notifications())
 	scheduleDiskUpdates()
 
 	// #12: in case of an error during webserver startup (e.g. missing certificate or privat key), the console output gets scrambled.
@@ -53,49 +74,89 @@ func main() {
 	web.StartServer()
 }
 
-func configureTerminal() {
-	if config.IsRunningInBackgroundForced() {
-		return
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	days := flags.Int("days", 30, "Length of the report period in days")
+	format := flags.String("format", report.FormatMarkdown, "Output format: markdown, html, csv or json")
+	grace := flags.Duration("grace", 0, "Backups modified later than this duration after their scheduled run are late; 0 means before the next run")
+	output := flags.String("output", "", "Write the report to this file instead of stdout")
+	// #34: the history is locked by a running instance, which generates the report instead
+	baseURL := flags.String("url", "", "Request the report from the running instance at this URL, e.g. http://localhost:8080")
+	token := flags.String("token", os.Getenv("BACKMON_TOKEN"), "API token for -url; defaults to $BACKMON_TOKEN")
+	options := report.Options{}
+	flags.StringVar(&options.Environment, "env", "", "Only report this environment")
+	flags.StringVar(&options.Disk, "disk", "", "Only report this disk")
+	flags.StringVar(&options.Directory, "dir", "", "Only report this directory")
+	flags.StringVar(&options.File, "file", "", "Only report this file definition")
+	_ = flags.Parse(args)
+
+	options.Days = *days
+	options.Grace = *grace
+
+	if !report.IsFormat(*format) {
+		log.Errorf("Unknown report format '%s'", *format)
+		return 2
+	}
+
+	out := os.Stdout
+	var err error
+
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			log.Errorf("Unable to create report file: %s", err)
+			return 1
+		}
+
+		defer func() { _ = out.Close() }()
 	}
 
-	// #7: allow manual refreshing of disks
-	// set up termbox, @see https://github.com/nsf/termbox-go/blob/master/_demos/raw_input.go
-	err := termbox.Init()
+	if *baseURL != "" {
+		err = report.Fetch(out, *baseURL, *token, options, *format)
+	} else {
+		err = generateReport(out, options, *format)
+	}
 
 	if err != nil {
-		log.Warnf("Unable to run in interactive mode: %s", err)
+		log.Errorf("Unable to create report: %s", err)
+		return 1
+	}
+
+	return 0
+}
+
+// generateReport generates the report from the history, which can't be opened while another backmon instance is using it
+func generateReport(out io.Writer, options report.Options, format string) error {
+	if !config.HasGlobalDebugEnabled() {
+		log.SetLevel(config.GetInstance().Global().LogLevel())
+	}
+
+	if err := history.OpenReadOnly(config.GetInstance().Global().History()); err != nil {
+		return fmt.Errorf("unable to open history: %w; use -url to request the report from the running instance instead", err)
+	}
+
+	defer history.Close()
+
+	storage.InitializeConfiguration()
+	storage.LoadDefinitions()
+
+	generated, err := report.Generate(history.GetInstance(), storage.GetFileDefinitions(), options, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return generated.Write(out, format)
+}
+
+func configureTerminal() {
+	if config.IsRunningInBackgroundForced() {
 		return
 	}
 
-	// start goroutine to continuously poll the keyboard
-	go func() {
-		for {
-			var current string
-			var data [64]byte
-
-			// we have to poll the raw events; normal events don't include escape sequences
-			switch ev := termbox.PollRawEvent(data[:]); ev.Type {
-			case termbox.EventRaw:
-				d := data[:ev.N]
-				current = fmt.Sprintf("%q", d)
-
-				// handle disk refresh
-				if current == `"\x12"` /* Ctrl+R */ || current == `"r"` {
-					log.Printf("Forcing reload...")
-					storage.UpdateDiskInfo()
-					// handle exiting
-				} else if current == `"\x1b"` /* ESC */ || current == `"q"` || current == `"\x03"` {
-					log.Printf("Exiting...")
-					termbox.Close()
-					os.Exit(0)
-				}
-			case termbox.EventError:
-				panic(ev.Err)
-			default:
-				log.Tracef("unhandled default case %v", ev)
-			}
-		}
-	}()
+	// #7, #44: interactive dashboard; `r` rescans the selected disk, `q` exits
+	if err := tui.Start(); err != nil {
+		log.Warnf("Unable to run in interactive mode: %s", err)
+	}
 }
 
 func configureLogger() {
@@ -107,12 +168,18 @@ func configureLogger() {
 
 func configureSignals() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		for range c {
+		for sig := range c {
+			if sig != syscall.SIGHUP {
+				log.Printf("Got %s signal, exiting ...", sig)
+				history.Close()
+				os.Exit(0)
+			}
+
 			log.Printf("Got HUP signal, reloading ...")
-			storage.UpdateDiskInfo()
+			storage.TriggerScan("", "")
 		}
 	}()
 }
@@ -120,11 +187,12 @@ func configureSignals() {
 func scheduleDiskUpdates() {
 	updateInterval := config.GetInstance().Global().UpdateInterval()
 
+	// #39: scans triggered by the ticker are coalesced with the ones triggered through the API, the terminal or SIGHUP
 	ticker := time.NewTicker(updateInterval)
 	go func() {
-		storage.UpdateDiskInfo()
+		storage.TriggerScan("", "")
 		for range ticker.C {
-			storage.UpdateDiskInfo()
+			storage.TriggerScan("", "")
 		}
 	}()
 }
@@ -3,6 +3,7 @@ package metrics
 import (
 	"errors"
 	log "github.com/sirupsen/logrus"
+	"strconv"
 	"time"
 
 	fs "github.com/dreitier/backmon/storage/fs"
@@ -10,9 +11,15 @@ import (
 )
 
 const (
-	LabelNameDir   = "dir"
-	LabelNameFile  = "file"
-	LabelNameGroup = "group"
+	LabelNameDir      = "dir"
+	LabelNameFile     = "file"
+	LabelNameGroup    = "group"
+	LabelNameBucket   = "bucket"
+	LabelNameDryRun   = "dry_run"
+	LabelNameGuard    = "guard"
+	LabelNameAction   = "action"
+	LabelNameKind     = "kind"
+	LabelNameCurrency = "currency"
 )
 
 type DiskMetric struct {
@@ -24,6 +31,7 @@ type DiskMetric struct {
 	fileCount                    *prometheus.GaugeVec
 	fileAgeThreshold             *prometheus.GaugeVec
 	fileYoungCount               *prometheus.GaugeVec
+	fileRetainedCount            *prometheus.GaugeVec
 	latestFileCreationExpectedAt *prometheus.GaugeVec
 	latestFileCreatedAt          *prometheus.GaugeVec
 	latestFileCreationDuration   *prometheus.GaugeVec
@@ -31,6 +39,25 @@ type DiskMetric struct {
 	latestFileModifiedAt         *prometheus.GaugeVec
 	latestFileArchivedAt         *prometheus.GaugeVec
 	latestSize                   *prometheus.GaugeVec
+	immutable                    *prometheus.GaugeVec
+	latestFileRetainUntil        *prometheus.GaugeVec
+	purgedFiles                  *prometheus.CounterVec
+	purgedBytes                  *prometheus.CounterVec
+	purgeRefused                 *prometheus.CounterVec
+	anomalyFiles                 *prometheus.GaugeVec
+	diskUsageGrowth              *prometheus.GaugeVec
+	diskUsageProjected           *prometheus.GaugeVec
+	diskQuotaExhaustion          *prometheus.GaugeVec
+	fileGrowth                   *prometheus.GaugeVec
+	diskCost                     *prometheus.GaugeVec
+	dirCost                      *prometheus.GaugeVec
+	fileCost                     *prometheus.GaugeVec
+	fileCostSavings              *prometheus.GaugeVec
+	anomaliesDetected            *prometheus.CounterVec
+	jobLastSuccess               *prometheus.GaugeVec
+	jobLastFailure               *prometheus.GaugeVec
+	jobDuration                  *prometheus.GaugeVec
+	jobUnconfirmed               *prometheus.GaugeVec
 }
 
 func NewDisk(diskName string) *DiskMetric {
@@ -105,6 +132,18 @@ func NewDisk(diskName string) *DiskMetric {
 			LabelNameFile,
 			LabelNameGroup,
 		}),
+		fileRetainedCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "file_retained_count",
+			Help:        "The amount of backup files in this group that are kept by the given retention bucket (latest, daily, weekly, monthly or yearly).",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+			LabelNameBucket,
+		}),
 		latestFileCreationExpectedAt: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace:   namespace,
 			Subsystem:   subsystemBackup,
@@ -181,6 +220,193 @@ func NewDisk(diskName string) *DiskMetric {
 			LabelNameFile,
 			LabelNameGroup,
 		}),
+		immutable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "immutable",
+			Help:        "Indicates whether the latest backup in the corresponding file group satisfies the required immutability (1) or not (0).",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+		}),
+		latestFileRetainUntil: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "latest_file_retain_until_timestamp_seconds",
+			Help:        "Unix timestamp until which the latest backup in the corresponding file group is locked against deletion.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+		}),
+		purgedFiles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "purged_files_total",
+			Help:        "The amount of purged files. The action is either delete or move. With dry_run=\"true\", the files have only been reported as purge candidates.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameAction,
+			LabelNameDryRun,
+		}),
+		purgedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "purged_bytes_total",
+			Help:        "The amount of bytes of purged files. The action is either delete or move. With dry_run=\"true\", the files have only been reported as purge candidates.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameAction,
+			LabelNameDryRun,
+		}),
+		purgeRefused: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "purge_refused_total",
+			Help:        "The amount of purge runs refused by a safety guard (healthy, max_files, max_bytes, late or definitions_changed).",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGuard,
+		}),
+		diskUsageGrowth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "disk_usage_growth_bytes_per_day",
+			Help:        "The growth of the amount of bytes used on a disk, based upon a linear regression of the usage history.",
+			ConstLabels: presetLabels,
+		}, []string{}),
+		diskUsageProjected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "disk_usage_projected_bytes",
+			Help:        "The projected amount of bytes used on a disk at the end of the forecast horizon (default: 30 days).",
+			ConstLabels: presetLabels,
+		}, []string{}),
+		diskQuotaExhaustion: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "disk_quota_exhaustion_timestamp_seconds",
+			Help:        "Unix timestamp on which the disk usage is projected to reach the disk quota. Not present if there is no quota or the usage does not grow.",
+			ConstLabels: presetLabels,
+		}, []string{}),
+		fileGrowth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "growth_bytes_per_day",
+			Help:        "The growth of the amount of bytes used by all backup files of the file definition, based upon a linear regression of the usage history.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+		}),
+		diskCost: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "disk_cost_monthly",
+			Help:        "The estimated monthly storage costs of all files on a disk, based upon the configured prices of each storage class.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameCurrency,
+		}),
+		dirCost: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "directory_cost_monthly",
+			Help:        "The estimated monthly storage costs of all backup files of the directory's file definitions.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameCurrency,
+		}),
+		fileCost: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "cost_monthly",
+			Help:        "The estimated monthly storage costs of all backup files of the file definition.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameCurrency,
+		}),
+		fileCostSavings: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "cost_savings_monthly",
+			Help:        "The estimated monthly storage costs of the backup files which are not retained by the retention policy of the file definition, i.e. which are or would be purged; 0 without a retention policy.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameCurrency,
+		}),
+		jobLastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "job_last_success_timestamp_seconds",
+			Help:        "The time at which the backup job of this group has reported its latest success.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+		}),
+		jobLastFailure: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "job_last_failure_timestamp_seconds",
+			Help:        "The time at which the backup job of this group has reported its latest failure.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+		}),
+		jobDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "job_duration_seconds",
+			Help:        "The duration of the latest finished run reported by the backup job of this group.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+		}),
+		jobUnconfirmed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemBackup,
+			Name:        "job_unconfirmed",
+			Help:        "Indicates whether the backup job of this group has reported a success, but no backup file has appeared within the grace period.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameDir,
+			LabelNameFile,
+			LabelNameGroup,
+		}),
+		anomalyFiles: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemAnomaly,
+			Name:        "files",
+			Help:        "The amount of files flagged by the given anomaly heuristic (modified, shrunk, renamed, deleted or entropy) in the latest scan.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameKind,
+		}),
+		anomaliesDetected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystemAnomaly,
+			Name:        "detected_total",
+			Help:        "The amount of scans in which the given anomaly heuristic (modified, shrunk, renamed, deleted or entropy) has been triggered.",
+			ConstLabels: presetLabels,
+		}, []string{
+			LabelNameKind,
+		}),
 	}
 	registry.MustRegister(disk.status)
 	registry.MustRegister(disk.fileCountTotal)
@@ -189,6 +415,7 @@ func NewDisk(diskName string) *DiskMetric {
 	registry.MustRegister(disk.fileCount)
 	registry.MustRegister(disk.fileAgeThreshold)
 	registry.MustRegister(disk.fileYoungCount)
+	registry.MustRegister(disk.fileRetainedCount)
 	registry.MustRegister(disk.latestFileCreationExpectedAt)
 	registry.MustRegister(disk.latestFileCreatedAt)
 	registry.MustRegister(disk.latestFileCreationDuration)
@@ -196,6 +423,25 @@ func NewDisk(diskName string) *DiskMetric {
 	registry.MustRegister(disk.latestFileModifiedAt)
 	registry.MustRegister(disk.latestFileArchivedAt)
 	registry.MustRegister(disk.latestSize)
+	registry.MustRegister(disk.immutable)
+	registry.MustRegister(disk.latestFileRetainUntil)
+	registry.MustRegister(disk.purgedFiles)
+	registry.MustRegister(disk.purgedBytes)
+	registry.MustRegister(disk.purgeRefused)
+	registry.MustRegister(disk.anomalyFiles)
+	registry.MustRegister(disk.diskUsageGrowth)
+	registry.MustRegister(disk.diskUsageProjected)
+	registry.MustRegister(disk.diskQuotaExhaustion)
+	registry.MustRegister(disk.fileGrowth)
+	registry.MustRegister(disk.diskCost)
+	registry.MustRegister(disk.dirCost)
+	registry.MustRegister(disk.fileCost)
+	registry.MustRegister(disk.fileCostSavings)
+	registry.MustRegister(disk.anomaliesDetected)
+	registry.MustRegister(disk.jobLastSuccess)
+	registry.MustRegister(disk.jobLastFailure)
+	registry.MustRegister(disk.jobDuration)
+	registry.MustRegister(disk.jobUnconfirmed)
 	return disk
 }
 
@@ -208,6 +454,7 @@ func (b *DiskMetric) Drop() {
 	registry.Unregister(b.fileCount)
 	registry.Unregister(b.fileAgeThreshold)
 	registry.Unregister(b.fileYoungCount)
+	registry.Unregister(b.fileRetainedCount)
 	registry.Unregister(b.latestFileCreationExpectedAt)
 	registry.Unregister(b.latestFileCreatedAt)
 	registry.Unregister(b.latestFileCreationDuration)
@@ -215,6 +462,25 @@ func (b *DiskMetric) Drop() {
 	registry.Unregister(b.latestFileModifiedAt)
 	registry.Unregister(b.latestFileArchivedAt)
 	registry.Unregister(b.latestSize)
+	registry.Unregister(b.immutable)
+	registry.Unregister(b.latestFileRetainUntil)
+	registry.Unregister(b.purgedFiles)
+	registry.Unregister(b.purgedBytes)
+	registry.Unregister(b.purgeRefused)
+	registry.Unregister(b.anomalyFiles)
+	registry.Unregister(b.diskUsageGrowth)
+	registry.Unregister(b.diskUsageProjected)
+	registry.Unregister(b.diskQuotaExhaustion)
+	registry.Unregister(b.fileGrowth)
+	registry.Unregister(b.diskCost)
+	registry.Unregister(b.dirCost)
+	registry.Unregister(b.fileCost)
+	registry.Unregister(b.fileCostSavings)
+	registry.Unregister(b.anomaliesDetected)
+	registry.Unregister(b.jobLastSuccess)
+	registry.Unregister(b.jobLastFailure)
+	registry.Unregister(b.jobDuration)
+	registry.Unregister(b.jobUnconfirmed)
 
 	GetApplicationMetrics().disksTotal.Dec()
 }
@@ -224,6 +490,7 @@ func (b *DiskMetric) resetMetrics() {
 	b.fileCount.Reset()
 	b.fileAgeThreshold.Reset()
 	b.fileYoungCount.Reset()
+	b.fileRetainedCount.Reset()
 	b.latestFileCreationExpectedAt.Reset()
 	b.latestFileCreatedAt.Reset()
 	b.latestFileCreationDuration.Reset()
@@ -231,6 +498,16 @@ func (b *DiskMetric) resetMetrics() {
 	b.latestFileModifiedAt.Reset()
 	b.latestFileArchivedAt.Reset()
 	b.latestSize.Reset()
+	b.immutable.Reset()
+	b.latestFileRetainUntil.Reset()
+	b.fileGrowth.Reset()
+	b.dirCost.Reset()
+	b.fileCost.Reset()
+	b.fileCostSavings.Reset()
+	b.jobLastSuccess.Reset()
+	b.jobLastFailure.Reset()
+	b.jobDuration.Reset()
+	b.jobUnconfirmed.Reset()
 }
 
 func (b *DiskMetric) DefinitionsMissing() {
@@ -264,6 +541,12 @@ func (b *DiskMetric) UpdateFileCounts(dir string, file string, group string, pre
 	}
 }
 
+func (b *DiskMetric) UpdateRetainedCounts(dir string, file string, group string, retained map[string]uint64) {
+	for bucket, count := range retained {
+		b.fileRetainedCount.WithLabelValues(dir, file, group, bucket).Set(float64(count))
+	}
+}
+
 func (b *DiskMetric) UpdateUsageStats(countTotal uint64, sizeTotal uint64) {
 	b.fileCountTotal.Set(float64(countTotal))
 	b.diskUsageTotal.Set(float64(sizeTotal))
@@ -292,6 +575,8 @@ func (b *DiskMetric) deleteLatestFileLabels(labels map[string]string) {
 	b.latestFileModifiedAt.Delete(labels)
 	b.latestFileArchivedAt.Delete(labels)
 	b.latestSize.Delete(labels)
+	b.immutable.Delete(labels)
+	b.latestFileRetainUntil.Delete(labels)
 }
 
 func (b *DiskMetric) UpdateLatestFile(dir string, file string, group string, fileInfo *fs.FileInfo, time time.Time) {
@@ -311,6 +596,119 @@ func (b *DiskMetric) DropFile(dir string, file string, group string) {
 
 	b.fileCount.Delete(labels)
 	b.fileYoungCount.Delete(labels)
+	b.fileRetainedCount.DeletePartialMatch(labels)
+	b.jobLastSuccess.Delete(labels)
+	b.jobLastFailure.Delete(labels)
+	b.jobDuration.Delete(labels)
+	b.jobUnconfirmed.Delete(labels)
 
 	b.deleteLatestFileLabels(labels)
 }
+
+func (b *DiskMetric) FilePurged(dir string, file string, action string, dryRun bool, size int64) {
+	b.purgedFiles.WithLabelValues(dir, file, action, strconv.FormatBool(dryRun)).Inc()
+	b.purgedBytes.WithLabelValues(dir, file, action, strconv.FormatBool(dryRun)).Add(float64(size))
+}
+
+func (b *DiskMetric) PurgeRefused(dir string, file string, guard string) {
+	b.purgeRefused.WithLabelValues(dir, file, guard).Inc()
+}
+
+func (b *DiskMetric) UpdateImmutability(dir string, file string, group string, immutable bool, retainUntil time.Time) {
+	value := float64(0)
+
+	if immutable {
+		value = 1
+	}
+
+	b.immutable.WithLabelValues(dir, file, group).Set(value)
+
+	if retainUntil.IsZero() {
+		b.latestFileRetainUntil.DeleteLabelValues(dir, file, group)
+	} else {
+		b.latestFileRetainUntil.WithLabelValues(dir, file, group).Set(float64(retainUntil.Unix()))
+	}
+}
+
+// UpdateAnomalies reports the amount of flagged files per anomaly kind; kinds without flagged files are reported as 0
+func (b *DiskMetric) UpdateAnomalies(kinds []string, flagged map[string]int) {
+	for _, kind := range kinds {
+		count := flagged[kind]
+		b.anomalyFiles.WithLabelValues(kind).Set(float64(count))
+
+		if count > 0 {
+			b.anomaliesDetected.WithLabelValues(kind).Inc()
+		}
+	}
+}
+
+// UpdateUsageForecast reports the growth and projection of the disk usage; without a trend, the metrics are removed
+func (b *DiskMetric) UpdateUsageForecast(hasTrend bool, growthPerDay float64, projected float64, exhaustion time.Time) {
+	if !hasTrend {
+		b.diskUsageGrowth.Reset()
+		b.diskUsageProjected.Reset()
+		b.diskQuotaExhaustion.Reset()
+		return
+	}
+
+	b.diskUsageGrowth.WithLabelValues().Set(growthPerDay)
+	b.diskUsageProjected.WithLabelValues().Set(projected)
+
+	if exhaustion.IsZero() {
+		b.diskQuotaExhaustion.Reset()
+	} else {
+		b.diskQuotaExhaustion.WithLabelValues().Set(float64(exhaustion.Unix()))
+	}
+}
+
+func (b *DiskMetric) UpdateFileGrowth(dir string, file string, hasTrend bool, growthPerDay float64) {
+	if !hasTrend {
+		b.fileGrowth.DeleteLabelValues(dir, file)
+		return
+	}
+
+	b.fileGrowth.WithLabelValues(dir, file).Set(growthPerDay)
+}
+
+func (b *DiskMetric) UpdateDiskCost(currency string, cost float64) {
+	b.diskCost.Reset()
+	b.diskCost.WithLabelValues(currency).Set(cost)
+}
+
+func (b *DiskMetric) UpdateDirectoryCost(dir string, currency string, cost float64) {
+	b.dirCost.WithLabelValues(dir, currency).Set(cost)
+}
+
+func (b *DiskMetric) UpdateFileCost(dir string, file string, currency string, cost float64, savings float64) {
+	b.fileCost.WithLabelValues(dir, file, currency).Set(cost)
+	b.fileCostSavings.WithLabelValues(dir, file, currency).Set(savings)
+}
+
+// UpdateJob updates the metrics reported by the backup job of a group; zero times and durations are not reported, see #38
+func (b *DiskMetric) UpdateJob(dir string, file string, group string, lastSuccess time.Time, lastFailure time.Time, duration time.Duration) {
+	if !lastSuccess.IsZero() {
+		b.jobLastSuccess.WithLabelValues(dir, file, group).Set(float64(lastSuccess.Unix()))
+	}
+
+	if !lastFailure.IsZero() {
+		b.jobLastFailure.WithLabelValues(dir, file, group).Set(float64(lastFailure.Unix()))
+	}
+
+	if duration > 0 {
+		b.jobDuration.WithLabelValues(dir, file, group).Set(duration.Seconds())
+	}
+}
+
+// UpdateJobConfirmation reports whether a file has appeared after the latest success of the backup job. If the job
+// has reported its duration, it replaces the approximated creation duration of the latest file.
+func (b *DiskMetric) UpdateJobConfirmation(dir string, file string, group string, unconfirmed bool, duration time.Duration) {
+	if unconfirmed {
+		b.jobUnconfirmed.WithLabelValues(dir, file, group).Set(1)
+	} else {
+		b.jobUnconfirmed.WithLabelValues(dir, file, group).Set(0)
+	}
+
+	if duration > 0 && !unconfirmed {
+		b.latestFileCreationDuration.WithLabelValues(dir, file, group).Set(duration.Seconds())
+	}
+}
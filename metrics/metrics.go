@@ -13,6 +13,9 @@ const (
 	subsystemBackup       = "backup"
 	subsystemEnvironments = "environments"
 	subsystemDisks        = "disks"
+	subsystemReplica      = "replica"
+	subsystemAnomaly      = "anomaly"
+	subsystemNotification = "notification"
 )
 
 var (
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	LabelNameChannel = "channel"
+	LabelNameResult  = "result"
+)
+
+// NotificationMetrics describe the notifications sent by the built-in alerting, see #37
+type NotificationMetrics struct {
+	sent   *prometheus.CounterVec
+	firing prometheus.Gauge
+}
+
+var (
+	notificationMetrics *NotificationMetrics
+	notificationOnce    sync.Once
+)
+
+func GetNotificationMetrics() *NotificationMetrics {
+	notificationOnce.Do(func() {
+		notificationMetrics = &NotificationMetrics{
+			sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystemNotification,
+				Name:      "sent_total",
+				Help:      "Number of notifications sent to a channel, either successfully or failed",
+			}, []string{LabelNameChannel, LabelNameResult}),
+			firing: prometheus.NewGauge(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemNotification,
+				Name:      "alerts_firing",
+				Help:      "Number of currently firing alerts",
+			}),
+		}
+
+		registry.MustRegister(notificationMetrics.sent)
+		registry.MustRegister(notificationMetrics.firing)
+	})
+
+	return notificationMetrics
+}
+
+func (m *NotificationMetrics) Sent(channel string, err error) {
+	result := "success"
+
+	if err != nil {
+		result = "failure"
+	}
+
+	m.sent.WithLabelValues(channel, result).Inc()
+}
+
+func (m *NotificationMetrics) UpdateFiring(firing int) {
+	m.firing.Set(float64(firing))
+}
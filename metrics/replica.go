@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	LabelNameRule = "rule"
+)
+
+// ReplicaMetrics describe the replication of backups between environments, see #31
+type ReplicaMetrics struct {
+	lag     *prometheus.GaugeVec
+	missing *prometheus.GaugeVec
+}
+
+var (
+	replicaMetrics *ReplicaMetrics
+	replicaOnce    sync.Once
+)
+
+func GetReplicaMetrics() *ReplicaMetrics {
+	replicaOnce.Do(func() {
+		replicaMetrics = &ReplicaMetrics{
+			lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemReplica,
+				Name:      "lag_seconds",
+				Help:      "Seconds between the latest source file and its replica; if a replica is missing, the seconds since the oldest source file without replica has been modified",
+			}, []string{LabelNameRule, LabelNameGroup}),
+			missing: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemReplica,
+				Name:      "missing",
+				Help:      "Indicates whether the replica of a source file is missing after the maximum lag has passed",
+			}, []string{LabelNameRule, LabelNameGroup}),
+		}
+
+		registry.MustRegister(replicaMetrics.lag)
+		registry.MustRegister(replicaMetrics.missing)
+	})
+
+	return replicaMetrics
+}
+
+func (m *ReplicaMetrics) Update(rule string, group string, lagSeconds float64, missing bool) {
+	m.lag.WithLabelValues(rule, group).Set(lagSeconds)
+
+	if missing {
+		m.missing.WithLabelValues(rule, group).Set(1)
+	} else {
+		m.missing.WithLabelValues(rule, group).Set(0)
+	}
+}
+
+// DropGroup removes the metrics of a vanished group
+func (m *ReplicaMetrics) DropGroup(rule string, group string) {
+	m.lag.DeleteLabelValues(rule, group)
+	m.missing.DeleteLabelValues(rule, group)
+}
+
+// Drop removes all metrics of a rule
+func (m *ReplicaMetrics) Drop(rule string) {
+	m.lag.DeletePartialMatch(prometheus.Labels{LabelNameRule: rule})
+	m.missing.DeletePartialMatch(prometheus.Labels{LabelNameRule: rule})
+}
@@ -0,0 +1,228 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// smtpTimeout limits the whole delivery of a mail, so that a hung SMTP server does not block the scans
+var smtpTimeout = 10 * time.Second
+
+// NewSender Return the sender of the channel's type
+func NewSender(cfg *config.ChannelConfiguration) Sender {
+	switch cfg.Type {
+	case config.ChannelTypeSMTP:
+		return &smtpSender{cfg: cfg}
+	case config.ChannelTypeSlack:
+		return &slackSender{cfg: cfg}
+	case config.ChannelTypeNtfy:
+		return &ntfySender{cfg: cfg}
+	default:
+		sender := &webhookSender{cfg: cfg}
+
+		if cfg.Template != "" {
+			// the template has already been validated when parsing the configuration
+			sender.body = template.Must(template.New(cfg.Name).Parse(cfg.Template))
+		}
+
+		return sender
+	}
+}
+
+// webhookSender posts the notification as JSON or renders the configured template
+type webhookSender struct {
+	cfg  *config.ChannelConfiguration
+	body *template.Template
+}
+
+func (s *webhookSender) Send(notification *Notification) error {
+	var body bytes.Buffer
+	contentType := "application/json"
+
+	if s.body != nil {
+		if err := s.body.Execute(&body, notification); err != nil {
+			return fmt.Errorf("unable to render template: %w", err)
+		}
+
+		contentType = "text/plain; charset=utf-8"
+	} else if err := json.NewEncoder(&body).Encode(notification); err != nil {
+		return err
+	}
+
+	return post(s.cfg.Method, s.cfg.URL, &body, contentType, s.cfg.Headers)
+}
+
+// slackSender posts to incoming webhooks of Slack, Mattermost and Microsoft Teams, which all accept a `text` property
+type slackSender struct {
+	cfg *config.ChannelConfiguration
+}
+
+func (s *slackSender) Send(notification *Notification) error {
+	data, err := json.Marshal(map[string]string{
+		"text": "*" + notification.Title() + "*\n" + notification.Text(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return post(http.MethodPost, s.cfg.URL, bytes.NewReader(data), "application/json", s.cfg.Headers)
+}
+
+// ntfySender publishes to a topic of ntfy, see https://docs.ntfy.sh/publish/
+type ntfySender struct {
+	cfg *config.ChannelConfiguration
+}
+
+func (s *ntfySender) Send(notification *Notification) error {
+	headers := map[string]string{
+		"Title": notification.Title(),
+		"Tags":  "white_check_mark",
+	}
+
+	if len(notification.Firing) > 0 {
+		headers["Tags"] = "warning"
+	}
+
+	if s.cfg.Priority != "" {
+		headers["Priority"] = s.cfg.Priority
+	}
+
+	if s.cfg.Token != "" {
+		headers["Authorization"] = "Bearer " + s.cfg.Token
+	}
+
+	for header, value := range s.cfg.Headers {
+		headers[header] = value
+	}
+
+	return post(http.MethodPost, s.cfg.URL, strings.NewReader(notification.Text()), "text/plain; charset=utf-8", headers)
+}
+
+type smtpSender struct {
+	cfg *config.ChannelConfiguration
+}
+
+func (s *smtpSender) Send(notification *Notification) error {
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	address := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+
+	return sendMail(address, s.cfg.Host, auth, s.cfg.From, s.cfg.To, s.message(notification))
+}
+
+// sendMail behaves like smtp.SendMail, but gives up after smtpTimeout
+func sendMail(address string, host string, auth smtp.Auth, from string, to []string, message []byte) error {
+	conn, err := net.DialTimeout("tcp", address, smtpTimeout)
+	if err != nil {
+		return err
+	}
+
+	if err = conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	defer func() { _ = client.Close() }()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+
+	for _, recipient := range to {
+		if err = client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = writer.Write(message); err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (s *smtpSender) message(notification *Notification) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("From: " + s.cfg.From + "\r\n")
+	b.WriteString("To: " + strings.Join(s.cfg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + notification.Title() + "\r\n")
+	b.WriteString("Date: " + notification.Time.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(notification.Text(), "\n", "\r\n"))
+
+	return b.Bytes()
+}
+
+func post(method string, url string, body io.Reader, contentType string, headers map[string]string) error {
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", contentType)
+
+	for header, value := range headers {
+		request.Header.Set(header, value)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %s", response.Status)
+	}
+
+	return nil
+}
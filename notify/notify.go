@@ -0,0 +1,311 @@
+package notify
+
+// Built-in alerting for sites without Prometheus and Alertmanager. After each update of the disks, the status of all
+// groups is evaluated: groups which are not ok fire an alert, which is routed to one or more channels. Firing alerts
+// are only sent again after the repeat interval; alerts which are not firing anymore are sent as resolved.
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/metrics"
+	"github.com/dreitier/backmon/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// labels of an alert, which can be matched by routes
+const (
+	LabelEnvironment = "environment"
+	LabelDisk        = "disk"
+	LabelDirectory   = "dir"
+	LabelFile        = "file"
+	LabelGroup       = "group"
+	LabelStatus      = "status"
+)
+
+type Alert struct {
+	Environment string `json:"environment"`
+	Disk        string `json:"disk"`
+	Directory   string `json:"dir,omitempty"`
+	File        string `json:"file,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Status      string `json:"status"`
+	// time of the latest backup file; zero if there is none
+	Latest time.Time `json:"latest,omitempty"`
+	// first evaluation which has fired the alert
+	Since time.Time `json:"since"`
+}
+
+func (a *Alert) Labels() map[string]string {
+	return map[string]string{
+		LabelEnvironment: a.Environment,
+		LabelDisk:        a.Disk,
+		LabelDirectory:   a.Directory,
+		LabelFile:        a.File,
+		LabelGroup:       a.Group,
+		LabelStatus:      a.Status,
+	}
+}
+
+// Fingerprint identifies the alert across evaluations
+func (a *Alert) Fingerprint() string {
+	return strings.Join([]string{a.Environment, a.Disk, a.Directory, a.File, a.Group, a.Status}, "|")
+}
+
+func (a *Alert) String() string {
+	r := a.Environment + "/" + a.Disk
+
+	if a.Directory != "" {
+		r += " " + a.Directory + "/" + a.File
+	}
+
+	if a.Group != "" {
+		r += " [" + a.Group + "]"
+	}
+
+	return r + ": " + a.Status
+}
+
+// Notification all alerts of a single evaluation which are sent to a channel
+type Notification struct {
+	Channel  string    `json:"channel"`
+	Time     time.Time `json:"time"`
+	Firing   []Alert   `json:"firing"`
+	Resolved []Alert   `json:"resolved"`
+}
+
+// Title Return a short summary of the notification
+func (n *Notification) Title() string {
+	if len(n.Firing) == 0 {
+		return fmt.Sprintf("[RESOLVED] %d backup alert(s) resolved", len(n.Resolved))
+	}
+
+	return fmt.Sprintf("[FIRING] %d backup alert(s) firing", len(n.Firing))
+}
+
+// Text Return the human-readable body of the notification
+func (n *Notification) Text() string {
+	var b strings.Builder
+
+	for _, alert := range n.Firing {
+		b.WriteString("FIRING " + alert.String())
+
+		if !alert.Latest.IsZero() {
+			b.WriteString(" (latest backup " + alert.Latest.UTC().Format(time.RFC3339) + ")")
+		}
+
+		b.WriteString("\n")
+	}
+
+	for _, alert := range n.Resolved {
+		b.WriteString("RESOLVED " + alert.String() + "\n")
+	}
+
+	return b.String()
+}
+
+// Sender delivers notifications to a channel
+type Sender interface {
+	Send(notification *Notification) error
+}
+
+type firingAlert struct {
+	alert Alert
+	// last notification per channel
+	notified map[string]time.Time
+}
+
+type Engine struct {
+	cfg     *config.NotificationsConfiguration
+	senders map[string]Sender
+	firing  map[string]*firingAlert
+	mutex   sync.Mutex
+}
+
+// NewEngine creates the engine with a sender for each configured channel
+func NewEngine(cfg *config.NotificationsConfiguration) *Engine {
+	senders := make(map[string]Sender, len(cfg.Channels))
+
+	for name, channel := range cfg.Channels {
+		senders[name] = NewSender(channel)
+	}
+
+	return &Engine{cfg: cfg, senders: senders, firing: make(map[string]*firingAlert)}
+}
+
+// Start evaluates the status of all groups after each update of the disks; without configured channels, nothing
+// happens
+func Start(cfg *config.NotificationsConfiguration) {
+	if cfg == nil {
+		log.Info("No notification channels configured, built-in alerting is disabled")
+		return
+	}
+
+	engine := NewEngine(cfg)
+
+	storage.OnUpdated(func() {
+		engine.Evaluate(AlertsOf(storage.GetStatuses()), time.Now())
+	})
+
+	log.Infof("Sending notifications to %d channel(s)", len(cfg.Channels))
+}
+
+// AlertsOf Return an alert for each status which is not ok
+func AlertsOf(statuses []storage.GroupStatus) []Alert {
+	var r []Alert
+
+	for _, status := range statuses {
+		if status.Status == storage.StatusOk {
+			continue
+		}
+
+		r = append(r, Alert{
+			Environment: status.Environment,
+			Disk:        status.Disk,
+			Directory:   status.Directory,
+			File:        status.File,
+			Group:       status.Group,
+			Status:      status.Status,
+			Latest:      status.Latest,
+		})
+	}
+
+	return r
+}
+
+// Evaluate compares the currently firing alerts with the ones of the previous evaluation and sends the notifications
+func (e *Engine) Evaluate(alerts []Alert, now time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	notifications := make(map[string]*Notification)
+	notification := func(channel string) *Notification {
+		if _, exists := notifications[channel]; !exists {
+			notifications[channel] = &Notification{Channel: channel, Time: now}
+		}
+
+		return notifications[channel]
+	}
+
+	current := make(map[string]bool, len(alerts))
+
+	for _, alert := range alerts {
+		fingerprint := alert.Fingerprint()
+		current[fingerprint] = true
+		state, exists := e.firing[fingerprint]
+
+		if !exists {
+			alert.Since = now
+			state = &firingAlert{alert: alert, notified: make(map[string]time.Time)}
+			e.firing[fingerprint] = state
+		} else {
+			alert.Since = state.alert.Since
+			state.alert = alert
+		}
+
+		// #48: silenced alerts keep firing, but are not sent until the silence has expired
+		if silenced(&alert, now) {
+			continue
+		}
+
+		for channel, repeatInterval := range e.route(&alert) {
+			last, notified := state.notified[channel]
+
+			if notified && now.Sub(last) < repeatInterval {
+				continue
+			}
+
+			state.notified[channel] = now
+			n := notification(channel)
+			n.Firing = append(n.Firing, alert)
+		}
+	}
+
+	for fingerprint, state := range e.firing {
+		if current[fingerprint] {
+			continue
+		}
+
+		delete(e.firing, fingerprint)
+
+		if !e.cfg.SendResolved {
+			continue
+		}
+
+		// only channels which have been notified about the alert are notified about its resolution
+		for channel := range state.notified {
+			n := notification(channel)
+			n.Resolved = append(n.Resolved, state.alert)
+		}
+	}
+
+	metrics.GetNotificationMetrics().UpdateFiring(len(e.firing))
+
+	for channel, n := range notifications {
+		sortAlerts(n.Firing)
+		sortAlerts(n.Resolved)
+
+		err := e.senders[channel].Send(n)
+		metrics.GetNotificationMetrics().Sent(channel, err)
+
+		if err != nil {
+			log.Errorf("Failed to send notification to channel '%s': %s", channel, err)
+
+			// try again on the next evaluation
+			for _, alert := range n.Firing {
+				delete(e.firing[alert.Fingerprint()].notified, channel)
+			}
+
+			continue
+		}
+
+		log.Infof("Sent notification with %d firing and %d resolved alert(s) to channel '%s'", len(n.Firing), len(n.Resolved), channel)
+	}
+}
+
+// route Return the channels of the alert and their repeat interval. Without routes, the alert is sent to all channels.
+func (e *Engine) route(alert *Alert) map[string]time.Duration {
+	r := make(map[string]time.Duration)
+
+	if len(e.cfg.Routes) == 0 {
+		for channel := range e.senders {
+			r[channel] = e.cfg.RepeatInterval
+		}
+
+		return r
+	}
+
+	labels := alert.Labels()
+
+	for _, route := range e.cfg.Routes {
+		if !route.Matches(labels) {
+			continue
+		}
+
+		repeatInterval := e.cfg.RepeatInterval
+		if route.RepeatInterval > 0 {
+			repeatInterval = route.RepeatInterval
+		}
+
+		for _, channel := range route.Channels {
+			if _, exists := r[channel]; !exists {
+				r[channel] = repeatInterval
+			}
+		}
+
+		if !route.Continue {
+			break
+		}
+	}
+
+	return r
+}
+
+func sortAlerts(alerts []Alert) {
+	sort.Slice(alerts, func(i int, j int) bool {
+		return alerts[i].Fingerprint() < alerts[j].Fingerprint()
+	})
+}
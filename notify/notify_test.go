@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSender struct {
+	sent []*Notification
+	err  error
+}
+
+func (s *recordingSender) Send(notification *Notification) error {
+	s.sent = append(s.sent, notification)
+	return s.err
+}
+
+func newTestEngine(cfg *config.NotificationsConfiguration, channels ...string) (*Engine, map[string]*recordingSender) {
+	engine := &Engine{cfg: cfg, senders: make(map[string]Sender), firing: make(map[string]*firingAlert)}
+	senders := make(map[string]*recordingSender)
+
+	for _, channel := range channels {
+		senders[channel] = &recordingSender{}
+		engine.senders[channel] = senders[channel]
+	}
+
+	return engine, senders
+}
+
+func lateAlert(group string) Alert {
+	return Alert{Environment: "prod", Disk: "backups", Directory: "db", File: "dump", Group: group, Status: "late"}
+}
+
+func Test_GH37_Evaluate_deduplicatesUntilRepeatInterval(t *testing.T) {
+	engine, senders := newTestEngine(&config.NotificationsConfiguration{RepeatInterval: time.Hour}, "ops")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	engine.Evaluate([]Alert{lateAlert("a")}, now)
+	engine.Evaluate([]Alert{lateAlert("a")}, now.Add(30*time.Minute))
+	assert.Len(t, senders["ops"].sent, 1)
+
+	engine.Evaluate([]Alert{lateAlert("a"), lateAlert("b")}, now.Add(45*time.Minute))
+	assert.Len(t, senders["ops"].sent, 2)
+	assert.Equal(t, "b", senders["ops"].sent[1].Firing[0].Group)
+
+	engine.Evaluate([]Alert{lateAlert("a"), lateAlert("b")}, now.Add(time.Hour))
+	assert.Len(t, senders["ops"].sent, 3)
+	assert.Len(t, senders["ops"].sent[2].Firing, 1)
+	assert.Equal(t, now, senders["ops"].sent[2].Firing[0].Since)
+}
+
+func Test_GH37_Evaluate_sendsResolvedAlerts(t *testing.T) {
+	engine, senders := newTestEngine(&config.NotificationsConfiguration{RepeatInterval: time.Hour, SendResolved: true}, "ops")
+	now := time.Now()
+
+	engine.Evaluate([]Alert{lateAlert("a")}, now)
+	engine.Evaluate(nil, now.Add(time.Minute))
+	engine.Evaluate(nil, now.Add(2*time.Minute))
+
+	assert.Len(t, senders["ops"].sent, 2)
+	assert.Empty(t, senders["ops"].sent[1].Firing)
+	assert.Equal(t, "a", senders["ops"].sent[1].Resolved[0].Group)
+}
+
+func Test_GH37_Evaluate_retriesFailedNotifications(t *testing.T) {
+	engine, senders := newTestEngine(&config.NotificationsConfiguration{RepeatInterval: time.Hour, SendResolved: true}, "ops")
+	senders["ops"].err = errors.New("unavailable")
+	now := time.Now()
+
+	engine.Evaluate([]Alert{lateAlert("a")}, now)
+	senders["ops"].err = nil
+	engine.Evaluate([]Alert{lateAlert("a")}, now.Add(time.Minute))
+
+	assert.Len(t, senders["ops"].sent, 2)
+}
+
+func Test_GH37_Evaluate_routesByLabels(t *testing.T) {
+	cfg := &config.NotificationsConfiguration{
+		RepeatInterval: time.Hour,
+		Routes: []*config.RouteConfiguration{
+			{
+				Matchers: []*config.LabelMatcher{{Name: LabelGroup, Regexp: regexp.MustCompile("^a$")}},
+				Channels: []string{"team-a"},
+			},
+			{
+				Channels: []string{"ops"},
+			},
+		},
+	}
+
+	engine, senders := newTestEngine(cfg, "team-a", "ops")
+
+	engine.Evaluate([]Alert{lateAlert("a"), lateAlert("b")}, time.Now())
+
+	assert.Len(t, senders["team-a"].sent, 1)
+	assert.Equal(t, "a", senders["team-a"].sent[0].Firing[0].Group)
+	// the first route does not continue
+	assert.Len(t, senders["ops"].sent, 1)
+	assert.Equal(t, "b", senders["ops"].sent[0].Firing[0].Group)
+}
+
+func Test_GH37_webhookSender_rendersTemplate(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = r.Method + " " + r.Header.Get("X-Token") + " " + string(data)
+	}))
+	defer server.Close()
+
+	sut := NewSender(&config.ChannelConfiguration{
+		Name:     "hook",
+		Type:     config.ChannelTypeWebhook,
+		URL:      server.URL,
+		Method:   http.MethodPut,
+		Headers:  map[string]string{"X-Token": "secret"},
+		Template: `{{range .Firing}}{{.Group}}={{.Status}};{{end}}`,
+	})
+
+	err := sut.Send(&Notification{Firing: []Alert{lateAlert("a")}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT secret a=late;", body)
+}
+
+func Test_GH37_slackSender_failsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	sut := NewSender(&config.ChannelConfiguration{Type: config.ChannelTypeSlack, URL: server.URL})
+
+	assert.Error(t, sut.Send(&Notification{Firing: []Alert{lateAlert("a")}}))
+}
+
+func Test_GH37_smtpSender_givesUpOnHungServers(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// the server accepts the connection, but never greets
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer func() { _ = conn.Close() }()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	previous := smtpTimeout
+	smtpTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { smtpTimeout = previous })
+
+	address := listener.Addr().(*net.TCPAddr)
+	sut := NewSender(&config.ChannelConfiguration{Type: config.ChannelTypeSMTP, Host: "127.0.0.1", Port: address.Port, From: "backmon@example.com", To: []string{"ops@example.com"}})
+	startedAt := time.Now()
+
+	assert.Error(t, sut.Send(&Notification{Firing: []Alert{lateAlert("a")}}))
+	assert.Less(t, time.Since(startedAt), 500*time.Millisecond)
+}
+
+func Test_GH48_Evaluate_suppressesSilencedAlerts(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+	sut, senders := newTestEngine(&config.NotificationsConfiguration{RepeatInterval: time.Hour, SendResolved: true}, "ops")
+
+	silence, err := AddSilence(Silence{Labels: map[string]string{LabelGroup: "a"}, CreatedAt: now, Until: now.Add(2 * time.Hour)})
+	assertion.NoError(err)
+	t.Cleanup(func() { RemoveSilence(silence.ID) })
+
+	sut.Evaluate([]Alert{lateAlert("a"), lateAlert("b")}, now)
+	assertion.Len(senders["ops"].sent, 1)
+	assertion.Len(senders["ops"].sent[0].Firing, 1)
+	assertion.Equal("b", senders["ops"].sent[0].Firing[0].Group)
+
+	// the alert is sent once the silence has expired
+	sut.Evaluate([]Alert{lateAlert("a"), lateAlert("b")}, now.Add(3*time.Hour))
+	assertion.Len(senders["ops"].sent, 2)
+	assertion.Len(senders["ops"].sent[1].Firing, 2)
+
+	_, err = AddSilence(Silence{Labels: map[string]string{"unknown": "a"}, CreatedAt: now, Until: now.Add(time.Hour)})
+	assertion.ErrorIs(err, ErrInvalidSilence)
+	_, err = AddSilence(Silence{CreatedAt: now, Until: now.Add(time.Hour)})
+	assertion.ErrorIs(err, ErrInvalidSilence)
+	assertion.Empty(GetSilences(now.Add(3 * time.Hour)))
+}
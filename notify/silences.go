@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSilence the silence has no labels, unknown labels or is already expired
+var ErrInvalidSilence = errors.New("invalid silence")
+
+// Silence suppresses the notifications of all alerts matching its labels until it expires. Silences are kept in memory
+// and are lost on restart; see #48.
+type Silence struct {
+	ID        string            `json:"id"`
+	Labels    map[string]string `json:"labels"`
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Until     time.Time         `json:"until"`
+}
+
+// Matches Return true if all labels of the silence equal the ones of the alert
+func (s *Silence) Matches(alert *Alert) bool {
+	labels := alert.Labels()
+
+	for label, value := range s.Labels {
+		if labels[label] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+var (
+	silences      = make(map[string]*Silence)
+	silencesMutex = &sync.Mutex{}
+)
+
+// AddSilence stores the silence with a new ID and returns it
+func AddSilence(silence Silence) (*Silence, error) {
+	if len(silence.Labels) == 0 || !silence.Until.After(silence.CreatedAt) {
+		return nil, ErrInvalidSilence
+	}
+
+	known := (&Alert{}).Labels()
+
+	for label := range silence.Labels {
+		if _, exists := known[label]; !exists {
+			return nil, ErrInvalidSilence
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	silence.ID = hex.EncodeToString(id)
+
+	silencesMutex.Lock()
+	defer silencesMutex.Unlock()
+
+	silences[silence.ID] = &silence
+	copied := silence
+
+	return &copied, nil
+}
+
+// GetSilence Return the silence with the ID; nil if it does not exist
+func GetSilence(id string) *Silence {
+	silencesMutex.Lock()
+	defer silencesMutex.Unlock()
+
+	silence, exists := silences[id]
+
+	if !exists {
+		return nil
+	}
+
+	copied := *silence
+
+	return &copied
+}
+
+// RemoveSilence removes the silence and returns whether it has existed
+func RemoveSilence(id string) bool {
+	silencesMutex.Lock()
+	defer silencesMutex.Unlock()
+
+	_, exists := silences[id]
+	delete(silences, id)
+
+	return exists
+}
+
+// GetSilences Return all silences which have not expired yet, ordered by their expiry; expired silences are removed
+func GetSilences(now time.Time) []Silence {
+	silencesMutex.Lock()
+	defer silencesMutex.Unlock()
+
+	r := []Silence{}
+
+	for id, silence := range silences {
+		if !silence.Until.After(now) {
+			delete(silences, id)
+			continue
+		}
+
+		r = append(r, *silence)
+	}
+
+	sort.Slice(r, func(i int, j int) bool {
+		return r[i].Until.Before(r[j].Until)
+	})
+
+	return r
+}
+
+// silenced Return true if a silence matches the alert
+func silenced(alert *Alert, now time.Time) bool {
+	for _, silence := range GetSilences(now) {
+		if silence.Matches(alert) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// Fetch requests the report from `/api/report` of the running instance at the base URL and writes it to out. The
+// token is sent as bearer token, if any. See #34.
+func Fetch(out io.Writer, baseURL string, token string, options Options, format string) error {
+	query := url.Values{"format": {format}, "days": {strconv.Itoa(options.Days)}}
+
+	for key, value := range map[string]string{"env": options.Environment, "disk": options.Disk, "dir": options.Directory, "file": options.File} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	if options.Grace > 0 {
+		query.Set("grace", options.Grace.String())
+	}
+
+	request, err := http.NewRequest(http.MethodGet, strings.TrimRight(baseURL, "/")+"/api/report?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("unexpected HTTP status %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	_, err = io.Copy(out, response.Body)
+
+	return err
+}
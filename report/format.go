@@ -0,0 +1,196 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// output formats of a report
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+)
+
+var columns = []string{
+	"Environment", "Disk", "Directory", "File", "Groups", "From", "Expected", "On time", "Late", "Missing",
+	"On-time rate", "Worst lateness", "Average size", "Growth",
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Backup SLA report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Backup SLA report</h1>
+<p>Period: {{.From}} - {{.To}}</p>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// ContentType Return the HTTP content type of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// IsFormat Return true if the format is supported
+func IsFormat(format string) bool {
+	switch format {
+	case FormatMarkdown, FormatHTML, FormatCSV, FormatJSON:
+		return true
+	}
+
+	return false
+}
+
+// Write writes the report in the given format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return r.writeMarkdown(w)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, map[string]interface{}{
+			"From":    formatTime(r.From),
+			"To":      formatTime(r.To),
+			"Columns": columns,
+			"Rows":    r.cells(),
+		})
+	case FormatCSV:
+		return r.writeCSV(w)
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(r)
+	}
+
+	return fmt.Errorf("unknown report format '%s'", format)
+}
+
+func (r *Report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# Backup SLA report\n\n")
+	b.WriteString(fmt.Sprintf("Period: %s - %s\n\n", formatTime(r.From), formatTime(r.To)))
+	b.WriteString("| " + strings.Join(columns, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+
+	for _, cells := range r.cells() {
+		for i := range cells {
+			cells[i] = strings.ReplaceAll(cells[i], "|", "\\|")
+		}
+
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// the CSV contains raw values, so that it can be processed further
+func (r *Report) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{
+		"environment", "disk", "directory", "file", "groups", "from", "expected_runs", "on_time_runs", "late_runs",
+		"missing_runs", "on_time_rate", "worst_lateness_seconds", "average_size_bytes", "growth_percent",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, row := range r.Rows {
+		rate := ""
+		if row.OnTimeRate != nil {
+			rate = strconv.FormatFloat(*row.OnTimeRate, 'f', 2, 64)
+		}
+
+		err = writer.Write([]string{
+			row.Environment,
+			row.Disk,
+			row.Directory,
+			row.File,
+			strconv.Itoa(row.Groups),
+			formatTime(row.From),
+			strconv.Itoa(row.ExpectedRuns),
+			strconv.Itoa(row.OnTimeRuns),
+			strconv.Itoa(row.LateRuns),
+			strconv.Itoa(row.MissingRuns),
+			rate,
+			strconv.FormatFloat(row.WorstLatenessSeconds, 'f', 0, 64),
+			strconv.FormatFloat(row.AverageSize, 'f', 0, 64),
+			strconv.FormatFloat(row.GrowthPercent, 'f', 2, 64),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// cells returns the human-readable cells of each row
+func (r *Report) cells() [][]string {
+	result := make([][]string, 0, len(r.Rows))
+
+	for _, row := range r.Rows {
+		rate := "n/a"
+		if row.OnTimeRate != nil {
+			rate = fmt.Sprintf("%.2f%%", *row.OnTimeRate)
+		}
+
+		result = append(result, []string{
+			row.Environment,
+			row.Disk,
+			row.Directory,
+			row.File,
+			strconv.Itoa(row.Groups),
+			formatTime(row.From),
+			strconv.Itoa(row.ExpectedRuns),
+			strconv.Itoa(row.OnTimeRuns),
+			strconv.Itoa(row.LateRuns),
+			strconv.Itoa(row.MissingRuns),
+			rate,
+			(time.Duration(row.WorstLatenessSeconds) * time.Second).String(),
+			bytefmt.ByteSize(uint64(row.AverageSize)),
+			fmt.Sprintf("%+.2f%%", row.GrowthPercent),
+		})
+	}
+
+	return result
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
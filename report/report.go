@@ -0,0 +1,239 @@
+package report
+
+// SLA and compliance reports, based upon the schedules of the file definitions and the persistent history of observed
+// backup files. For each scheduled run within the report period, the first backup file modified before the next
+// scheduled run is attributed to the run.
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/dreitier/backmon/history"
+	"github.com/dreitier/backmon/storage"
+	"github.com/gorhill/cronexpr"
+)
+
+// Options restrict and parameterize a report; empty names match everything
+type Options struct {
+	// length of the report period, ending now
+	Days        int
+	Environment string
+	Disk        string
+	Directory   string
+	File        string
+	// a backup is on time if it has been modified at most `Grace` after the scheduled run; 0 means before the next run
+	Grace time.Duration
+}
+
+type Report struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Rows []Row     `json:"rows"`
+}
+
+// Row the SLA figures of a single file definition, summarized over all of its groups
+type Row struct {
+	Environment string `json:"environment"`
+	Disk        string `json:"disk"`
+	Directory   string `json:"directory"`
+	File        string `json:"file"`
+	Groups      int    `json:"groups"`
+	// the period is shortened if the history starts later
+	From         time.Time `json:"from"`
+	ExpectedRuns int       `json:"expected_runs"`
+	OnTimeRuns   int       `json:"on_time_runs"`
+	LateRuns     int       `json:"late_runs"`
+	MissingRuns  int       `json:"missing_runs"`
+	// percentage of expected runs which have been on time; nil if no runs were expected
+	OnTimeRate           *float64 `json:"on_time_rate"`
+	WorstLatenessSeconds float64  `json:"worst_lateness_seconds"`
+	AverageSize          float64  `json:"average_size_bytes"`
+	// average growth in percent from the first to the last backup file of each group
+	GrowthPercent float64 `json:"growth_percent"`
+}
+
+// Generate creates the report of all given file definitions matching the options
+func Generate(store *history.Store, definitions []storage.FileDefinitionRef, options Options, now time.Time) (*Report, error) {
+	if store == nil {
+		return nil, history.ErrDisabled
+	}
+
+	if options.Days <= 0 {
+		return nil, errors.New("the report period must be at least one day")
+	}
+
+	r := &Report{
+		From: now.AddDate(0, 0, -options.Days).UTC(),
+		To:   now.UTC(),
+		Rows: []Row{},
+	}
+
+	// the start of the history of each disk
+	historyStart := make(map[string]time.Time)
+
+	for _, definition := range definitions {
+		if !matches(options.Environment, definition.Environment) ||
+			!matches(options.Disk, definition.Disk) ||
+			!matches(options.Directory, definition.Directory.Alias) ||
+			!matches(options.File, definition.File.Alias) {
+			continue
+		}
+
+		diskKey := definition.Environment + "/" + definition.Disk
+		from, known := historyStart[diskKey]
+
+		if !known {
+			scans, err := store.Scans(history.Query{Environment: definition.Environment, Disk: definition.Disk, From: r.From})
+			if err != nil {
+				return nil, err
+			}
+
+			// runs before the first scan have not been observed and can't be reported as missing
+			from = r.To
+			if len(scans) > 0 {
+				from = scans[0].Time
+			}
+
+			historyStart[diskKey] = from
+		}
+
+		observations, err := store.Files(history.Query{
+			Environment: definition.Environment,
+			Disk:        definition.Disk,
+			Directory:   definition.Directory.Alias,
+			File:        definition.File.Alias,
+			From:        from,
+			To:          r.To,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		row := evaluate(definition.File.Schedule, observations, from, r.To, options.Grace)
+		row.Environment = definition.Environment
+		row.Disk = definition.Disk
+		row.Directory = definition.Directory.Alias
+		row.File = definition.File.Alias
+		r.Rows = append(r.Rows, row)
+	}
+
+	sort.SliceStable(r.Rows, func(i int, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+
+		if a.Environment != b.Environment {
+			return a.Environment < b.Environment
+		}
+
+		if a.Disk != b.Disk {
+			return a.Disk < b.Disk
+		}
+
+		if a.Directory != b.Directory {
+			return a.Directory < b.Directory
+		}
+
+		return a.File < b.File
+	})
+
+	return r, nil
+}
+
+// evaluate computes the SLA figures of the observed files of a single file definition. Only runs whose successor run is
+// not in the future are expected, as the current run may still be in progress.
+func evaluate(schedule *cronexpr.Expression, observations []history.Observation, from time.Time, to time.Time, grace time.Duration) Row {
+	row := Row{From: from}
+	groups := make(map[string][]history.Observation)
+
+	for _, observation := range observations {
+		groups[observation.Group] = append(groups[observation.Group], observation)
+	}
+
+	row.Groups = len(groups)
+
+	// without any backup file, every run is missing
+	if len(groups) == 0 {
+		groups[""] = nil
+	}
+
+	var runs []time.Time
+
+	if schedule != nil {
+		for run := schedule.Next(from); !run.IsZero() && run.Before(to); run = schedule.Next(run) {
+			runs = append(runs, run)
+		}
+	}
+
+	totalSize := 0.0
+	totalFiles := 0
+	totalGrowth := 0.0
+	grown := 0
+
+	for _, files := range groups {
+		sort.SliceStable(files, func(i int, j int) bool {
+			return files[i].ModifiedAt.Before(files[j].ModifiedAt)
+		})
+
+		var inPeriod []history.Observation
+
+		for _, file := range files {
+			if !file.ModifiedAt.Before(from) && !file.ModifiedAt.After(to) {
+				inPeriod = append(inPeriod, file)
+				totalSize += float64(file.Size)
+				totalFiles++
+			}
+		}
+
+		if len(inPeriod) > 1 && inPeriod[0].Size > 0 {
+			totalGrowth += float64(inPeriod[len(inPeriod)-1].Size-inPeriod[0].Size) / float64(inPeriod[0].Size) * 100
+			grown++
+		}
+
+		next := 0
+
+		for i := 0; i+1 < len(runs); i++ {
+			run, nextRun := runs[i], runs[i+1]
+			row.ExpectedRuns++
+
+			for next < len(inPeriod) && inPeriod[next].ModifiedAt.Before(run) {
+				next++
+			}
+
+			if next >= len(inPeriod) || !inPeriod[next].ModifiedAt.Before(nextRun) {
+				row.MissingRuns++
+				continue
+			}
+
+			lateness := inPeriod[next].ModifiedAt.Sub(run)
+
+			if lateness.Seconds() > row.WorstLatenessSeconds {
+				row.WorstLatenessSeconds = lateness.Seconds()
+			}
+
+			if grace > 0 && lateness > grace {
+				row.LateRuns++
+			} else {
+				row.OnTimeRuns++
+			}
+		}
+	}
+
+	if row.ExpectedRuns > 0 {
+		rate := float64(row.OnTimeRuns) / float64(row.ExpectedRuns) * 100
+		row.OnTimeRate = &rate
+	}
+
+	if totalFiles > 0 {
+		row.AverageSize = totalSize / float64(totalFiles)
+	}
+
+	if grown > 0 {
+		row.GrowthPercent = totalGrowth / float64(grown)
+	}
+
+	return row
+}
+
+func matches(expected string, actual string) bool {
+	return expected == "" || expected == actual
+}
@@ -0,0 +1,122 @@
+package report
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/history"
+	"github.com/gorhill/cronexpr"
+	"github.com/stretchr/testify/assert"
+)
+
+func backupAt(group string, modifiedAt time.Time, size int64) history.Observation {
+	return history.Observation{Group: group, Name: modifiedAt.Format(time.RFC3339), ModifiedAt: modifiedAt, Size: size}
+}
+
+func Test_GH34_evaluate_countsOnTimeLateAndMissingRuns(t *testing.T) {
+	assertion := assert.New(t)
+	schedule := cronexpr.MustParse("0 2 * * *")
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	// runs on the 1st to the 5th; the run on the 5th is still in progress
+	to := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	observations := []history.Observation{
+		backupAt(".", time.Date(2024, 3, 1, 2, 10, 0, 0, time.UTC), 100),
+		backupAt(".", time.Date(2024, 3, 2, 5, 0, 0, 0, time.UTC), 110),
+		// the 3rd is missing
+		backupAt(".", time.Date(2024, 3, 4, 2, 30, 0, 0, time.UTC), 120),
+	}
+
+	row := evaluate(schedule, observations, from, to, time.Hour)
+
+	assertion.Equal(1, row.Groups)
+	assertion.Equal(4, row.ExpectedRuns)
+	assertion.Equal(2, row.OnTimeRuns)
+	assertion.Equal(1, row.LateRuns)
+	assertion.Equal(1, row.MissingRuns)
+	assertion.Equal(50.0, *row.OnTimeRate)
+	assertion.Equal((3 * time.Hour).Seconds(), row.WorstLatenessSeconds)
+	assertion.Equal(110.0, row.AverageSize)
+	assertion.Equal(20.0, row.GrowthPercent)
+
+	// without a grace period, every backup before the next run is on time
+	row = evaluate(schedule, observations, from, to, 0)
+	assertion.Equal(3, row.OnTimeRuns)
+	assertion.Equal(0, row.LateRuns)
+}
+
+func Test_GH34_evaluate_reportsAllRunsOfEachGroup(t *testing.T) {
+	assertion := assert.New(t)
+	schedule := cronexpr.MustParse("0 2 * * *")
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	row := evaluate(schedule, []history.Observation{
+		backupAt("db1", time.Date(2024, 3, 1, 2, 10, 0, 0, time.UTC), 100),
+		backupAt("db2", time.Date(2024, 3, 1, 2, 10, 0, 0, time.UTC), 100),
+		backupAt("db2", time.Date(2024, 3, 2, 2, 10, 0, 0, time.UTC), 100),
+	}, from, to, 0)
+
+	assertion.Equal(2, row.Groups)
+	assertion.Equal(4, row.ExpectedRuns)
+	assertion.Equal(1, row.MissingRuns)
+
+	row = evaluate(schedule, nil, from, to, 0)
+
+	assertion.Equal(0, row.Groups)
+	assertion.Equal(2, row.MissingRuns)
+	assertion.Equal(0.0, *row.OnTimeRate)
+}
+
+func Test_GH34_Write_formats(t *testing.T) {
+	assertion := assert.New(t)
+	rate := 75.0
+	sut := &Report{
+		From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		Rows: []Row{{Environment: "prod", Disk: "bucket", Directory: "db", File: "dump", Groups: 1, ExpectedRuns: 4, OnTimeRuns: 3, MissingRuns: 1, OnTimeRate: &rate, AverageSize: 2048}},
+	}
+
+	var markdown bytes.Buffer
+	assertion.NoError(sut.Write(&markdown, FormatMarkdown))
+	assertion.Contains(markdown.String(), "| prod | bucket | db | dump | 1 | 0001-01-01T00:00:00Z | 4 | 3 | 0 | 1 | 75.00% | 0s | 2K | +0.00% |")
+
+	var csv bytes.Buffer
+	assertion.NoError(sut.Write(&csv, FormatCSV))
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	assertion.Equal(2, len(lines))
+	assertion.Equal("prod,bucket,db,dump,1,0001-01-01T00:00:00Z,4,3,0,1,75.00,0,2048,0.00", lines[1])
+
+	var html bytes.Buffer
+	assertion.NoError(sut.Write(&html, FormatHTML))
+	assertion.Contains(html.String(), "<td>75.00%</td>")
+
+	assertion.Error(sut.Write(&html, "pdf"))
+}
+
+func Test_GH34_Fetch_requestsReportOfRunningInstance(t *testing.T) {
+	assertion := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte("Unauthorized"))
+			return
+		}
+
+		_, _ = w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	options := Options{Days: 7, Disk: "backups", Grace: time.Hour}
+
+	assertion.NoError(Fetch(&out, server.URL+"/", "secret", options, FormatCSV))
+	assertion.Equal("/api/report?days=7&disk=backups&format=csv&grace=1h0m0s", out.String())
+
+	err := Fetch(&out, server.URL, "", options, FormatCSV)
+	assertion.ErrorContains(err, "401")
+}
@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/events"
+	fs "github.com/dreitier/backmon/storage/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// kinds of anomalies, used as metric label values; see #32
+const (
+	AnomalyModified = "modified"
+	AnomalyShrunk   = "shrunk"
+	AnomalyRenamed  = "renamed"
+	AnomalyDeleted  = "deleted"
+	AnomalyEntropy  = "entropy"
+)
+
+var anomalyKinds = []string{AnomalyModified, AnomalyShrunk, AnomalyRenamed, AnomalyDeleted, AnomalyEntropy}
+
+// Anomaly is a suspicious change of a file between two consecutive scans of a disk
+type Anomaly struct {
+	Kind string
+	// path of the file in the current scan; for deleted files the path in the previous scan
+	Path   string
+	Detail string
+}
+
+// detectAnomalies compares two consecutive scans of a disk. Files purged by backmon in between are not counted as
+// deleted. The anomalies of a kind are only reported if their amount reaches the configured threshold.
+func detectAnomalies(previous *fs.DirectoryInfo, current *fs.DirectoryInfo, purged map[string]bool, cfg *config.AnomaliesConfiguration) []Anomaly {
+	before := flattenFiles(previous, make(map[string]*fs.FileInfo))
+	after := flattenFiles(current, make(map[string]*fs.FileInfo))
+	found := make(map[string][]Anomaly)
+	renamed := make(map[string]bool)
+
+	for _, filePath := range sortedPaths(after) {
+		file := after[filePath]
+		old, exists := before[filePath]
+
+		if exists {
+			if file.Size < old.Size {
+				found[AnomalyShrunk] = append(found[AnomalyShrunk], Anomaly{
+					Kind:   AnomalyShrunk,
+					Path:   filePath,
+					Detail: fmt.Sprintf("size changed from %d to %d bytes", old.Size, file.Size),
+				})
+			} else if !file.ModifiedAt.Equal(old.ModifiedAt) {
+				found[AnomalyModified] = append(found[AnomalyModified], Anomaly{
+					Kind:   AnomalyModified,
+					Path:   filePath,
+					Detail: fmt.Sprintf("modified at %s, previously at %s", file.ModifiedAt, old.ModifiedAt),
+				})
+			}
+
+			continue
+		}
+
+		// a file which has been renamed by appending an extension, e.g. dump.sql.gz -> dump.sql.gz.locked
+		ext := path.Ext(filePath)
+		original := strings.TrimSuffix(filePath, ext)
+
+		if _, existed := before[original]; ext != "" && existed {
+			if _, stillExists := after[original]; !stillExists {
+				renamed[original] = true
+				found[AnomalyRenamed] = append(found[AnomalyRenamed], Anomaly{
+					Kind:   AnomalyRenamed,
+					Path:   filePath,
+					Detail: fmt.Sprintf("renamed from '%s'", original),
+				})
+			}
+		}
+	}
+
+	for _, filePath := range sortedPaths(before) {
+		if _, exists := after[filePath]; exists || purged[filePath] || renamed[filePath] {
+			continue
+		}
+
+		found[AnomalyDeleted] = append(found[AnomalyDeleted], Anomaly{
+			Kind:   AnomalyDeleted,
+			Path:   filePath,
+			Detail: "deleted since the previous scan",
+		})
+	}
+
+	thresholds := map[string]uint64{
+		AnomalyModified: cfg.ModifiedFiles,
+		AnomalyShrunk:   cfg.ShrunkFiles,
+		AnomalyRenamed:  cfg.RenamedFiles,
+		AnomalyDeleted:  cfg.DeletedFiles,
+	}
+
+	var r []Anomaly
+
+	for _, kind := range anomalyKinds {
+		threshold := thresholds[kind]
+
+		if threshold > 0 && uint64(len(found[kind])) >= threshold {
+			r = append(r, found[kind]...)
+		}
+	}
+
+	return r
+}
+
+// entropyCandidates returns all new or modified files which should be compressible, based upon their extension
+func entropyCandidates(previous *fs.DirectoryInfo, current *fs.DirectoryInfo, purged map[string]bool, cfg *config.AnomaliesConfiguration) []*fs.FileInfo {
+	before := flattenFiles(previous, make(map[string]*fs.FileInfo))
+	after := flattenFiles(current, make(map[string]*fs.FileInfo))
+
+	// files moved to an archive by purge appear as new files
+	purgedNames := make(map[string]bool, len(purged))
+	for filePath := range purged {
+		purgedNames[path.Base(filePath)] = true
+	}
+
+	var r []*fs.FileInfo
+
+	for _, filePath := range sortedPaths(after) {
+		file := after[filePath]
+
+		if old, exists := before[filePath]; exists && old.ModifiedAt.Equal(file.ModifiedAt) {
+			continue
+		}
+
+		if purgedNames[file.Name] || !isCompressible(file.Name, cfg.CompressibleExtensions) {
+			continue
+		}
+
+		r = append(r, file)
+	}
+
+	return r
+}
+
+func isCompressible(name string, extensions []string) bool {
+	name = strings.ToLower(name)
+
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, strings.ToLower(ext)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sampleEntropy downloads the first bytes of the file and returns their Shannon entropy in bits per byte
+func sampleEntropy(client Client, disk string, file *fs.FileInfo, sampleSize uint64) (float64, error) {
+	reader, _, _, err := client.Download(disk, file)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	sample, err := io.ReadAll(io.LimitReader(reader, int64(sampleSize)))
+	if err != nil {
+		return 0, err
+	}
+
+	return shannonEntropy(sample), nil
+}
+
+// shannonEntropy Return the entropy of the data in bits per byte, from 0 (constant) to 8 (random)
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var frequencies [256]int
+	for _, b := range data {
+		frequencies[b]++
+	}
+
+	entropy := 0.0
+	total := float64(len(data))
+
+	for _, frequency := range frequencies {
+		if frequency == 0 {
+			continue
+		}
+
+		p := float64(frequency) / total
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// scanSnapshot is the listing of a disk, kept until the next scan
+type scanSnapshot struct {
+	root *fs.DirectoryInfo
+	// the maximum directory depth of the listing; listings of different depths cannot be compared
+	depth uint64
+}
+
+// updateAnomalies compares the files of the current scan with the previous scan of the disk and keeps the current
+// scan as the new snapshot
+func (disk *DiskData) updateAnomalies(client Client, root *fs.DirectoryInfo, depth uint64) []Anomaly {
+	previous := disk.snapshot
+	purged := disk.purged
+
+	disk.snapshot = &scanSnapshot{root: root, depth: depth}
+	disk.purged = nil
+
+	cfg := config.GetInstance().Global().Anomalies()
+
+	// without a comparable previous scan, every file would be new or deleted
+	if previous == nil || previous.depth != depth {
+		return nil
+	}
+
+	anomalies := detectAnomalies(previous.root, root, purged, cfg)
+
+	if cfg.EntropySampleSize > 0 {
+		for _, file := range entropyCandidates(previous.root, root, purged, cfg) {
+			entropy, err := sampleEntropy(client, disk.Name, file, cfg.EntropySampleSize)
+			if err != nil {
+				log.Warnf("Could not sample entropy of file '%s' in disk '%s': %s", file.Name, disk.Name, err)
+				continue
+			}
+
+			if entropy >= cfg.EntropyThreshold {
+				anomalies = append(anomalies, Anomaly{
+					Kind:   AnomalyEntropy,
+					Path:   path.Join(file.Parent, file.Name),
+					Detail: fmt.Sprintf("entropy of %.2f bits per byte, although the file should be compressible", entropy),
+				})
+			}
+		}
+	}
+
+	flagged := make(map[string]int)
+	for _, anomaly := range anomalies {
+		// #40
+		disk.publish(events.Event{Type: events.TypeAnomaly, Name: anomaly.Path, Detail: anomaly.Kind + ": " + anomaly.Detail})
+
+		if flagged[anomaly.Kind] == 0 {
+			log.Warnf("[disk:%s] Possible tampering (%s): '%s' %s", disk.Name, anomaly.Kind, anomaly.Path, anomaly.Detail)
+		}
+
+		flagged[anomaly.Kind]++
+	}
+
+	for kind, count := range flagged {
+		if count > 1 {
+			log.Warnf("[disk:%s] Possible tampering (%s): %d files in total", disk.Name, kind, count)
+		}
+	}
+
+	disk.metrics.UpdateAnomalies(anomalyKinds, flagged)
+
+	return anomalies
+}
+
+// markPurged remembers a purged file, so that it is not considered as deleted by the next scan
+func (disk *DiskData) markPurged(file *fs.FileInfo) {
+	if disk.purged == nil {
+		disk.purged = make(map[string]bool)
+	}
+
+	disk.purged[path.Join(file.Parent, file.Name)] = true
+}
+
+func flattenFiles(dir *fs.DirectoryInfo, files map[string]*fs.FileInfo) map[string]*fs.FileInfo {
+	if dir == nil {
+		return files
+	}
+
+	for _, file := range dir.Files {
+		files[path.Join(file.Parent, file.Name)] = file
+	}
+
+	for _, subDir := range dir.SubDirs {
+		flattenFiles(subDir, files)
+	}
+
+	return files
+}
+
+func sortedPaths(files map[string]*fs.FileInfo) []string {
+	r := make([]string, 0, len(files))
+
+	for filePath := range files {
+		r = append(r, filePath)
+	}
+
+	sort.Strings(r)
+
+	return r
+}
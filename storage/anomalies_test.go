@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func backupDir(files ...*fs.FileInfo) *fs.DirectoryInfo {
+	return &fs.DirectoryInfo{Name: "backups", Files: files}
+}
+
+func backupFile(name string, size int64, modifiedAt time.Time) *fs.FileInfo {
+	return &fs.FileInfo{Name: name, Parent: "/backups", Size: size, ModifiedAt: modifiedAt}
+}
+
+func anomalyKindsOf(anomalies []Anomaly) map[string]int {
+	r := make(map[string]int)
+
+	for _, anomaly := range anomalies {
+		r[anomaly.Kind]++
+	}
+
+	return r
+}
+
+func Test_GH32_detectAnomalies_ignoresRegularBackupRotation(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	cfg := &config.AnomaliesConfiguration{ModifiedFiles: 2, DeletedFiles: 1, ShrunkFiles: 1, RenamedFiles: 1}
+
+	previous := backupDir(backupFile("dump-1.sql.gz", 100, now.Add(-48*time.Hour)), backupFile("dump-2.sql.gz", 100, now.Add(-24*time.Hour)))
+	current := backupDir(backupFile("dump-2.sql.gz", 100, now.Add(-24*time.Hour)), backupFile("dump-3.sql.gz", 100, now))
+
+	anomalies := detectAnomalies(previous, current, map[string]bool{"/backups/dump-1.sql.gz": true}, cfg)
+
+	assertion.Empty(anomalies)
+}
+
+func Test_GH32_detectAnomalies_flagsTampering(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	cfg := &config.AnomaliesConfiguration{ModifiedFiles: 2, DeletedFiles: 1, ShrunkFiles: 1, RenamedFiles: 1}
+
+	previous := backupDir(
+		backupFile("dump-1.sql.gz", 100, now.Add(-72*time.Hour)),
+		backupFile("dump-2.sql.gz", 100, now.Add(-48*time.Hour)),
+		backupFile("dump-3.sql.gz", 100, now.Add(-24*time.Hour)),
+		backupFile("dump-4.sql.gz", 100, now.Add(-24*time.Hour)),
+		backupFile("dump-5.sql.gz", 100, now.Add(-24*time.Hour)),
+	)
+	current := backupDir(
+		backupFile("dump-1.sql.gz.locked", 100, now),
+		backupFile("dump-2.sql.gz", 10, now),
+		backupFile("dump-3.sql.gz", 120, now),
+		backupFile("dump-4.sql.gz", 120, now),
+	)
+
+	kinds := anomalyKindsOf(detectAnomalies(previous, current, nil, cfg))
+
+	assertion.Equal(1, kinds[AnomalyRenamed])
+	assertion.Equal(1, kinds[AnomalyShrunk])
+	assertion.Equal(2, kinds[AnomalyModified])
+	// the renamed file has not been deleted
+	assertion.Equal(1, kinds[AnomalyDeleted])
+}
+
+func Test_GH32_detectAnomalies_respectsThresholds(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	cfg := &config.AnomaliesConfiguration{ModifiedFiles: 3, DeletedFiles: 2}
+
+	previous := backupDir(backupFile("a.tar", 100, now.Add(-time.Hour)), backupFile("b.tar", 100, now.Add(-time.Hour)), backupFile("c.tar", 100, now.Add(-time.Hour)))
+	current := backupDir(backupFile("a.tar", 100, now), backupFile("b.tar", 100, now))
+
+	assertion.Empty(detectAnomalies(previous, current, nil, cfg))
+}
+
+func Test_GH32_entropyCandidates_onlyReturnsNewCompressibleFiles(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	cfg := &config.AnomaliesConfiguration{CompressibleExtensions: []string{".sql", ".csv"}}
+
+	previous := backupDir(backupFile("old.sql", 100, now.Add(-time.Hour)))
+	current := backupDir(backupFile("old.sql", 100, now.Add(-time.Hour)), backupFile("new.SQL", 100, now), backupFile("new.sql.gz", 100, now))
+
+	candidates := entropyCandidates(previous, current, nil, cfg)
+
+	assertion.Equal(1, len(candidates))
+	assertion.Equal("new.SQL", candidates[0].Name)
+}
+
+func Test_GH32_shannonEntropy(t *testing.T) {
+	assertion := assert.New(t)
+	random := make([]byte, 64*1024)
+	rand.New(rand.NewSource(32)).Read(random)
+
+	assertion.Equal(0.0, shannonEntropy(bytes.Repeat([]byte{'a'}, 1024)))
+	assertion.InDelta(1.0, shannonEntropy(bytes.Repeat([]byte("ab"), 512)), 0.001)
+	assertion.Less(shannonEntropy([]byte("INSERT INTO backups (id, name) VALUES (1, 'nightly');")), 5.0)
+	assertion.Greater(shannonEntropy(random), 7.9)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"io"
+	"time"
 
 	"github.com/dreitier/backmon/config"
 	fs "github.com/dreitier/backmon/storage/fs"
@@ -15,7 +16,20 @@ type Client interface {
 
 	Download(disk string, file *fs.FileInfo) (bytes io.ReadCloser, length int64, contentType string, err error)
 
+	// Open returns a seekable reader of the file, e.g. for serving HTTP range requests
+	Open(disk string, file *fs.FileInfo) (io.ReadSeekCloser, error)
+
+	// PresignedURL returns a URL which downloads the file as attachment without credentials until it expires;
+	// errors.ErrUnsupported if the storage does not support it
+	PresignedURL(disk string, file *fs.FileInfo, attachmentName string, expiry time.Duration) (string, error)
+
 	Delete(disk string, file *fs.FileInfo) error
+
+	// Move relocates the file below targetPath in targetDisk (or its own disk, if targetDisk is empty)
+	Move(disk string, file *fs.FileInfo, targetDisk string, targetPath string) error
+
+	// GetLockStatus returns the immutability of the file; errors.ErrUnsupported if the storage does not support it
+	GetLockStatus(disk string, file *fs.FileInfo) (*fs.LockStatus, error)
 }
 
 func NewClient(config *config.ClientConfiguration) Client {
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	fs "github.com/dreitier/backmon/storage/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// prices are per GB-month
+const bytesPerGB = 1024 * 1024 * 1024
+
+// costCalculator estimates the monthly storage costs of the files of a disk, see #36
+type costCalculator struct {
+	costs       *config.CostsConfiguration
+	environment string
+	provider    string
+	// storage classes without a price, only logged once per calculator
+	unknown map[string]bool
+}
+
+// newCostCalculator Return the calculator for the disk; nil if no costs have been configured
+func newCostCalculator(disk *DiskData) *costCalculator {
+	costs := config.GetInstance().Costs()
+
+	if costs == nil {
+		return nil
+	}
+
+	return &costCalculator{
+		costs:       costs,
+		environment: disk.Environment,
+		provider:    providerOf(disk.Environment),
+		unknown:     make(map[string]bool),
+	}
+}
+
+func providerOf(environment string) string {
+	for _, env := range config.GetInstance().Environments() {
+		if env.Name == environment && env.Client.Directory != "" {
+			return config.ProviderLocal
+		}
+	}
+
+	return config.ProviderS3
+}
+
+func (c *costCalculator) of(file *fs.FileInfo) float64 {
+	price, exists := c.costs.Price(c.environment, c.provider, file.StorageClass)
+
+	if !exists {
+		if !c.unknown[file.StorageClass] {
+			log.Debugf("[env:%s] No price for storage class '%s' of provider '%s'", c.environment, file.StorageClass, c.provider)
+			c.unknown[file.StorageClass] = true
+		}
+
+		return 0
+	}
+
+	return float64(file.Size) / bytesPerGB * price
+}
+
+func (c *costCalculator) ofDir(dir *fs.DirectoryInfo) float64 {
+	cost := 0.0
+
+	for _, file := range dir.Files {
+		cost += c.of(file)
+	}
+
+	for _, subDir := range dir.SubDirs {
+		cost += c.ofDir(subDir)
+	}
+
+	return cost
+}
+
+// ofGroup Return the costs of all files of the group and the costs of the files which are not retained by the
+// retention policy, i.e. the purge candidates. Without a retention policy, nothing can be saved.
+func (c *costCalculator) ofGroup(list FileGroup, fileDef *backup.FileDefinition, now time.Time) (cost float64, savings float64) {
+	policy := applyRetention(list, fileDef, now)
+
+	for i, file := range list {
+		fileCost := c.of(file.File)
+		cost += fileCost
+
+		if fileDef.HasRetention() && !policy.keep[i] {
+			savings += fileCost
+		}
+	}
+
+	return cost, savings
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH36_costCalculator_usesPricesOfStorageClasses(t *testing.T) {
+	assertion := assert.New(t)
+	sut := &costCalculator{
+		costs: &config.CostsConfiguration{
+			Providers: map[string]map[string]float64{
+				config.ProviderS3: {"STANDARD": 0.02, "GLACIER": 0.004},
+			},
+			Environments: map[string]map[string]float64{
+				"minio": {config.StorageClassDefault: 0.01},
+			},
+		},
+		environment: "aws",
+		provider:    config.ProviderS3,
+		unknown:     make(map[string]bool),
+	}
+
+	assertion.InDelta(0.2, sut.of(&fs.FileInfo{Size: 10 * bytesPerGB, StorageClass: "STANDARD"}), 0.0001)
+	assertion.InDelta(0.04, sut.of(&fs.FileInfo{Size: 10 * bytesPerGB, StorageClass: "GLACIER"}), 0.0001)
+	assertion.Equal(0.0, sut.of(&fs.FileInfo{Size: 10 * bytesPerGB, StorageClass: "DEEP_ARCHIVE"}))
+
+	// the price table of the environment takes precedence
+	sut.environment = "minio"
+	assertion.InDelta(0.1, sut.of(&fs.FileInfo{Size: 10 * bytesPerGB, StorageClass: "STANDARD"}), 0.0001)
+}
+
+func Test_GH36_costCalculator_ofGroup_computesSavingsOfPurgeCandidates(t *testing.T) {
+	assertion := assert.New(t)
+	sut := &costCalculator{
+		costs: &config.CostsConfiguration{
+			Providers: map[string]map[string]float64{config.ProviderLocal: {config.StorageClassDefault: 1}},
+		},
+		provider: config.ProviderLocal,
+		unknown:  make(map[string]bool),
+	}
+
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+	list := dailyFiles(now, 10)
+	for _, file := range list {
+		file.File.Size = bytesPerGB
+	}
+
+	cost, savings := sut.ofGroup(list, &backup.FileDefinition{RetentionCount: 2}, now)
+	assertion.InDelta(10, cost, 0.0001)
+	assertion.InDelta(8, savings, 0.0001)
+
+	// files retained by the buckets are no savings
+	_, savings = sut.ofGroup(list, &backup.FileDefinition{RetentionCount: 2, RetentionWeekly: 2}, now)
+	assertion.InDelta(7, savings, 0.0001)
+
+	_, savings = sut.ofGroup(list, &backup.FileDefinition{RetentionAge: 30 * 24 * time.Hour}, now)
+	assertion.Equal(0.0, savings)
+
+	// without a retention policy, no file is a purge candidate
+	cost, savings = sut.ofGroup(list, &backup.FileDefinition{}, now)
+	assertion.InDelta(10, cost, 0.0001)
+	assertion.Equal(0.0, savings)
+}
@@ -0,0 +1,507 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/history"
+	fs "github.com/dreitier/backmon/storage/fs"
+)
+
+// Structured resources of the environments, disks, directories, file definitions and groups as of the latest scan,
+// used by the v2 API; see #41. In contrast to DiskData, all resources are copies which can be used without locking.
+
+var (
+	ErrDiskNotFound      = errors.New("disk does not exist")
+	ErrDiskAmbiguous     = errors.New("disk exists in several environments")
+	ErrDirectoryNotFound = errors.New("directory does not exist")
+	ErrFileNotFound      = errors.New("file does not exist")
+	ErrGroupNotFound     = errors.New("group does not exist")
+	ErrBackupNotFound    = errors.New("backup does not exist")
+)
+
+// GroupFiles all backup files of a file definition in a group, newest first
+type GroupFiles struct {
+	Files FileGroup
+	// amount of files younger than the retention age
+	Young uint64
+}
+
+// latest Return the newest file or nil if there is none
+func (g *GroupFiles) latest() *fs.FileInfo {
+	if g == nil || len(g.Files) == 0 {
+		return nil
+	}
+
+	return g.Files[0].File
+}
+
+// severity of each status; the health of a file definition or disk is the most severe status of its groups
+var severities = map[string]int{
+	StatusOk:                 0,
+	StatusLate:               1,
+	StatusUnconfirmed:        2,
+	StatusFailed:             3,
+	StatusMissing:            4,
+	StatusDefinitionsMissing: 5,
+}
+
+// Severity Return the severity of the status; the more severe, the higher
+func Severity(status string) int {
+	return severities[status]
+}
+
+type EnvironmentResource struct {
+	Name  string   `json:"name"`
+	Disks []string `json:"disks"`
+}
+
+type DiskResource struct {
+	Environment string `json:"environment"`
+	Name        string `json:"name"`
+	Health      string `json:"health"`
+	// 0 if no quota has been defined
+	Quota uint64 `json:"quota"`
+	// nil if the disk has not been scanned yet
+	LastScan    *history.Scan       `json:"last_scan"`
+	Directories []DirectoryResource `json:"directories,omitempty"`
+}
+
+type DirectoryResource struct {
+	Alias     string                   `json:"alias"`
+	Pattern   string                   `json:"pattern"`
+	Variables []string                 `json:"variables"`
+	Health    string                   `json:"health"`
+	Files     []FileDefinitionResource `json:"files,omitempty"`
+}
+
+type RetentionResource struct {
+	Count      uint64  `json:"count"`
+	AgeSeconds float64 `json:"age_seconds"`
+	Daily      uint64  `json:"daily"`
+	Weekly     uint64  `json:"weekly"`
+	Monthly    uint64  `json:"monthly"`
+	Yearly     uint64  `json:"yearly"`
+}
+
+type FileDefinitionResource struct {
+	Alias    string `json:"alias"`
+	Pattern  string `json:"pattern"`
+	Schedule string `json:"schedule"`
+	// the latest scheduled run, for which a backup file is expected
+	ExpectedAt time.Time         `json:"expected_at"`
+	NextRunAt  time.Time         `json:"next_run_at"`
+	Retention  RetentionResource `json:"retention"`
+	Purge      bool              `json:"purge"`
+	// `delete` or `move`
+	PurgeAction string          `json:"purge_action"`
+	Health      string          `json:"health"`
+	Groups      []GroupResource `json:"groups,omitempty"`
+}
+
+type GroupResource struct {
+	Name       string        `json:"name"`
+	Health     string        `json:"health"`
+	FileCount  int           `json:"file_count"`
+	YoungCount uint64        `json:"young_count"`
+	Latest     *FileResource `json:"latest"`
+	// all backup files of the group, newest first; only included in the resource of a single group
+	Files []FileResource `json:"files,omitempty"`
+}
+
+type FileResource struct {
+	Name       string    `json:"name"`
+	Parent     string    `json:"parent"`
+	Size       int64     `json:"size"`
+	SortTime   time.Time `json:"sort_time"`
+	BornAt     time.Time `json:"born_at"`
+	ModifiedAt time.Time `json:"modified_at"`
+	ArchivedAt time.Time `json:"archived_at"`
+	// the timestamp parsed from the file's and directory's name
+	InterpolatedAt *time.Time `json:"interpolated_at,omitempty"`
+	Checksum       string     `json:"checksum,omitempty"`
+	StorageClass   string     `json:"storage_class,omitempty"`
+}
+
+// DescribeEnvironments Return all environments and the names of their disks, ordered by name
+func DescribeEnvironments() []EnvironmentResource {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r := make([]EnvironmentResource, 0, len(clients))
+
+	for name, client := range clients {
+		environment := EnvironmentResource{Name: name, Disks: make([]string, 0, len(client.Disks))}
+
+		for diskName := range client.Disks {
+			environment.Disks = append(environment.Disks, diskName)
+		}
+
+		sort.Strings(environment.Disks)
+		r = append(r, environment)
+	}
+
+	sort.Slice(r, func(i int, j int) bool {
+		return r[i].Name < r[j].Name
+	})
+
+	return r
+}
+
+// DescribeDisks Return all disks without their directories, ordered by environment and name
+func DescribeDisks() []DiskResource {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r := []DiskResource{}
+
+	for _, client := range clients {
+		for _, disk := range client.Disks {
+			r = append(r, disk.describe(false, time.Now()))
+		}
+	}
+
+	sort.Slice(r, func(i int, j int) bool {
+		if r[i].Environment != r[j].Environment {
+			return r[i].Environment < r[j].Environment
+		}
+
+		return r[i].Name < r[j].Name
+	})
+
+	return r
+}
+
+// DescribeDisk Return the disk with its directories and file definitions
+func DescribeDisk(diskName string) (*DiskResource, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// #41: disks with the same name in several environments cannot be described by their name
+	disk, err := lookupDisk(diskName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := disk.describe(true, time.Now())
+
+	return &r, nil
+}
+
+// DescribeDirectory Return the directory with its file definitions
+func DescribeDirectory(diskName string, dirName string) (*DirectoryResource, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	disk, iDir, err := findDirectoryIndex(diskName, dirName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := disk.describeDirectory(iDir, true, time.Now())
+
+	return &r, nil
+}
+
+// DescribeFileDefinition Return the file definition with its groups
+func DescribeFileDefinition(diskName string, dirName string, fileName string) (*FileDefinitionResource, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	disk, iDir, err := findDirectoryIndex(diskName, dirName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for k, fileDef := range disk.Definition.Directories[iDir].Files {
+		if fileDef.Alias == fileName {
+			r := disk.describeFile(iDir, k, true, time.Now())
+			return &r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: '%s'", ErrFileNotFound, fileName)
+}
+
+// DescribeGroup Return the group of the file definition with all of its backup files
+func DescribeGroup(diskName string, dirName string, fileName string, groupName string) (*GroupResource, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	disk, iDir, err := findDirectoryIndex(diskName, dirName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dirDef := disk.Definition.Directories[iDir]
+
+	for k, fileDef := range dirDef.Files {
+		if fileDef.Alias != fileName {
+			continue
+		}
+
+		files, exists := disk.files[iDir][groupName]
+
+		if !exists || files[k] == nil {
+			return nil, fmt.Errorf("%w: '%s'", ErrGroupNotFound, groupName)
+		}
+
+		r := disk.describeGroup(dirDef, fileDef, groupName, files[k], true)
+
+		return &r, nil
+	}
+
+	return nil, fmt.Errorf("%w: '%s'", ErrFileNotFound, fileName)
+}
+
+// PurgeCandidate a backup file which is not retained by the retention policy of its file definition
+type PurgeCandidate struct {
+	Directory string `json:"directory"`
+	File      string `json:"file"`
+	Group     string `json:"group"`
+	// whether the file definition purges its files at all; in dry-run mode, purging is enabled
+	Purge bool `json:"purge"`
+	FileResource
+}
+
+// PurgeCandidates Return the backup files of the disk which are not retained by the retention policies, as of the latest
+// scan, ordered by directory, file definition, group and age. Empty directory and file names match all of them. Files
+// are candidates regardless of whether purging is enabled, in dry-run mode or refused by a guard; see #44.
+func PurgeCandidates(diskName string, dirName string, fileName string) ([]PurgeCandidate, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	disk, err := lookupDisk(diskName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return disk.purgeCandidates(dirName, fileName, time.Now().UTC()), nil
+}
+
+func (disk *DiskData) purgeCandidates(dirName string, fileName string, now time.Time) []PurgeCandidate {
+	r := []PurgeCandidate{}
+
+	if disk.Definition == nil {
+		return r
+	}
+
+	for iDir, dirDef := range disk.Definition.Directories {
+		if (dirName != "" && dirDef.Alias != dirName) || iDir >= len(disk.files) {
+			continue
+		}
+
+		groups := make([]string, 0, len(disk.files[iDir]))
+
+		for group := range disk.files[iDir] {
+			groups = append(groups, group)
+		}
+
+		sort.Strings(groups)
+
+		for k, fileDef := range dirDef.Files {
+			if fileName != "" && fileDef.Alias != fileName {
+				continue
+			}
+
+			for _, group := range groups {
+				files := disk.files[iDir][group][k]
+
+				if files == nil {
+					continue
+				}
+
+				policy := applyRetention(files.Files, fileDef, now)
+
+				for i := range files.Files {
+					if !policy.keep[i] {
+						r = append(r, PurgeCandidate{
+							Directory:    dirDef.Alias,
+							File:         fileDef.Alias,
+							Group:        group,
+							Purge:        fileDef.Purge,
+							FileResource: describeFile(&files.Files[i]),
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+func findDirectoryIndex(diskName string, dirName string) (*DiskData, int, error) {
+	disk, err := lookupDisk(diskName)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if disk.Definition != nil {
+		for iDir, dirDef := range disk.Definition.Directories {
+			if dirDef.Alias == dirName {
+				return disk, iDir, nil
+			}
+		}
+	}
+
+	return nil, 0, fmt.Errorf("%w: '%s'", ErrDirectoryNotFound, dirName)
+}
+
+func (disk *DiskData) describe(withDirectories bool, now time.Time) DiskResource {
+	r := DiskResource{
+		Environment: disk.Environment,
+		Name:        disk.Name,
+		Health:      disk.health("", "", nil),
+		LastScan:    disk.lastScan,
+	}
+
+	if disk.Definition == nil {
+		return r
+	}
+
+	r.Quota = disk.Definition.Quota
+
+	if withDirectories {
+		r.Directories = make([]DirectoryResource, 0, len(disk.Definition.Directories))
+
+		for iDir := range disk.Definition.Directories {
+			r.Directories = append(r.Directories, disk.describeDirectory(iDir, false, now))
+		}
+	}
+
+	return r
+}
+
+func (disk *DiskData) describeDirectory(iDir int, withFiles bool, now time.Time) DirectoryResource {
+	dirDef := disk.Definition.Directories[iDir]
+	r := DirectoryResource{
+		Alias:     dirDef.Alias,
+		Pattern:   dirDef.Filter.Pattern,
+		Variables: []string{},
+		Health:    disk.health(dirDef.Alias, "", nil),
+		Files:     make([]FileDefinitionResource, 0, len(dirDef.Files)),
+	}
+
+	for _, variable := range dirDef.Filter.Variables {
+		if !variable.Fuse {
+			r.Variables = append(r.Variables, variable.Name)
+		}
+	}
+
+	for k := range dirDef.Files {
+		r.Files = append(r.Files, disk.describeFile(iDir, k, withFiles, now))
+	}
+
+	return r
+}
+
+func (disk *DiskData) describeFile(iDir int, k int, withGroups bool, now time.Time) FileDefinitionResource {
+	dirDef := disk.Definition.Directories[iDir]
+	fileDef := dirDef.Files[k]
+	r := FileDefinitionResource{
+		Alias:      fileDef.Alias,
+		Pattern:    fileDef.Pattern,
+		Schedule:   fileDef.ScheduleExpression,
+		ExpectedAt: backup.FindPrevious(fileDef.Schedule, now),
+		Retention: RetentionResource{
+			Count:      fileDef.RetentionCount,
+			AgeSeconds: fileDef.RetentionAge.Seconds(),
+			Daily:      fileDef.RetentionDaily,
+			Weekly:     fileDef.RetentionWeekly,
+			Monthly:    fileDef.RetentionMonthly,
+			Yearly:     fileDef.RetentionYearly,
+		},
+		Purge:       fileDef.Purge,
+		PurgeAction: fileDef.PurgeAction,
+		Health:      disk.health(dirDef.Alias, fileDef.Alias, nil),
+	}
+
+	if fileDef.Schedule != nil {
+		r.NextRunAt = fileDef.Schedule.Next(now)
+	}
+
+	if !withGroups || iDir >= len(disk.files) {
+		return r
+	}
+
+	r.Groups = []GroupResource{}
+
+	for group, files := range disk.files[iDir] {
+		if files[k] != nil {
+			r.Groups = append(r.Groups, disk.describeGroup(dirDef, fileDef, group, files[k], false))
+		}
+	}
+
+	sort.Slice(r.Groups, func(i int, j int) bool {
+		return r.Groups[i].Name < r.Groups[j].Name
+	})
+
+	return r
+}
+
+func (disk *DiskData) describeGroup(dirDef *backup.Directory, fileDef *backup.FileDefinition, group string, files *GroupFiles, withFiles bool) GroupResource {
+	r := GroupResource{
+		Name:       group,
+		Health:     disk.health(dirDef.Alias, fileDef.Alias, &group),
+		FileCount:  len(files.Files),
+		YoungCount: files.Young,
+	}
+
+	if len(files.Files) > 0 {
+		latest := describeFile(&files.Files[0])
+		r.Latest = &latest
+	}
+
+	if withFiles {
+		r.Files = make([]FileResource, 0, len(files.Files))
+
+		for i := range files.Files {
+			r.Files = append(r.Files, describeFile(&files.Files[i]))
+		}
+	}
+
+	return r
+}
+
+func describeFile(file *TemporalFile) FileResource {
+	return FileResource{
+		Name:           file.File.Name,
+		Parent:         file.File.Parent,
+		Size:           file.File.Size,
+		SortTime:       file.Time,
+		BornAt:         file.File.BornAt,
+		ModifiedAt:     file.File.ModifiedAt,
+		ArchivedAt:     file.File.ArchivedAt,
+		InterpolatedAt: file.File.InterpolatedTimestamp,
+		Checksum:       file.File.Checksum,
+		StorageClass:   file.File.StorageClass,
+	}
+}
+
+// health Return the most severe status of all groups matching the directory, file and group; empty names match
+// everything
+func (disk *DiskData) health(dir string, file string, group *string) string {
+	r := StatusOk
+
+	for _, status := range disk.statuses {
+		if (dir != "" && status.Directory != dir) || (file != "" && status.File != file) || (group != nil && status.Group != *group) {
+			continue
+		}
+
+		if severities[status.Status] > severities[r] {
+			r = status.Status
+		}
+	}
+
+	return r
+}
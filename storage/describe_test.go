@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH41_describeFile_includesGroupsWithHealth(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	fileDef := &backup.FileDefinition{Alias: "dump", ScheduleExpression: "0 2 * * *", RetentionCount: 3}
+	disk := &DiskData{
+		Definition: &backup.Definition{Directories: []*backup.Directory{{Alias: "db", Files: []*backup.FileDefinition{fileDef}}}},
+		files: []map[string][]*GroupFiles{{
+			"b": {{Files: FileGroup{{Time: now, File: &fs.FileInfo{Name: "dump-2.sql", Size: 10}}, {Time: now, File: &fs.FileInfo{Name: "dump-1.sql"}}}, Young: 2}},
+			"a": {{Files: FileGroup{}}},
+		}},
+		statuses: []GroupStatus{
+			{Directory: "db", File: "dump", Group: "a", Status: StatusMissing},
+			{Directory: "db", File: "dump", Group: "b", Status: StatusLate},
+		},
+	}
+
+	sut := disk.describeFile(0, 0, true, now)
+
+	assertion.Equal("0 2 * * *", sut.Schedule)
+	assertion.Equal(uint64(3), sut.Retention.Count)
+	assertion.Equal(StatusMissing, sut.Health)
+	assertion.Len(sut.Groups, 2)
+	assertion.Equal("a", sut.Groups[0].Name)
+	assertion.Nil(sut.Groups[0].Latest)
+	assertion.Equal(StatusLate, sut.Groups[1].Health)
+	assertion.Equal("dump-2.sql", sut.Groups[1].Latest.Name)
+	assertion.Equal(2, sut.Groups[1].FileCount)
+	assertion.Equal(uint64(2), sut.Groups[1].YoungCount)
+	// the files are only included in the resource of a single group
+	assertion.Nil(sut.Groups[1].Files)
+}
+
+func Test_GH44_purgeCandidates_returnsFilesNotRetained(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	fileDef := &backup.FileDefinition{Alias: "dump", RetentionCount: 1}
+	other := &backup.FileDefinition{Alias: "other", RetentionCount: 5}
+	disk := &DiskData{
+		Definition: &backup.Definition{Directories: []*backup.Directory{{Alias: "db", Files: []*backup.FileDefinition{fileDef, other}}}},
+		files: []map[string][]*GroupFiles{{
+			"b": {
+				{Files: FileGroup{{Time: now, File: &fs.FileInfo{Name: "dump-3.sql"}}, {Time: now.Add(-24 * time.Hour), File: &fs.FileInfo{Name: "dump-2.sql"}}}},
+				{Files: FileGroup{{Time: now, File: &fs.FileInfo{Name: "other-1.sql"}}}},
+			},
+			"a": {
+				{Files: FileGroup{{Time: now, File: &fs.FileInfo{Name: "dump-1.sql"}}, {Time: now.Add(-48 * time.Hour), File: &fs.FileInfo{Name: "dump-0.sql", Size: 42}}}},
+				nil,
+			},
+		}},
+	}
+
+	sut := disk.purgeCandidates("", "", now)
+
+	assertion.Len(sut, 2)
+	assertion.Equal("a", sut[0].Group)
+	assertion.Equal("dump-0.sql", sut[0].Name)
+	assertion.Equal(int64(42), sut[0].Size)
+	assertion.Equal("b", sut[1].Group)
+	assertion.Equal("dump-2.sql", sut[1].Name)
+	assertion.False(sut[1].Purge)
+	assertion.Empty(disk.purgeCandidates("db", "other", now))
+	assertion.Empty(disk.purgeCandidates("unknown", "", now))
+}
+
+func Test_GH45_selectBackup_findsBackupByNameOrTimestamp(t *testing.T) {
+	assertion := assert.New(t)
+	day := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+	files := FileGroup{
+		{Time: day, File: &fs.FileInfo{Parent: "db", Name: "dump-3.sql"}},
+		{Time: day.Add(-24 * time.Hour), File: &fs.FileInfo{Parent: "db", Name: "dump-2.sql"}},
+		{Time: day.Add(-48 * time.Hour), File: &fs.FileInfo{Parent: "db", Name: "dump-1.sql"}},
+	}
+
+	assertion.Equal("dump-3.sql", selectBackup(files, "", time.Time{}).Name)
+	assertion.Equal("dump-1.sql", selectBackup(files, "dump-1.sql", time.Time{}).Name)
+	assertion.Equal("dump-2.sql", selectBackup(files, "db/dump-2.sql", time.Time{}).Name)
+	assertion.Nil(selectBackup(files, "dump-4.sql", time.Time{}))
+	// the newest backup not younger than the timestamp
+	assertion.Equal("dump-2.sql", selectBackup(files, "1711764000", day.Add(-12*time.Hour)).Name)
+	assertion.Equal("dump-1.sql", selectBackup(files, "", day.Add(-48*time.Hour)).Name)
+	assertion.Nil(selectBackup(files, "", day.Add(-72*time.Hour)))
+	assertion.Nil(selectBackup(nil, "", time.Time{}))
+}
+
+func Test_GH48_lookupDisk_rejectsAmbiguousNames(t *testing.T) {
+	assertion := assert.New(t)
+	previous := clients
+	t.Cleanup(func() { clients = previous })
+
+	clients = map[string]*clientData{
+		"prod": {Disks: map[string]*DiskData{"shared": {Environment: "prod"}, "db": {Environment: "prod"}}},
+		"test": {Disks: map[string]*DiskData{"shared": {Environment: "test"}}},
+	}
+
+	disk, err := lookupDisk("db")
+	assertion.NoError(err)
+	assertion.Equal("prod", disk.Environment)
+
+	_, err = lookupDisk("shared")
+	assertion.ErrorIs(err, ErrDiskAmbiguous)
+	assertion.Nil(FindDisk("shared"))
+	assertion.Nil(findClient("shared"))
+	assertion.Equal([]string{"prod", "test"}, EnvironmentsOfDisk("shared"))
+
+	_, err = lookupDisk("unknown")
+	assertion.ErrorIs(err, ErrDiskNotFound)
+	assertion.Empty(EnvironmentsOfDisk("unknown"))
+}
+
+func Test_GH41_DescribeDisk_rejectsAmbiguousNames(t *testing.T) {
+	assertion := assert.New(t)
+	previous := clients
+	t.Cleanup(func() { clients = previous })
+
+	clients = map[string]*clientData{
+		"prod": {Disks: map[string]*DiskData{"shared": {Environment: "prod"}}},
+		"test": {Disks: map[string]*DiskData{"shared": {Environment: "test"}}},
+	}
+
+	_, err := DescribeDisk("shared")
+	assertion.ErrorIs(err, ErrDiskAmbiguous)
+
+	_, err = DescribeDirectory("shared", "db")
+	assertion.ErrorIs(err, ErrDiskAmbiguous)
+
+	_, err = PurgeCandidates("shared", "", "")
+	assertion.ErrorIs(err, ErrDiskAmbiguous)
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"path"
+
+	"github.com/dreitier/backmon/events"
+	fs "github.com/dreitier/backmon/storage/fs"
+)
+
+// publish publishes an event of the disk, see #40
+func (disk *DiskData) publish(event events.Event) {
+	event.Environment = disk.Environment
+	event.Disk = disk.Name
+
+	events.Publish(event)
+}
+
+// publishGroupChanges publishes the changes of a group since the previous scan. Without a previous scan of the
+// directory, nothing is published, as every group would have appeared.
+func (disk *DiskData) publishGroupChanges(dir string, files []string, group string, previous map[string][]*GroupFiles, latest []*fs.FileInfo) {
+	if previous == nil {
+		return
+	}
+
+	previousFiles, existed := previous[group]
+
+	if !existed {
+		disk.publish(events.Event{Type: events.TypeGroupAppeared, Directory: dir, Group: group})
+	}
+
+	for k, file := range latest {
+		if file == nil {
+			continue
+		}
+
+		var previousLatest *fs.FileInfo
+
+		if k < len(previousFiles) {
+			previousLatest = previousFiles[k].latest()
+		}
+
+		if previousLatest != nil && previousLatest.Parent == file.Parent && previousLatest.Name == file.Name {
+			continue
+		}
+
+		disk.publish(events.Event{
+			Type:      events.TypeLatestFile,
+			Directory: dir,
+			File:      files[k],
+			Group:     group,
+			Name:      path.Join(file.Parent, file.Name),
+		})
+	}
+}
+
+// publishStatusChanges publishes all groups whose status differs from the previous scan
+func (disk *DiskData) publishStatusChanges(previous []GroupStatus) {
+	if previous == nil {
+		return
+	}
+
+	key := func(status *GroupStatus) string {
+		return jobKey(status.Directory, status.File, status.Group)
+	}
+
+	previousStatuses := make(map[string]string, len(previous))
+
+	for i := range previous {
+		previousStatuses[key(&previous[i])] = previous[i].Status
+	}
+
+	for i := range disk.statuses {
+		status := &disk.statuses[i]
+		previousStatus, existed := previousStatuses[key(status)]
+
+		if !existed || previousStatus == status.Status {
+			continue
+		}
+
+		disk.publish(events.Event{
+			Type:           events.TypeStatusChanged,
+			Directory:      status.Directory,
+			File:           status.File,
+			Group:          status.Group,
+			Status:         status.Status,
+			PreviousStatus: previousStatus,
+		})
+	}
+}
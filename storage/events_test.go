@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/dreitier/backmon/events"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH40_publishGroupChanges_publishesNewLatestFilesAndGroups(t *testing.T) {
+	assertion := assert.New(t)
+	received, cancel := events.GetInstance().Subscribe(events.Filter{Disk: "events-test"}, 0)
+	defer cancel()
+
+	disk := &DiskData{Environment: "prod", Name: "events-test"}
+	files := []string{"dump", "log"}
+	unchanged := &fs.FileInfo{Parent: "db", Name: "dump-1.log"}
+	previous := map[string][]*GroupFiles{
+		"a": {
+			{Files: FileGroup{{File: &fs.FileInfo{Parent: "db", Name: "dump-1.sql"}}}},
+			{Files: FileGroup{{File: unchanged}}},
+		},
+	}
+
+	// without a previous scan, nothing has changed
+	disk.publishGroupChanges("db", files, "a", nil, []*fs.FileInfo{{Parent: "db", Name: "dump-2.sql"}, unchanged})
+	assertion.Len(received, 0)
+
+	disk.publishGroupChanges("db", files, "a", previous, []*fs.FileInfo{{Parent: "db", Name: "dump-2.sql"}, unchanged})
+	disk.publishGroupChanges("db", files, "b", previous, []*fs.FileInfo{nil, nil})
+
+	assertion.Len(received, 2)
+	event := <-received
+	assertion.Equal(events.TypeLatestFile, event.Type)
+	assertion.Equal("dump", event.File)
+	assertion.Equal("db/dump-2.sql", event.Name)
+	assertion.Equal(events.TypeGroupAppeared, (<-received).Type)
+}
+
+func Test_GH40_publishStatusChanges_publishesChangedStatusesOnly(t *testing.T) {
+	assertion := assert.New(t)
+	received, cancel := events.GetInstance().Subscribe(events.Filter{Disk: "status-test"}, 0)
+	defer cancel()
+
+	disk := &DiskData{Environment: "prod", Name: "status-test", statuses: []GroupStatus{
+		{Directory: "db", File: "dump", Group: "a", Status: StatusLate},
+		{Directory: "db", File: "dump", Group: "b", Status: StatusOk},
+		{Directory: "db", File: "dump", Group: "c", Status: StatusOk},
+	}}
+
+	disk.publishStatusChanges([]GroupStatus{
+		{Directory: "db", File: "dump", Group: "a", Status: StatusOk},
+		{Directory: "db", File: "dump", Group: "b", Status: StatusOk},
+	})
+
+	assertion.Len(received, 1)
+	event := <-received
+	assertion.Equal("a", event.Group)
+	assertion.Equal(StatusLate, event.Status)
+	assertion.Equal(StatusOk, event.PreviousStatus)
+}
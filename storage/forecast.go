@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/forecast"
+	"github.com/dreitier/backmon/history"
+	log "github.com/sirupsen/logrus"
+)
+
+// usageSeries are the usage samples of a disk and of each of its file definitions, see #35
+type usageSeries struct {
+	disk        *forecast.Series
+	definitions map[string]*forecast.Series
+}
+
+func (u *usageSeries) add(scan *history.Scan, hasDefinitions bool) {
+	u.disk.Add(forecast.Point{Time: scan.Time, Value: float64(scan.Bytes)})
+
+	if !hasDefinitions {
+		return
+	}
+
+	for _, usage := range scan.Definitions {
+		key := definitionKey(usage.Directory, usage.File)
+		series, exists := u.definitions[key]
+
+		if !exists {
+			series = forecast.NewSeries(u.disk.Window())
+			u.definitions[key] = series
+		}
+
+		series.Add(forecast.Point{Time: scan.Time, Value: float64(usage.Bytes)})
+	}
+}
+
+// updateForecast adds the successful scan to the usage samples and updates the forecast metrics. After a restart, the
+// samples are restored from the history.
+func (disk *DiskData) updateForecast(scan *history.Scan, now time.Time) {
+	cfg := config.GetInstance().Global().Forecast()
+
+	if disk.usage == nil {
+		disk.usage = &usageSeries{
+			disk:        forecast.NewSeries(cfg.Window),
+			definitions: make(map[string]*forecast.Series),
+		}
+
+		if store := history.GetInstance(); store != nil {
+			scans, err := store.Scans(history.Query{Environment: disk.Environment, Disk: disk.Name, From: now.Add(-cfg.Window)})
+
+			if err != nil {
+				log.Warnf("Could not restore usage history of disk '%s': %s", disk.Name, err)
+			}
+
+			for i := range scans {
+				if scans[i].Error == "" {
+					disk.usage.add(&scans[i], len(scans[i].Definitions) > 0)
+				}
+			}
+		}
+	}
+
+	// a failed scan would look like an empty disk
+	if scan.Error != "" {
+		return
+	}
+
+	disk.usage.add(scan, disk.Definition != nil)
+
+	trend, hasTrend := forecast.Fit(disk.usage.disk.Points())
+	var growth, projected float64
+	var exhaustion time.Time
+
+	if hasTrend {
+		growth = trend.PerDay()
+		projected = trend.At(now.Add(cfg.Horizon))
+
+		if disk.Definition != nil && disk.Definition.Quota > 0 {
+			exhaustion, _ = trend.Reaches(float64(disk.Definition.Quota), now)
+		}
+	}
+
+	disk.metrics.UpdateUsageForecast(hasTrend, growth, projected, exhaustion)
+
+	if disk.Definition == nil {
+		return
+	}
+
+	for _, dirDef := range disk.Definition.Directories {
+		for _, fileDef := range dirDef.Files {
+			series, exists := disk.usage.definitions[definitionKey(dirDef.Alias, fileDef.Alias)]
+			hasTrend = false
+			growth = 0
+
+			if exists {
+				if trend, hasTrend = forecast.Fit(series.Points()); hasTrend {
+					growth = trend.PerDay()
+				}
+			}
+
+			disk.metrics.UpdateFileGrowth(dirDef.Alias, fileDef.Alias, hasTrend, growth)
+		}
+	}
+}
+
+func definitionKey(directory string, file string) string {
+	return directory + "/" + file
+}
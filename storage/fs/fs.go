@@ -30,6 +30,25 @@ type FileInfo struct {
 	ArchivedAt time.Time
 	// An optional timestamp based upon the file's path substitution variables
 	InterpolatedTimestamp *time.Time
+	// Hex encoded MD5 checksum of the file's content, if it is known without reading the file (e.g. from an S3 ETag)
+	Checksum string
+	// Storage class of the file, e.g. STANDARD or GLACIER for S3 objects; empty if the storage has no storage classes
+	StorageClass string
+}
+
+// LockStatus describes the immutability of a file, e.g. through S3 Object Lock
+type LockStatus struct {
+	// retention mode, e.g. GOVERNANCE or COMPLIANCE; empty if no retention is set
+	Mode string
+	// the file cannot be deleted before this timestamp
+	RetainUntil time.Time
+	// the file cannot be deleted as long as a legal hold is in place
+	LegalHold bool
+}
+
+// IsLocked Return true if the file cannot be deleted at the given moment
+func (status *LockStatus) IsLocked(moment time.Time) bool {
+	return status.LegalHold || status.RetainUntil.After(moment)
 }
 
 func IsFilePathValid(path string) (bool, error) {
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// names of the guards which can refuse a purge run, used as metric label values
+const (
+	PurgeGuardHealthy            = "healthy"
+	PurgeGuardMaxFiles           = "max_files"
+	PurgeGuardMaxBytes           = "max_bytes"
+	PurgeGuardLate               = "late"
+	PurgeGuardDefinitionsChanged = "definitions_changed"
+	PurgeGuardPaused             = "paused"
+)
+
+// PurgeState whether purging of a disk has been paused; see #48
+type PurgeState struct {
+	Environment string `json:"environment"`
+	Disk        string `json:"disk"`
+	Paused      bool   `json:"paused"`
+}
+
+// GetPurgeStates Return the purge state of all disks
+func GetPurgeStates() []PurgeState {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r := []PurgeState{}
+
+	for environmentName, client := range clients {
+		for diskName, disk := range client.Disks {
+			r = append(r, PurgeState{Environment: environmentName, Disk: diskName, Paused: disk.purgePaused})
+		}
+	}
+
+	sort.Slice(r, func(i int, j int) bool {
+		if r[i].Environment != r[j].Environment {
+			return r[i].Environment < r[j].Environment
+		}
+
+		return r[i].Disk < r[j].Disk
+	})
+
+	return r
+}
+
+// PausePurging pauses or resumes purging of the disk until restart. While paused, excess files are kept as if a guard
+// had refused to purge them.
+func PausePurging(environmentName string, diskName string, paused bool) (*PurgeState, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	client, exists := clients[environmentName]
+
+	if !exists {
+		return nil, fmt.Errorf("%w: environment '%s'", ErrDiskNotFound, environmentName)
+	}
+
+	disk, exists := client.Disks[diskName]
+
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrDiskNotFound, diskName)
+	}
+
+	disk.purgePaused = paused
+
+	if paused {
+		log.Warnf("[env:%s][disk:%s] Purging has been paused", environmentName, diskName)
+	} else {
+		log.Infof("[env:%s][disk:%s] Purging has been resumed", environmentName, diskName)
+	}
+
+	return &PurgeState{Environment: environmentName, Disk: diskName, Paused: paused}, nil
+}
+
+// checkPurgeGuards returns the name of the first guard refusing to purge the excess files of the newest-first sorted
+// list, together with an explanation. An empty guard name means that purging is allowed.
+func checkPurgeGuards(
+	list FileGroup,
+	policy *retention,
+	fileDef *backup.FileDefinition,
+	guards *config.PurgeGuardsConfiguration,
+	definitionsChangedAt time.Time,
+	now time.Time,
+) (guard string, reason string) {
+	if guards.DefinitionsGrace > 0 && !definitionsChangedAt.IsZero() && now.Sub(definitionsChangedAt) < guards.DefinitionsGrace {
+		return PurgeGuardDefinitionsChanged, fmt.Sprintf("the definitions file changed at %s, which is within the last %s",
+			definitionsChangedAt.Format(time.RFC3339), guards.DefinitionsGrace)
+	}
+
+	if len(list) > 0 && backup.IsLate(fileDef.Schedule, list[0].Time, now) {
+		return PurgeGuardLate, fmt.Sprintf("the newest file '%s' from %s is late", list[0].File.Name, list[0].Time.Format(time.RFC3339))
+	}
+
+	// files cannot be younger than the next scheduled run; if they are, the sort time is wrong or the clock is skewed
+	youngest := now
+	if fileDef.Schedule != nil {
+		if next := fileDef.Schedule.Next(now); !next.IsZero() {
+			youngest = next
+		}
+	}
+
+	healthy := uint64(0)
+	excessFiles := uint64(0)
+	excessBytes := int64(0)
+	totalBytes := int64(0)
+
+	for i, file := range list {
+		totalBytes += file.File.Size
+
+		if !policy.keep[i] {
+			excessFiles++
+			excessBytes += file.File.Size
+		} else if !file.Time.After(youngest) {
+			healthy++
+		}
+	}
+
+	if healthy < fileDef.RetentionCount {
+		return PurgeGuardHealthy, fmt.Sprintf("only %d of the remaining files are not younger than the schedule allows, but %d are required",
+			healthy, fileDef.RetentionCount)
+	}
+
+	maxFiles := guards.MaxFiles
+	if fileDef.PurgeMaxFiles > 0 {
+		maxFiles = fileDef.PurgeMaxFiles
+	}
+
+	if maxFiles > 0 && excessFiles > maxFiles {
+		return PurgeGuardMaxFiles, fmt.Sprintf("%d files would be purged, but at most %d are allowed in one run", excessFiles, maxFiles)
+	}
+
+	maxBytesPercent := guards.MaxBytesPercent
+	if fileDef.PurgeMaxBytesPercent > 0 {
+		maxBytesPercent = fileDef.PurgeMaxBytesPercent
+	}
+
+	if maxBytesPercent > 0 && totalBytes > 0 {
+		percent := float64(excessBytes) * 100 / float64(totalBytes)
+
+		if percent > maxBytesPercent {
+			return PurgeGuardMaxBytes, fmt.Sprintf("%.1f%% of the bytes would be purged, but at most %.1f%% are allowed in one run", percent, maxBytesPercent)
+		}
+	}
+
+	return "", ""
+}
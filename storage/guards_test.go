@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	"github.com/gorhill/cronexpr"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH28_checkPurgeGuards_allowsRegularPurge(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now.Add(-10*time.Hour), 10)
+	fileDef := &backup.FileDefinition{Schedule: cronexpr.MustParse("0 2 * * *"), RetentionCount: 3}
+
+	guard, _ := checkPurgeGuards(list, applyRetention(list, fileDef, now), fileDef, &config.PurgeGuardsConfiguration{}, time.Time{}, now)
+
+	assertion.Equal("", guard)
+}
+
+func Test_GH28_checkPurgeGuards_refusesIfNewestFileIsLate(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now.AddDate(0, 0, -2), 10)
+	fileDef := &backup.FileDefinition{Schedule: cronexpr.MustParse("0 2 * * *"), RetentionCount: 3}
+
+	guard, _ := checkPurgeGuards(list, applyRetention(list, fileDef, now), fileDef, &config.PurgeGuardsConfiguration{}, time.Time{}, now)
+
+	assertion.Equal(PurgeGuardLate, guard)
+}
+
+func Test_GH28_checkPurgeGuards_refusesFilesFromTheFuture(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	// e.g. a skewed clock: the two newest files are dated in one and two days
+	list := dailyFiles(now.AddDate(0, 0, 2), 10)
+	fileDef := &backup.FileDefinition{RetentionCount: 3}
+
+	guard, _ := checkPurgeGuards(list, applyRetention(list, fileDef, now), fileDef, &config.PurgeGuardsConfiguration{}, time.Time{}, now)
+
+	assertion.Equal(PurgeGuardHealthy, guard)
+}
+
+func Test_GH28_checkPurgeGuards_limitsFilesAndBytes(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now.Add(-time.Hour), 10)
+	fileDef := &backup.FileDefinition{RetentionCount: 5}
+	policy := applyRetention(list, fileDef, now)
+
+	guard, _ := checkPurgeGuards(list, policy, fileDef, &config.PurgeGuardsConfiguration{MaxFiles: 4}, time.Time{}, now)
+	assertion.Equal(PurgeGuardMaxFiles, guard)
+
+	for i := range list {
+		list[i].File.Size = 100
+	}
+
+	guard, _ = checkPurgeGuards(list, policy, fileDef, &config.PurgeGuardsConfiguration{MaxBytesPercent: 40}, time.Time{}, now)
+	assertion.Equal(PurgeGuardMaxBytes, guard)
+
+	// the file definition overrides the global limit
+	fileDef.PurgeMaxBytesPercent = 50
+	guard, _ = checkPurgeGuards(list, policy, fileDef, &config.PurgeGuardsConfiguration{MaxBytesPercent: 40}, time.Time{}, now)
+	assertion.Equal("", guard)
+}
+
+func Test_GH28_checkPurgeGuards_refusesAfterDefinitionsChanged(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now.Add(-time.Hour), 10)
+	fileDef := &backup.FileDefinition{RetentionCount: 5}
+	guards := &config.PurgeGuardsConfiguration{DefinitionsGrace: 30 * time.Minute}
+
+	guard, _ := checkPurgeGuards(list, applyRetention(list, fileDef, now), fileDef, guards, now.Add(-10*time.Minute), now)
+	assertion.Equal(PurgeGuardDefinitionsChanged, guard)
+
+	guard, _ = checkPurgeGuards(list, applyRetention(list, fileDef, now), fileDef, guards, now.Add(-time.Hour), now)
+	assertion.Equal("", guard)
+}
@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// kinds of heartbeats sent by backup jobs, see #38
+const (
+	HeartbeatStart   = "start"
+	HeartbeatSuccess = "success"
+	HeartbeatFail    = "fail"
+)
+
+// the clocks of backup jobs and storages may differ slightly
+const heartbeatClockSkew = time.Minute
+
+// ErrUnknownDefinition the disk, directory or file of a heartbeat is not defined
+var ErrUnknownDefinition = errors.New("unknown backup definition")
+
+// Heartbeat a ping of a backup job
+type Heartbeat struct {
+	Kind string
+	Time time.Time
+	// reported duration of the run; 0 if the job did not report it
+	Duration time.Duration
+	// reported size of the backup; 0 if the job did not report it
+	Size int64
+	Log  string
+}
+
+// JobStatus the latest heartbeats of the backup job of a group
+type JobStatus struct {
+	Environment string    `json:"environment"`
+	Disk        string    `json:"disk"`
+	Directory   string    `json:"directory"`
+	File        string    `json:"file"`
+	Group       string    `json:"group"`
+	Status      string    `json:"status"`
+	LastStart   time.Time `json:"last_start"`
+	LastSuccess time.Time `json:"last_success"`
+	LastFailure time.Time `json:"last_failure"`
+	// duration of the latest finished run, either reported or measured from its start
+	DurationSeconds float64 `json:"duration_seconds"`
+	Size            int64   `json:"size"`
+	Log             string  `json:"log,omitempty"`
+	// the job has reported a success, but no file has appeared within the grace period
+	Unconfirmed bool `json:"unconfirmed"`
+}
+
+func (job *JobStatus) duration() time.Duration {
+	return time.Duration(job.DurationSeconds * float64(time.Second))
+}
+
+// failed Return true if the latest finished run has failed
+func (job *JobStatus) failed() bool {
+	return job.LastFailure.After(job.LastSuccess)
+}
+
+// runStartedAt Return when the latest successful run has started
+func (job *JobStatus) runStartedAt() time.Time {
+	if job.DurationSeconds > 0 {
+		return job.LastSuccess.Add(-job.duration())
+	}
+
+	if !job.LastStart.IsZero() && job.LastStart.Before(job.LastSuccess) {
+		return job.LastStart
+	}
+
+	return job.LastSuccess
+}
+
+func jobKey(dir string, file string, group string) string {
+	return dir + "\x00" + file + "\x00" + group
+}
+
+var (
+	// #38: guards the jobs of all disks and the heartbeat targets; heartbeats must not wait for a running scan, which
+	// holds the mutex
+	jobsMutex = &sync.Mutex{}
+	// the disks accepting heartbeats by their name
+	heartbeatTargets = make(map[string][]*heartbeatTarget)
+)
+
+// heartbeatTarget the definitions and groups of a disk as of its latest scan
+type heartbeatTarget struct {
+	disk       *DiskData
+	definition *backup.Definition
+	groups     []map[string][]*GroupFiles
+}
+
+// publishHeartbeatTargets makes the current definitions and groups of all disks available to heartbeats. The caller
+// must hold the mutex.
+func publishHeartbeatTargets() {
+	targets := make(map[string][]*heartbeatTarget)
+
+	for _, client := range clients {
+		for diskName, disk := range client.Disks {
+			targets[diskName] = append(targets[diskName], &heartbeatTarget{
+				disk:       disk,
+				definition: disk.Definition,
+				// the groups of each directory are replaced, but never modified by a scan
+				groups: append([]map[string][]*GroupFiles(nil), disk.files...),
+			})
+		}
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	heartbeatTargets = targets
+}
+
+// RecordHeartbeat records the heartbeat of the backup job of a group
+func RecordHeartbeat(diskName string, dirName string, fileName string, group string, heartbeat Heartbeat) (*JobStatus, error) {
+	return recordHeartbeat(diskName, dirName, fileName, group, heartbeat, func() uint64 {
+		return config.GetInstance().Global().Heartbeats().MaxLogSize
+	})
+}
+
+// recordHeartbeat records the heartbeat; the maximum log size is only looked up for known jobs
+func recordHeartbeat(diskName string, dirName string, fileName string, group string, heartbeat Heartbeat, maxLogSize func() uint64) (*JobStatus, error) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	// disks with the same name in several environments cannot be addressed by their name only
+	targets := heartbeatTargets[diskName]
+
+	if len(targets) != 1 || targets[0].definition == nil {
+		return nil, fmt.Errorf("%w: disk '%s'", ErrUnknownDefinition, diskName)
+	}
+
+	target := targets[0]
+	disk := target.disk
+	dirDef, fileDef := findFileDefinition(target.definition, dirName, fileName)
+
+	if fileDef == nil {
+		return nil, fmt.Errorf("%w: file '%s' in directory '%s'", ErrUnknownDefinition, fileName, dirName)
+	}
+
+	// #38: jobs of arbitrary groups would be kept forever
+	if !target.hasGroup(dirDef, group) {
+		return nil, fmt.Errorf("%w: group '%s' in directory '%s'", ErrUnknownDefinition, group, dirName)
+	}
+
+	key := jobKey(dirDef.Alias, fileDef.Alias, group)
+
+	if disk.jobs == nil {
+		disk.jobs = make(map[string]*JobStatus)
+	}
+
+	job, exists := disk.jobs[key]
+
+	if !exists {
+		job = &JobStatus{Environment: disk.Environment, Disk: disk.Name, Directory: dirDef.Alias, File: fileDef.Alias, Group: group}
+		disk.jobs[key] = job
+	}
+
+	job.update(heartbeat, maxLogSize())
+	disk.metrics.UpdateJob(job.Directory, job.File, job.Group, job.LastSuccess, job.LastFailure, job.duration())
+
+	log.Infof("[env:%s][disk:%s] Backup job of %s/%s [%s] reported %s", disk.Environment, disk.Name, dirDef.Alias, fileDef.Alias, group, heartbeat.Kind)
+
+	copied := *job
+
+	return &copied, nil
+}
+
+func (job *JobStatus) update(heartbeat Heartbeat, maxLogSize uint64) {
+	job.Status = heartbeat.Kind
+
+	if heartbeat.Kind == HeartbeatStart {
+		job.LastStart = heartbeat.Time
+		return
+	}
+
+	duration := heartbeat.Duration
+
+	// measure the duration from the start of this run if the job has not reported it
+	if duration <= 0 && !job.LastStart.IsZero() && job.LastStart.After(job.LastSuccess) && job.LastStart.After(job.LastFailure) {
+		duration = heartbeat.Time.Sub(job.LastStart)
+	}
+
+	job.DurationSeconds = duration.Seconds()
+	job.Size = heartbeat.Size
+	job.Log = heartbeat.Log
+
+	if maxLogSize > 0 && uint64(len(job.Log)) > maxLogSize {
+		// the end of the log usually tells what went wrong
+		job.Log = job.Log[uint64(len(job.Log))-maxLogSize:]
+	}
+
+	if heartbeat.Kind == HeartbeatSuccess {
+		job.LastSuccess = heartbeat.Time
+		job.Unconfirmed = false
+	} else {
+		job.LastFailure = heartbeat.Time
+	}
+}
+
+// GetJobs Return the status of all backup jobs which have sent a heartbeat
+func GetJobs() []JobStatus {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	r := []JobStatus{}
+
+	for _, targets := range heartbeatTargets {
+		for _, target := range targets {
+			for _, job := range target.disk.jobs {
+				r = append(r, *job)
+			}
+		}
+	}
+
+	return r
+}
+
+// confirmJob correlates the latest success of the group's backup job with the latest file. The success is unconfirmed
+// if no file has been modified since the start of the run, once the grace period has passed.
+func (disk *DiskData) confirmJob(dirDef *backup.Directory, fileDef *backup.FileDefinition, group string, latest *TemporalFile, grace time.Duration, now time.Time) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	job := disk.jobs[jobKey(dirDef.Alias, fileDef.Alias, group)]
+
+	if job == nil {
+		return
+	}
+
+	// the metrics may have been reset since the heartbeat
+	disk.metrics.UpdateJob(job.Directory, job.File, job.Group, job.LastSuccess, job.LastFailure, job.duration())
+
+	if job.LastSuccess.IsZero() {
+		return
+	}
+
+	confirmed := latest != nil && !latest.File.ModifiedAt.Before(job.runStartedAt().Add(-heartbeatClockSkew))
+	job.Unconfirmed = !confirmed && now.Sub(job.LastSuccess) >= grace
+
+	// the reported duration only belongs to the latest file if it has been created by the latest run
+	duration := time.Duration(0)
+	if confirmed {
+		duration = job.duration()
+	}
+
+	disk.metrics.UpdateJobConfirmation(dirDef.Alias, fileDef.Alias, group, job.Unconfirmed, duration)
+}
+
+func findFileDefinition(definition *backup.Definition, dirName string, fileName string) (*backup.Directory, *backup.FileDefinition) {
+	for _, dirDef := range definition.Directories {
+		if dirDef.Alias != dirName {
+			continue
+		}
+
+		for _, fileDef := range dirDef.Files {
+			if fileDef.Alias == fileName {
+				return dirDef, fileDef
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// hasGroup Return true if the group has been observed by a scan, matches the directory pattern or is the only group of
+// a directory without variables
+func (target *heartbeatTarget) hasGroup(dirDef *backup.Directory, group string) bool {
+	if len(dirDef.Filter.Variables) == 0 && group == assembleFromTemplate(dirDef.Filter.Template, nil, nil) {
+		return true
+	}
+
+	// the first run of a new group's job reports before its file has been scanned
+	if matchesPattern(dirDef, group) {
+		return true
+	}
+
+	for iDir, candidate := range target.definition.Directories {
+		if candidate == dirDef && iDir < len(target.groups) {
+			_, exists := target.groups[iDir][group]
+			return exists
+		}
+	}
+
+	return false
+}
+
+// matchesPattern Return true if the group is the path of a directory matching the pattern, the same way as a scan would
+// assemble it
+func matchesPattern(dirDef *backup.Directory, group string) bool {
+	filter := dirDef.Filter
+	segments := strings.Split(group, "/")
+
+	if len(filter.Layers) == 0 || len(segments) != len(filter.Layers) {
+		return false
+	}
+
+	vars := make([]string, len(filter.Variables))
+	offset := 0
+
+	for level, segment := range segments {
+		count := filter.Layers[level].NumSubexp()
+
+		if offset+count > len(vars) {
+			return false
+		}
+
+		match := fuseLayer(filter.Layers[level], filter.Variables[offset:offset+count]).FindStringSubmatch(segment)
+
+		if match == nil {
+			return false
+		}
+
+		offset += copy(vars[offset:], match[1:])
+	}
+
+	return assembleFromTemplate(filter.Template, filter.Variables, vars) == group
+}
+
+// fuseLayer Return the layer expecting the placeholders of its fused variables instead of their values, as they appear
+// in the group
+func fuseLayer(layer *regexp.Regexp, variables []backup.VariableDefinition) *regexp.Regexp {
+	fused := false
+
+	for _, v := range variables {
+		fused = fused || v.Fuse
+	}
+
+	if !fused {
+		return layer
+	}
+
+	expr, err := syntax.Parse(layer.String(), syntax.Perl)
+	if err != nil {
+		return layer
+	}
+
+	replaceFused(expr, variables)
+
+	r, err := regexp.Compile(expr.String())
+	if err != nil {
+		return layer
+	}
+
+	return r
+}
+
+// replaceFused replaces the captures of fused variables by their placeholders; the captures are kept, so that the
+// submatches stay in the order of the variables
+func replaceFused(expr *syntax.Regexp, variables []backup.VariableDefinition) {
+	for i, sub := range expr.Sub {
+		if sub.Op == syntax.OpCapture && sub.Cap <= len(variables) && variables[sub.Cap-1].Fuse {
+			expr.Sub[i] = &syntax.Regexp{
+				Op:   syntax.OpCapture,
+				Cap:  sub.Cap,
+				Name: sub.Name,
+				Sub:  []*syntax.Regexp{{Op: syntax.OpLiteral, Rune: []rune(fusedValue(variables[sub.Cap-1]))}},
+			}
+			continue
+		}
+
+		replaceFused(sub, variables)
+	}
+}
+
+// confirmVanishedJobs confirms the jobs of groups without any files
+func (disk *DiskData) confirmVanishedJobs(dirDef *backup.Directory, groups FileLookup, grace time.Duration, now time.Time) {
+	type vanished struct {
+		file  string
+		group string
+	}
+
+	var jobs []vanished
+
+	jobsMutex.Lock()
+	for _, job := range disk.jobs {
+		if _, exists := groups[job.Group]; job.Directory == dirDef.Alias && !exists {
+			jobs = append(jobs, vanished{file: job.File, group: job.Group})
+		}
+	}
+	jobsMutex.Unlock()
+
+	for _, job := range jobs {
+		for _, fileDef := range dirDef.Files {
+			if fileDef.Alias == job.file {
+				disk.confirmJob(dirDef, fileDef, job.group, nil, grace, now)
+				disk.addStatus(dirDef, fileDef, job.group, nil, now)
+			}
+		}
+	}
+}
+
+// dropJobs removes the jobs of file definitions which do not exist anymore
+func (disk *DiskData) dropJobs() {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	for key, job := range disk.jobs {
+		if disk.Definition == nil {
+			delete(disk.jobs, key)
+			continue
+		}
+
+		if _, fileDef := findFileDefinition(disk.Definition, job.Directory, job.File); fileDef == nil {
+			delete(disk.jobs, key)
+		}
+	}
+}
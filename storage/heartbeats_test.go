@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/metrics"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH38_update_measuresDurationSinceStart(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+	job := &JobStatus{}
+
+	job.update(Heartbeat{Kind: HeartbeatStart, Time: now}, 0)
+	job.update(Heartbeat{Kind: HeartbeatSuccess, Time: now.Add(20 * time.Minute), Size: 1024, Log: "0123456789"}, 4)
+
+	assertion.Equal(HeartbeatSuccess, job.Status)
+	assertion.Equal(1200.0, job.DurationSeconds)
+	assertion.Equal(int64(1024), job.Size)
+	assertion.Equal("6789", job.Log)
+	assertion.False(job.failed())
+
+	job.update(Heartbeat{Kind: HeartbeatFail, Time: now.Add(time.Hour), Duration: time.Minute}, 0)
+
+	assertion.Equal(60.0, job.DurationSeconds)
+	assertion.True(job.failed())
+}
+
+func Test_GH38_confirmJob_flagsSuccessWithoutFileAfterGrace(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+	dirDef := &backup.Directory{Alias: "db"}
+	fileDef := &backup.FileDefinition{Alias: "dump"}
+	job := &JobStatus{Directory: "db", File: "dump", Group: "a", LastSuccess: now, DurationSeconds: 600}
+	disk := &DiskData{
+		Environment: "prod",
+		Name:        "heartbeats-test",
+		metrics:     metrics.NewDisk("heartbeats-test"),
+		jobs:        map[string]*JobStatus{jobKey("db", "dump", "a"): job},
+	}
+	defer disk.metrics.Drop()
+
+	previous := &TemporalFile{File: &fs.FileInfo{ModifiedAt: now.Add(-24 * time.Hour)}}
+	current := &TemporalFile{File: &fs.FileInfo{ModifiedAt: now.Add(-5 * time.Minute)}}
+
+	// still within the grace period
+	disk.confirmJob(dirDef, fileDef, "a", previous, 15*time.Minute, now.Add(10*time.Minute))
+	assertion.False(job.Unconfirmed)
+
+	disk.confirmJob(dirDef, fileDef, "a", previous, 15*time.Minute, now.Add(20*time.Minute))
+	assertion.True(job.Unconfirmed)
+
+	disk.addStatus(dirDef, fileDef, "a", previous, now.Add(20*time.Minute))
+	assertion.Equal(StatusUnconfirmed, disk.statuses[0].Status)
+
+	disk.confirmJob(dirDef, fileDef, "a", current, 15*time.Minute, now.Add(20*time.Minute))
+	assertion.False(job.Unconfirmed)
+}
+
+func Test_GH38_hasGroup_rejectsUnknownGroups(t *testing.T) {
+	assertion := assert.New(t)
+	static := &backup.Directory{Alias: "static", Filter: backup.DirectoryFilter{Template: []string{"backups/db"}}}
+	ungrouped := &backup.Directory{Alias: "ungrouped"}
+	grouped := &backup.Directory{Alias: "grouped", Filter: backup.DirectoryFilter{
+		Template:  []string{"backups/", ""},
+		Variables: []backup.VariableDefinition{{Name: "customer"}},
+	}}
+	target := &heartbeatTarget{
+		definition: &backup.Definition{Directories: []*backup.Directory{static, ungrouped, grouped}},
+		groups:     []map[string][]*GroupFiles{nil, nil, {"backups/acme": nil}},
+	}
+
+	assertion.True(target.hasGroup(static, "backups/db"))
+	assertion.False(target.hasGroup(static, "."))
+	assertion.True(target.hasGroup(ungrouped, "."))
+	assertion.True(target.hasGroup(grouped, "backups/acme"))
+	assertion.False(target.hasGroup(grouped, "backups/unknown"))
+	assertion.False(target.hasGroup(grouped, "."))
+}
+
+func Test_GH38_hasGroup_acceptsNewGroupsMatchingThePattern(t *testing.T) {
+	assertion := assert.New(t)
+	filter, _ := backup.ParsePathPattern("backups/{{customer}}/%Y")
+	dirDef := &backup.Directory{Alias: "grouped", Filter: filter}
+	target := &heartbeatTarget{
+		definition: &backup.Definition{Directories: []*backup.Directory{dirDef}},
+		groups:     []map[string][]*GroupFiles{{}},
+	}
+
+	assertion.True(target.hasGroup(dirDef, "backups/acme/2024"))
+	assertion.False(target.hasGroup(dirDef, "backups/acme/latest"))
+	assertion.False(target.hasGroup(dirDef, "backups/acme"))
+	assertion.False(target.hasGroup(dirDef, "archive/acme/2024"))
+	assertion.False(target.hasGroup(dirDef, "backups/acme/2024/extra"))
+
+	dirDef.Filter.Variables[0].Fuse = true
+	dirDef.Filter.Variables[1].Fuse = true
+
+	assertion.True(target.hasGroup(dirDef, "backups/{{customer}}/%Y"))
+	assertion.False(target.hasGroup(dirDef, "backups/acme/%Y"))
+	assertion.False(target.hasGroup(dirDef, "backups/{{customer}}/2024"))
+}
+
+func noLogLimit() uint64 {
+	return 0
+}
+
+func Test_GH38_recordHeartbeat_doesNotWaitForRunningScans(t *testing.T) {
+	assertion := assert.New(t)
+	dirDef := &backup.Directory{Alias: "db", Filter: backup.DirectoryFilter{Template: []string{"."}}, Files: []*backup.FileDefinition{{Alias: "dump"}}}
+	disk := &DiskData{
+		Environment: "prod",
+		Name:        "heartbeats-test",
+		metrics:     metrics.NewDisk("heartbeats-test"),
+		Definition:  &backup.Definition{Directories: []*backup.Directory{dirDef}},
+	}
+	defer disk.metrics.Drop()
+
+	previousClients, previousTargets := clients, heartbeatTargets
+	t.Cleanup(func() { clients, heartbeatTargets = previousClients, previousTargets })
+	clients = map[string]*clientData{"prod": {Disks: map[string]*DiskData{disk.Name: disk}}}
+	publishHeartbeatTargets()
+
+	// a scan is running
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	recorded := make(chan error, 1)
+
+	go func() {
+		_, err := recordHeartbeat("heartbeats-test", "db", "dump", ".", Heartbeat{Kind: HeartbeatStart, Time: time.Now()}, noLogLimit)
+		recorded <- err
+	}()
+
+	select {
+	case err := <-recorded:
+		assertion.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat has waited for the scan")
+	}
+
+	jobs := GetJobs()
+	assertion.Len(jobs, 1)
+	assertion.Equal(HeartbeatStart, jobs[0].Status)
+
+	_, err := recordHeartbeat("heartbeats-test", "db", "unknown", ".", Heartbeat{Kind: HeartbeatStart, Time: time.Now()}, noLogLimit)
+	assertion.ErrorIs(err, ErrUnknownDefinition)
+}
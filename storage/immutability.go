@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"path"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	fs "github.com/dreitier/backmon/storage/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// satisfiesImmutability Return true if the lock status of the file fulfills the requirements of the file definition
+func satisfiesImmutability(fileDef *backup.FileDefinition, file *fs.FileInfo, status *fs.LockStatus, now time.Time) bool {
+	if fileDef.ImmutableMode != "" && (status.Mode != fileDef.ImmutableMode || !status.RetainUntil.After(now)) {
+		return false
+	}
+
+	if fileDef.ImmutableRetention > 0 && status.RetainUntil.Before(file.ModifiedAt.Add(fileDef.ImmutableRetention)) {
+		return false
+	}
+
+	if fileDef.ImmutableLegalHold && !status.LegalHold {
+		return false
+	}
+
+	return true
+}
+
+// updateImmutability checks the lock status of the group's latest file, if the file definition requires it
+func updateImmutability(client Client, disk *DiskData, dirDef *backup.Directory, fileDef *backup.FileDefinition, group string, latest *fs.FileInfo, now time.Time) {
+	if !fileDef.RequiresImmutability() {
+		return
+	}
+
+	status, err := disk.lockStatus(client, latest, !fileDef.ImmutableLegalHold, now)
+
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			log.Warnf("File definition %#q requires immutability, but disk %#q does not support locking files", fileDef.Alias, disk.Name)
+		} else {
+			log.Errorf("Could not check immutability of '%s' in disk %#q: %s", latest.Name, disk.Name, err)
+		}
+
+		disk.metrics.UpdateImmutability(dirDef.Alias, fileDef.Alias, group, false, time.Time{})
+		return
+	}
+
+	immutable := satisfiesImmutability(fileDef, latest, status, now)
+
+	if !immutable {
+		log.Warnf("Latest file '%s' in disk %#q does not satisfy the required immutability (mode: %#q, retain until: %s, legal hold: %t)",
+			latest.Name, disk.Name, status.Mode, status.RetainUntil.Format(time.RFC3339), status.LegalHold)
+	}
+
+	disk.metrics.UpdateImmutability(dirDef.Alias, fileDef.Alias, group, immutable, status.RetainUntil)
+}
+
+// isLocked Return true if the file cannot be purged
+func (disk *DiskData) isLocked(client Client, file *fs.FileInfo, now time.Time) (bool, *fs.LockStatus) {
+	status, err := disk.lockStatus(client, file, true, now)
+
+	if err != nil {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			log.Warnf("Could not determine lock status of '%s': %s", file.Name, err)
+		}
+
+		return false, nil
+	}
+
+	return status.IsLocked(now), status
+}
+
+// lockStatus Return the lock status of the file. Retention locks are cached until they expire; legal holds can be
+// released at any time, so that a cached status is only used if the legal hold is irrelevant.
+func (disk *DiskData) lockStatus(client Client, file *fs.FileInfo, ignoreLegalHold bool, now time.Time) (*fs.LockStatus, error) {
+	key := path.Join(file.Parent, file.Name)
+
+	if status := disk.locks.get(key); status != nil && ignoreLegalHold && status.RetainUntil.After(now) {
+		return status, nil
+	}
+
+	status, err := client.GetLockStatus(disk.Name, file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if status.RetainUntil.After(now) {
+		disk.locks.put(key, status)
+	}
+
+	return status, nil
+}
+
+// lockCache the retention locks of the files checked during the previous and the current scan
+type lockCache struct {
+	previous map[string]*fs.LockStatus
+	current  map[string]*fs.LockStatus
+}
+
+// rotate starts a new scan; locks of files which are not checked again during the scan are evicted
+func (c *lockCache) rotate() {
+	c.previous, c.current = c.current, nil
+}
+
+func (c *lockCache) get(key string) *fs.LockStatus {
+	if status, cached := c.current[key]; cached {
+		return status
+	}
+
+	status, cached := c.previous[key]
+
+	if cached {
+		c.put(key, status)
+	}
+
+	return status
+}
+
+func (c *lockCache) put(key string, status *fs.LockStatus) {
+	if c.current == nil {
+		c.current = make(map[string]*fs.LockStatus)
+	}
+
+	c.current[key] = status
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH30_satisfiesImmutability(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	file := &fs.FileInfo{Name: "dump.sql", ModifiedAt: now.Add(-time.Hour)}
+	fileDef := &backup.FileDefinition{ImmutableMode: "COMPLIANCE", ImmutableRetention: 30 * 24 * time.Hour}
+
+	assertion.True(satisfiesImmutability(fileDef, file, &fs.LockStatus{Mode: "COMPLIANCE", RetainUntil: now.AddDate(0, 0, 30)}, now))
+	// governance retention can be bypassed
+	assertion.False(satisfiesImmutability(fileDef, file, &fs.LockStatus{Mode: "GOVERNANCE", RetainUntil: now.AddDate(0, 0, 30)}, now))
+	// retained for less than 30 days
+	assertion.False(satisfiesImmutability(fileDef, file, &fs.LockStatus{Mode: "COMPLIANCE", RetainUntil: now.AddDate(0, 0, 7)}, now))
+	assertion.False(satisfiesImmutability(fileDef, file, &fs.LockStatus{}, now))
+
+	legalHold := &backup.FileDefinition{ImmutableLegalHold: true}
+	assertion.True(satisfiesImmutability(legalHold, file, &fs.LockStatus{LegalHold: true}, now))
+	assertion.False(satisfiesImmutability(legalHold, file, &fs.LockStatus{Mode: "COMPLIANCE", RetainUntil: now.AddDate(1, 0, 0)}, now))
+}
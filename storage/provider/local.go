@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 type LocalClient struct {
@@ -99,7 +100,7 @@ func (c *LocalClient) Download(disk string, file *fs.FileInfo) (bytes io.ReadClo
 	if disk != c.Directory {
 		return nil, -1, "", errors.New(fmt.Sprintf("disk %#q does not exist", disk))
 	}
-	fileName := filepath.Join(disk, file.Parent, file.Name)
+	fileName := c.pathOf(disk, file)
 
 	fileInfo, err := os.Stat(fileName)
 
@@ -118,11 +119,25 @@ func (c *LocalClient) Download(disk string, file *fs.FileInfo) (bytes io.ReadClo
 	return bytes, length, "", nil
 }
 
+// Open returns the opened file; see #46
+func (c *LocalClient) Open(disk string, file *fs.FileInfo) (io.ReadSeekCloser, error) {
+	if disk != c.Directory {
+		return nil, fmt.Errorf("disk %#q does not exist", disk)
+	}
+
+	return os.Open(c.pathOf(disk, file))
+}
+
+// PresignedURL is not supported, local files can only be downloaded through backmon
+func (c *LocalClient) PresignedURL(disk string, file *fs.FileInfo, attachmentName string, expiry time.Duration) (string, error) {
+	return "", errors.ErrUnsupported
+}
+
 func (c *LocalClient) Delete(disk string, file *fs.FileInfo) error {
 	if disk != c.Directory {
 		return fmt.Errorf("disk %#q does not exist", disk)
 	}
-	filePath := filepath.Join(disk, file.Parent, file.Name)
+	filePath := c.pathOf(disk, file)
 
 	err := os.Remove(filePath)
 
@@ -138,6 +153,101 @@ func (c *LocalClient) Delete(disk string, file *fs.FileInfo) error {
 	return err
 }
 
+// Move moves the file and its .stat file below targetPath. A relative targetPath is resolved against targetDisk, or
+// the file's own disk if targetDisk is empty. The file's path relative to its disk is kept.
+func (c *LocalClient) Move(disk string, file *fs.FileInfo, targetDisk string, targetPath string) error {
+	if disk != c.Directory {
+		return fmt.Errorf("disk %#q does not exist", disk)
+	}
+
+	if targetDisk == "" {
+		targetDisk = disk
+	}
+
+	if !filepath.IsAbs(targetPath) {
+		targetPath = filepath.Join(targetDisk, targetPath)
+	}
+
+	sourcePath := c.pathOf(disk, file)
+	relativePath, err := filepath.Rel(disk, sourcePath)
+
+	if err != nil {
+		return fmt.Errorf("failed to resolve path of %s relative to disk %s: %s", sourcePath, disk, err)
+	}
+
+	destinationPath := filepath.Join(targetPath, relativePath)
+
+	if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+		return fmt.Errorf("failed to create target directory: %s", err)
+	}
+
+	if err := moveFile(sourcePath, destinationPath); err != nil {
+		return err
+	}
+
+	// move a belonging .stat file if it is existent
+	possibleDotStatFilePath := dotstat.ToDotStatPath(sourcePath)
+	dotStatExists, _ := fs.IsFilePathValid(possibleDotStatFilePath)
+
+	if dotStatExists {
+		if err := moveFile(possibleDotStatFilePath, dotstat.ToDotStatPath(destinationPath)); err != nil {
+			log.Warnf("Could not move .stat file %s: %s", possibleDotStatFilePath, err)
+		}
+	}
+
+	return nil
+}
+
+// moveFile renames the file; if that fails (e.g. because the target is on another device), the file is copied and removed
+func moveFile(sourcePath string, destinationPath string) error {
+	if err := os.Rename(sourcePath, destinationPath); err == nil {
+		return nil
+	}
+
+	source, err := os.Open(sourcePath)
+
+	if err != nil {
+		return fmt.Errorf("failed to open file for reading: %s", err)
+	}
+
+	defer func(source *os.File) {
+		_ = source.Close()
+	}(source)
+
+	destination, err := os.OpenFile(destinationPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %s", destinationPath, err)
+	}
+
+	_, err = io.Copy(destination, source)
+
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(destinationPath)
+		return fmt.Errorf("failed to copy file to %s: %s", destinationPath, err)
+	}
+
+	return os.Remove(sourcePath)
+}
+
+// GetLockStatus local files cannot be locked
+func (c *LocalClient) GetLockStatus(disk string, file *fs.FileInfo) (*fs.LockStatus, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// pathOf returns the path of the file. Scanned files already contain the disk directory in their parent path.
+func (c *LocalClient) pathOf(disk string, file *fs.FileInfo) string {
+	if relativeParent, err := filepath.Rel(disk, file.Parent); err == nil && !strings.HasPrefix(relativeParent, "..") {
+		return filepath.Join(disk, relativeParent, file.Name)
+	}
+
+	return filepath.Join(disk, file.Parent, file.Name)
+}
+
 func (c *LocalClient) findDisk(diskName *string) (*string, error) {
 	names, err := c.GetDiskNames()
 
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	//	"github.com/dreitier/backmon/storage/fs"
 )
@@ -21,3 +22,40 @@ func TestLocalClient_GetDiskNames(t *testing.T) {
 	}
 
 }
+
+func TestLocalClient_Move(t *testing.T) {
+	directory := t.TempDir()
+	c := LocalClient{EnvName: "test", Directory: directory}
+
+	if err := os.MkdirAll(filepath.Join(directory, "backups"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dump.sql", "dump.sql.stat"} {
+		if err := os.WriteFile(filepath.Join(directory, "backups", name), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root, err := c.GetFileNames(directory, 1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := root.SubDirs["backups"].Files[0]
+
+	if err := c.Move(directory, file, "", "archive"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dump.sql", "dump.sql.stat"} {
+		if _, err := os.Stat(filepath.Join(directory, "archive", "backups", name)); err != nil {
+			t.Errorf("%s has not been moved: %s", name, err)
+		}
+
+		if _, err := os.Stat(filepath.Join(directory, "backups", name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", name)
+		}
+	}
+}
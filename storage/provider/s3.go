@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -17,6 +19,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/logging"
 
 	cfg "github.com/dreitier/backmon/config"
@@ -253,12 +256,18 @@ func (c *S3Client) appendFilesTo(diskName *string, root *fs.DirectoryInfo, objec
 		}
 
 		file := &fs.FileInfo{
-			Name:       fileName,
-			Parent:     parentPath,
-			BornAt:     *obj.LastModified,
-			ModifiedAt: *obj.LastModified,
-			ArchivedAt: *obj.LastModified,
-			Size:       *obj.Size,
+			Name:         fileName,
+			Parent:       parentPath,
+			BornAt:       *obj.LastModified,
+			ModifiedAt:   *obj.LastModified,
+			ArchivedAt:   *obj.LastModified,
+			Size:         *obj.Size,
+			StorageClass: string(obj.StorageClass),
+		}
+
+		// ETags of multipart uploads are not the MD5 checksum of the object
+		if obj.ETag != nil && !strings.Contains(*obj.ETag, "-") {
+			file.Checksum = strings.Trim(*obj.ETag, "\"")
 		}
 
 		currentDir.Files = append(currentDir.Files, file)
@@ -351,15 +360,18 @@ func (c *S3Client) hasAccessToBucket(client *s3.Client, bucketName *string) bool
 	return true
 }
 
-func (c *S3Client) Download(disk string, file *fs.FileInfo) (bytes io.ReadCloser, length int64, contentType string, err error) {
-	var fullName string
-
+// objectKey returns the key of the file inside its bucket
+func objectKey(file *fs.FileInfo) string {
 	if file.Parent == "" {
-		fullName = file.Name
-	} else {
-		fullName = strings.TrimSuffix(file.Parent, "/") + "/" + file.Name
+		return file.Name
 	}
 
+	return strings.TrimSuffix(file.Parent, "/") + "/" + file.Name
+}
+
+func (c *S3Client) Download(disk string, file *fs.FileInfo) (bytes io.ReadCloser, length int64, contentType string, err error) {
+	fullName := objectKey(file)
+
 	out, err := c.get(&disk, &fullName)
 
 	if err != nil {
@@ -372,6 +384,152 @@ func (c *S3Client) Download(disk string, file *fs.FileInfo) (bytes io.ReadCloser
 	return out.Body, length, contentType, nil
 }
 
+// Open returns a reader of the object. The current size and ETag of the object are requested first; a missing object is
+// reported as os.ErrNotExist. The content is only requested when reading, starting at the current offset through
+// ranged GetObject requests, so that seeking to the requested range of an HTTP range request does not download the
+// whole object; see #46
+func (c *S3Client) Open(disk string, file *fs.FileInfo) (io.ReadSeekCloser, error) {
+	client, err := getClient(c)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not acquire S3 client instance: %s", err)
+	}
+
+	key := objectKey(file)
+	head, err := client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: &disk, Key: &key})
+
+	if err != nil {
+		if isMissingObject(err) {
+			return nil, fmt.Errorf("object %s does not exist in disk %s: %w", key, disk, os.ErrNotExist)
+		}
+
+		return nil, fmt.Errorf("failed to get object %s from disk %s: %s", key, disk, err)
+	}
+
+	return &objectReader{client: c, bucket: disk, key: key, size: aws.ToInt64(head.ContentLength), etag: head.ETag, chunkSize: objectChunkSize}, nil
+}
+
+// PresignedURL returns a presigned URL of GetObject; see #47
+func (c *S3Client) PresignedURL(disk string, file *fs.FileInfo, attachmentName string, expiry time.Duration) (string, error) {
+	client, err := getClient(c)
+
+	if err != nil {
+		return "", fmt.Errorf("could not acquire S3 client instance: %s", err)
+	}
+
+	key := objectKey(file)
+	disposition := "attachment; filename=\"" + attachmentName + "\""
+	input := &s3.GetObjectInput{Bucket: &disk, Key: &key, ResponseContentDisposition: &disposition}
+
+	req, err := s3.NewPresignClient(client).PresignGetObject(context.Background(), input, s3.WithPresignExpires(expiry))
+
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object %s of disk %s: %s", key, disk, err)
+	}
+
+	return req.URL, nil
+}
+
+// objectChunkSize maximum amount of bytes requested by a single GetObject request of an objectReader
+const objectChunkSize = 64 * 1024 * 1024
+
+type objectReader struct {
+	client *S3Client
+	bucket string
+	key    string
+	size   int64
+	// all chunks must belong to the same version of the object
+	etag *string
+	// maximum size of a chunk
+	chunkSize int64
+	offset    int64
+	// body of the pending GetObject request and the offset at which it ends; nil until the next read
+	body io.ReadCloser
+	end  int64
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body != nil && r.offset >= r.end {
+		_ = r.Close()
+	}
+
+	if r.body == nil {
+		client, err := getClient(r.client)
+
+		if err != nil {
+			return 0, fmt.Errorf("could not acquire S3 client instance: %s", err)
+		}
+
+		r.end = min(r.offset+r.chunkSize, r.size)
+		out, err := client.GetObject(context.Background(), &s3.GetObjectInput{
+			Bucket:  &r.bucket,
+			Key:     &r.key,
+			Range:   aws.String(fmt.Sprintf("bytes=%d-%d", r.offset, r.end-1)),
+			IfMatch: r.etag,
+		})
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to download object %s from disk %s: %s", r.key, r.bucket, err)
+		}
+
+		r.body = out.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+
+	// the end of a chunk is not the end of the object
+	if err == io.EOF && r.offset < r.size {
+		if r.offset < r.end {
+			return n, io.ErrUnexpectedEOF
+		}
+
+		err = nil
+	}
+
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	target := offset
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		target += r.offset
+	case io.SeekEnd:
+		target += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if target < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if target != r.offset {
+		_ = r.Close()
+		r.offset = target
+	}
+
+	return target, nil
+}
+
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	err := r.body.Close()
+	r.body = nil
+
+	return err
+}
+
 func (c *S3Client) Delete(disk string, file *fs.FileInfo) error {
 	//TODO: check out the s3 delete object documentation to make this work with versioned files
 	client, err := getClient(c)
@@ -379,7 +537,7 @@ func (c *S3Client) Delete(disk string, file *fs.FileInfo) error {
 	if err != nil {
 		return fmt.Errorf("could not acquire S3 client instance: %s", err)
 	}
-	fullName := file.Parent + "/" + file.Name
+	fullName := objectKey(file)
 	delObjectInput := s3.DeleteObjectInput{Bucket: &disk, Key: &fullName}
 	out, err := client.DeleteObject(context.Background(), &delObjectInput)
 	_ = fmt.Sprint(out)
@@ -390,3 +548,191 @@ func (c *S3Client) Delete(disk string, file *fs.FileInfo) error {
 
 	return nil
 }
+
+// Move copies the object (and its .stat object, if existent) server-side below targetPath in targetDisk and deletes the
+// original afterward. If targetDisk is empty, the object stays in its bucket. The object's key is kept as suffix.
+func (c *S3Client) Move(disk string, file *fs.FileInfo, targetDisk string, targetPath string) error {
+	client, err := getClient(c)
+
+	if err != nil {
+		return fmt.Errorf("could not acquire S3 client instance: %s", err)
+	}
+
+	if targetDisk == "" {
+		targetDisk = disk
+	}
+
+	sourceKey := objectKey(file)
+	targetKey := strings.Trim(targetPath, "/") + "/" + sourceKey
+
+	if err := c.copyObject(client, disk, sourceKey, file.Size, targetDisk, targetKey); err != nil {
+		return fmt.Errorf("failed to copy object %s from disk %s to %s in disk %s: %s", sourceKey, disk, targetKey, targetDisk, err)
+	}
+
+	// the .stat object is optional; a failing copy most likely means that there is none
+	sourceStatKey := dotstat.ToDotStatPath(sourceKey)
+
+	if err := c.copyObject(client, disk, sourceStatKey, 0, targetDisk, dotstat.ToDotStatPath(targetKey)); err == nil {
+		_, err = client.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: &disk, Key: &sourceStatKey})
+
+		if err != nil {
+			log.Warnf("Could not delete .stat object %s from disk %s: %s", sourceStatKey, disk, err)
+		}
+	}
+
+	return c.Delete(disk, file)
+}
+
+// maxCopySize objects larger than this cannot be copied by a single CopyObject request
+const maxCopySize = 5 * 1024 * 1024 * 1024
+
+// copyPartSize size of the parts of a multipart copy; allows objects up to 5 TiB within the limit of 10,000 parts
+const copyPartSize = 512 * 1024 * 1024
+
+// copyObject copies the object of the size server-side; objects above 5 GiB are copied as multipart upload
+func (c *S3Client) copyObject(client *s3.Client, disk string, key string, size int64, targetDisk string, targetKey string) error {
+	copySource := aws.String(url.PathEscape(disk) + "/" + escapeKey(key))
+
+	if size <= maxCopySize {
+		_, err := client.CopyObject(context.Background(), &s3.CopyObjectInput{
+			Bucket:     &targetDisk,
+			Key:        &targetKey,
+			CopySource: copySource,
+		})
+
+		return err
+	}
+
+	upload, err := client.CreateMultipartUpload(context.Background(), &s3.CreateMultipartUploadInput{Bucket: &targetDisk, Key: &targetKey})
+
+	if err != nil {
+		return err
+	}
+
+	var parts []types.CompletedPart
+
+	for offset := int64(0); offset < size; offset += copyPartSize {
+		end := min(offset+copyPartSize, size) - 1
+		partNumber := aws.Int32(int32(len(parts) + 1))
+
+		part, err := client.UploadPartCopy(context.Background(), &s3.UploadPartCopyInput{
+			Bucket:          &targetDisk,
+			Key:             &targetKey,
+			UploadId:        upload.UploadId,
+			PartNumber:      partNumber,
+			CopySource:      copySource,
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+		})
+
+		if err != nil {
+			c.abortMultipartUpload(client, targetDisk, targetKey, upload.UploadId)
+			return fmt.Errorf("failed to copy part %d: %s", *partNumber, err)
+		}
+
+		parts = append(parts, types.CompletedPart{ETag: part.CopyPartResult.ETag, PartNumber: partNumber})
+	}
+
+	_, err = client.CompleteMultipartUpload(context.Background(), &s3.CompleteMultipartUploadInput{
+		Bucket:          &targetDisk,
+		Key:             &targetKey,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+
+	if err != nil {
+		c.abortMultipartUpload(client, targetDisk, targetKey, upload.UploadId)
+	}
+
+	return err
+}
+
+// abortMultipartUpload removes the parts of a failed multipart copy
+func (c *S3Client) abortMultipartUpload(client *s3.Client, disk string, key string, uploadId *string) {
+	_, err := client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{Bucket: &disk, Key: &key, UploadId: uploadId})
+
+	if err != nil {
+		log.Warnf("Could not abort multipart upload of object %s in disk %s: %s", key, disk, err)
+	}
+}
+
+// escapeKey URL-encodes each segment of the object key
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// GetLockStatus retrieves the Object Lock retention and legal hold of the object. Objects in buckets without Object Lock
+// configuration are reported as unlocked.
+func (c *S3Client) GetLockStatus(disk string, file *fs.FileInfo) (*fs.LockStatus, error) {
+	client, err := getClient(c)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not acquire S3 client instance: %s", err)
+	}
+
+	key := objectKey(file)
+	status := &fs.LockStatus{}
+
+	retention, err := client.GetObjectRetention(context.Background(), &s3.GetObjectRetentionInput{Bucket: &disk, Key: &key})
+
+	if err != nil && !isMissingObjectLock(err) {
+		return nil, fmt.Errorf("failed to get retention of object %s in disk %s: %s", key, disk, err)
+	}
+
+	if err == nil && retention.Retention != nil {
+		status.Mode = string(retention.Retention.Mode)
+
+		if retention.Retention.RetainUntilDate != nil {
+			status.RetainUntil = *retention.Retention.RetainUntilDate
+		}
+	}
+
+	legalHold, err := client.GetObjectLegalHold(context.Background(), &s3.GetObjectLegalHoldInput{Bucket: &disk, Key: &key})
+
+	if err != nil && !isMissingObjectLock(err) {
+		return nil, fmt.Errorf("failed to get legal hold of object %s in disk %s: %s", key, disk, err)
+	}
+
+	if err == nil && legalHold.LegalHold != nil {
+		status.LegalHold = legalHold.LegalHold.Status == types.ObjectLockLegalHoldStatusOn
+	}
+
+	return status, nil
+}
+
+// isMissingObject Return true if the error indicates that the object does not exist
+func isMissingObject(err error) bool {
+	var apiErr smithy.APIError
+
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "NotFound", "NoSuchKey":
+		return true
+	}
+
+	return false
+}
+
+// isMissingObjectLock Return true if the error indicates that there is no Object Lock configuration for the bucket or object
+func isMissingObjectLock(err error) bool {
+	var apiErr smithy.APIError
+
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.ErrorCode() {
+	case "NoSuchObjectLockConfiguration", "ObjectLockConfigurationNotFoundError", "InvalidRequest":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH46_S3Client_Open_forwardsRangeAfterSeeking(t *testing.T) {
+	assertion := assert.New(t)
+	content := "0123456789"
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket/db/missing.sql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		assertion.Equal("/bucket/db/dump.sql", r.URL.Path)
+		w.Header().Set("ETag", `"v1"`)
+
+		// the size may differ from the one of the latest scan
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+
+		assertion.Equal(`"v1"`, r.Header.Get("If-Match"))
+		ranges = append(ranges, r.Header.Get("Range"))
+
+		var start, end int
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Length", strconv.Itoa(end+1-start))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, content[start:end+1])
+	}))
+	defer server.Close()
+
+	c := &S3Client{Region: "eu-central-1", AccessKey: "key", SecretKey: "secret", Endpoint: server.URL, ForcePathStyle: true}
+	sut, err := c.Open("bucket", &fs.FileInfo{Parent: "db", Name: "dump.sql", Size: 4})
+	assertion.NoError(err)
+	defer func() { _ = sut.Close() }()
+
+	// seeking alone does not request the object
+	size, _ := sut.Seek(0, io.SeekEnd)
+	assertion.Equal(int64(len(content)), size)
+	_, _ = sut.Seek(6, io.SeekStart)
+	assertion.Empty(ranges)
+
+	data, err := io.ReadAll(sut)
+	assertion.NoError(err)
+	assertion.Equal("6789", string(data))
+
+	// the object is requested in chunks
+	sut.(*objectReader).chunkSize = 2
+	_, _ = sut.Seek(2, io.SeekStart)
+	part := make([]byte, 3)
+	_, err = io.ReadFull(sut, part)
+	assertion.NoError(err)
+	assertion.Equal("234", string(part))
+
+	assertion.Equal([]string{"bytes=6-9", "bytes=2-3", "bytes=4-5"}, ranges)
+
+	_, err = c.Open("bucket", &fs.FileInfo{Parent: "db", Name: "missing.sql"})
+	assertion.ErrorIs(err, os.ErrNotExist)
+}
+
+func Test_GH47_S3Client_PresignedURL_signsGetObjectWithAttachment(t *testing.T) {
+	assertion := assert.New(t)
+	c := &S3Client{Region: "eu-central-1", AccessKey: "key", SecretKey: "secret", Endpoint: "https://s3.example.com", ForcePathStyle: true}
+
+	location, err := c.PresignedURL("bucket", &fs.FileInfo{Parent: "db", Name: "dump.sql"}, "dump.sql", 15*time.Minute)
+	assertion.NoError(err)
+
+	parsed, err := url.Parse(location)
+	assertion.NoError(err)
+	assertion.Equal("s3.example.com", parsed.Host)
+	assertion.Equal("/bucket/db/dump.sql", parsed.Path)
+	assertion.Equal("900", parsed.Query().Get("X-Amz-Expires"))
+	assertion.Equal(`attachment; filename="dump.sql"`, parsed.Query().Get("response-content-disposition"))
+	assertion.NotEmpty(parsed.Query().Get("X-Amz-Signature"))
+}
+
+func Test_GH47_LocalClient_PresignedURL_isUnsupported(t *testing.T) {
+	c := &LocalClient{Directory: t.TempDir()}
+
+	_, err := c.PresignedURL(c.Directory, &fs.FileInfo{Name: "dump.sql"}, "dump.sql", time.Minute)
+
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func Test_GH29_S3Client_Move_copiesLargeObjectsInParts(t *testing.T) {
+	assertion := assert.New(t)
+	var ranges []string
+	var completed, deleted bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		switch {
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			assertion.Equal("/bucket/archive/db/dump.sql", r.URL.Path)
+			_, _ = io.WriteString(w, `<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPut && query.Has("partNumber"):
+			assertion.Equal("upload-1", query.Get("uploadId"))
+			assertion.Equal("bucket/db/dump.sql", strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+			ranges = append(ranges, r.Header.Get("X-Amz-Copy-Source-Range"))
+			_, _ = io.WriteString(w, `<CopyPartResult><ETag>"part-`+query.Get("partNumber")+`"</ETag></CopyPartResult>`)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			body, _ := io.ReadAll(r.Body)
+			assertion.Contains(string(body), `<PartNumber>11</PartNumber>`)
+			completed = true
+			_, _ = io.WriteString(w, `<CompleteMultipartUploadResult><ETag>"complete"</ETag></CompleteMultipartUploadResult>`)
+		case r.Method == http.MethodPut:
+			// there is no .stat object
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+		case r.Method == http.MethodDelete:
+			assertion.Equal("/bucket/db/dump.sql", r.URL.Path)
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer server.Close()
+
+	c := &S3Client{Region: "eu-central-1", AccessKey: "key", SecretKey: "secret", Endpoint: server.URL, ForcePathStyle: true}
+	err := c.Move("bucket", &fs.FileInfo{Parent: "db", Name: "dump.sql", Size: maxCopySize + 1}, "", "archive")
+
+	assertion.NoError(err)
+	assertion.Len(ranges, 11)
+	assertion.Equal("bytes=0-536870911", ranges[0])
+	assertion.Equal("bytes=5368709120-5368709120", ranges[10])
+	assertion.True(completed)
+	assertion.True(deleted)
+}
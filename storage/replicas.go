@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/metrics"
+	fs "github.com/dreitier/backmon/storage/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+// replicaEndpoint is the resolved side of a replica rule
+type replicaEndpoint struct {
+	client Client
+	disk   *DiskData
+	// files of each group; the index of the file definition is file
+	groups map[string][]*GroupFiles
+	file   int
+}
+
+type cachedChecksum struct {
+	size       int64
+	modifiedAt time.Time
+	checksum   string
+}
+
+// checksums of files without a checksum in their listing, computed during the current and the previous evaluation;
+// computing them requires downloading the whole file
+var checksums, previousChecksums map[string]*cachedChecksum
+
+// groups with metrics of each replica rule
+var replicaGroups = make(map[string]map[string]bool)
+
+// evaluateReplicas checks for each replica rule, that the files of each group in the source have been replicated. Source
+// files older than the oldest replica of the group are not expected to be replicated anymore, as the retention of the
+// replica may be shorter. It must be called after all environments have been updated.
+func evaluateReplicas(rules []*config.ReplicaConfiguration, now time.Time) {
+	replicaMetrics := metrics.GetReplicaMetrics()
+	previousChecksums, checksums = checksums, make(map[string]*cachedChecksum)
+
+	for _, rule := range rules {
+		source, err := resolveReplicaEndpoint(rule.Source)
+		if err != nil {
+			log.Warnf("[replica:%s] Skipping check, source is not available: %s", rule.Name, err)
+			replicaMetrics.Drop(rule.Name)
+			delete(replicaGroups, rule.Name)
+			continue
+		}
+
+		replica, err := resolveReplicaEndpoint(rule.Replica)
+		if err != nil {
+			// all replicas are considered missing
+			log.Warnf("[replica:%s] Replica is not available: %s", rule.Name, err)
+		}
+
+		groups := make(map[string]bool)
+
+		for group, files := range source.groups {
+			if files[source.file].latest() == nil {
+				continue
+			}
+
+			var replicaFiles FileGroup
+			if replica != nil {
+				if files, exists := replica.groups[group]; exists && files[replica.file] != nil {
+					replicaFiles = files[replica.file].Files
+				}
+			}
+
+			lag, missing := evaluateGroup(rule, group, source, files[source.file].Files, replica, replicaFiles, now)
+			replicaMetrics.Update(rule.Name, group, lag.Seconds(), missing)
+			groups[group] = true
+		}
+
+		for group := range replicaGroups[rule.Name] {
+			if !groups[group] {
+				replicaMetrics.DropGroup(rule.Name, group)
+			}
+		}
+
+		replicaGroups[rule.Name] = groups
+	}
+}
+
+// evaluateGroup Return the lag of the group and whether a replica is missing. The lag is the one of the oldest source
+// file which has not been replicated; if all files have been replicated, the lag of the latest file.
+func evaluateGroup(rule *config.ReplicaConfiguration, group string, source *replicaEndpoint, sourceFiles FileGroup, replica *replicaEndpoint, replicaFiles FileGroup, now time.Time) (lag time.Duration, missing bool) {
+	var oldestReplica time.Time
+
+	for _, replicaFile := range replicaFiles {
+		if oldestReplica.IsZero() || replicaFile.File.ModifiedAt.Before(oldestReplica) {
+			oldestReplica = replicaFile.File.ModifiedAt
+		}
+	}
+
+	// sorted from newest to oldest
+	for i, sourceFile := range sourceFiles {
+		if i > 0 && !oldestReplica.IsZero() && sourceFile.File.ModifiedAt.Before(oldestReplica) {
+			break
+		}
+
+		replicaFile := findReplica(rule, sourceFile.File, replicaFiles)
+		replicated := replicaFile != nil
+
+		if replicated && rule.MatchChecksum {
+			var err error
+			replicated, err = checksumsMatch(rule, source, sourceFile.File, replica, replicaFile)
+			if err != nil {
+				log.Warnf("[replica:%s][group:%s] Could not compare checksums: %s", rule.Name, group, err)
+			}
+		}
+
+		fileLag, fileMissing := replicaLag(rule, sourceFile.File, replicaFile, replicated, now)
+
+		if fileMissing {
+			log.Warnf("[replica:%s][group:%s] No replica of '%s' found in disk '%s' after %s", rule.Name, group, sourceFile.File.Name, rule.Replica.Disk, fileLag.Round(time.Second))
+		}
+
+		if i == 0 || !replicated {
+			lag = fileLag
+		}
+
+		missing = missing || fileMissing
+	}
+
+	return lag, missing
+}
+
+// findReplica Return the replica of the source file or nil
+func findReplica(rule *config.ReplicaConfiguration, source *fs.FileInfo, replicaFiles FileGroup) *fs.FileInfo {
+	for _, replicaFile := range replicaFiles {
+		if isReplicaOf(rule, source, replicaFile.File) {
+			return replicaFile.File
+		}
+	}
+
+	return nil
+}
+
+func resolveReplicaEndpoint(endpoint *config.ReplicaEndpointConfiguration) (*replicaEndpoint, error) {
+	cd, exists := clients[endpoint.Environment]
+	if !exists {
+		return nil, fmt.Errorf("unknown environment '%s'", endpoint.Environment)
+	}
+
+	disk, exists := cd.Disks[endpoint.Disk]
+	if !exists {
+		return nil, fmt.Errorf("unknown disk '%s' in environment '%s'", endpoint.Disk, endpoint.Environment)
+	}
+
+	if disk.Definition == nil {
+		return nil, fmt.Errorf("disk '%s' has no backup definitions", endpoint.Disk)
+	}
+
+	for iDir, dirDef := range disk.Definition.Directories {
+		if dirDef.Alias != endpoint.Directory {
+			continue
+		}
+
+		for iFile, fileDef := range dirDef.Files {
+			if fileDef.Alias == endpoint.File {
+				return &replicaEndpoint{
+					client: cd.Client,
+					disk:   disk,
+					groups: disk.files[iDir],
+					file:   iFile,
+				}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unknown file '%s' in directory '%s' of disk '%s'", endpoint.File, endpoint.Directory, endpoint.Disk)
+}
+
+// isReplicaOf Return true if the replica has the same name or the same interpolated timestamp as the source and,
+// if required, the same size
+func isReplicaOf(rule *config.ReplicaConfiguration, source *fs.FileInfo, replica *fs.FileInfo) bool {
+	sameName := source.Name == replica.Name
+	sameTimestamp := source.InterpolatedTimestamp != nil && replica.InterpolatedTimestamp != nil &&
+		source.InterpolatedTimestamp.Equal(*replica.InterpolatedTimestamp)
+
+	if !sameName && !sameTimestamp {
+		return false
+	}
+
+	return !rule.MatchSize || source.Size == replica.Size
+}
+
+// replicaLag returns how long the replication took or, if the file has not been replicated yet, since when the
+// replica is pending. The replica is missing if it is pending for longer than the maximum lag.
+func replicaLag(rule *config.ReplicaConfiguration, source *fs.FileInfo, replica *fs.FileInfo, replicated bool, now time.Time) (lag time.Duration, missing bool) {
+	if replicated {
+		lag = replica.ModifiedAt.Sub(source.ModifiedAt)
+
+		if lag < 0 {
+			lag = 0
+		}
+
+		return lag, false
+	}
+
+	lag = now.Sub(source.ModifiedAt)
+
+	return lag, lag > rule.MaxLag
+}
+
+func checksumsMatch(rule *config.ReplicaConfiguration, source *replicaEndpoint, sourceFile *fs.FileInfo, replica *replicaEndpoint, replicaFile *fs.FileInfo) (bool, error) {
+	sourceChecksum, err := checksumOf(rule.Source.Environment, source, sourceFile)
+	if err != nil {
+		return false, err
+	}
+
+	replicaChecksum, err := checksumOf(rule.Replica.Environment, replica, replicaFile)
+	if err != nil {
+		return false, err
+	}
+
+	return sourceChecksum == replicaChecksum, nil
+}
+
+func checksumOf(environment string, endpoint *replicaEndpoint, file *fs.FileInfo) (string, error) {
+	if file.Checksum != "" {
+		return file.Checksum, nil
+	}
+
+	key := environment + ":" + endpoint.disk.Name + ":" + path.Join(file.Parent, file.Name)
+	cached, exists := checksums[key]
+
+	if !exists {
+		cached, exists = previousChecksums[key]
+	}
+
+	if exists && cached.size == file.Size && cached.modifiedAt.Equal(file.ModifiedAt) {
+		checksums[key] = cached
+		return cached.checksum, nil
+	}
+
+	log.Debugf("Computing checksum of '%s' in disk '%s'", file.Name, endpoint.disk.Name)
+
+	reader, _, _, err := endpoint.client.Download(endpoint.disk.Name, file)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = reader.Close() }()
+
+	hash := md5.New()
+	if _, err = io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	checksums[key] = &cachedChecksum{size: file.Size, modifiedAt: file.ModifiedAt, checksum: checksum}
+
+	return checksum, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH31_isReplicaOf_matchesByInterpolatedTimestamp(t *testing.T) {
+	assertion := assert.New(t)
+	timestamp := time.Date(2024, 3, 31, 2, 0, 0, 0, time.UTC)
+	other := timestamp.AddDate(0, 0, -1)
+	rule := &config.ReplicaConfiguration{}
+
+	source := &fs.FileInfo{Name: "dump-2024-03-31.sql.gz", Size: 100, InterpolatedTimestamp: &timestamp}
+	replica := &fs.FileInfo{Name: "2024/03/31/dump.sql.gz", Size: 90, InterpolatedTimestamp: &timestamp}
+	outdated := &fs.FileInfo{Name: "2024/03/30/dump.sql.gz", Size: 100, InterpolatedTimestamp: &other}
+
+	assertion.True(isReplicaOf(rule, source, replica))
+	assertion.False(isReplicaOf(rule, source, outdated))
+
+	rule.MatchSize = true
+	assertion.False(isReplicaOf(rule, source, replica))
+}
+
+func Test_GH31_replicaLag_flagsMissingReplicaAfterMaxLag(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	rule := &config.ReplicaConfiguration{MaxLag: 6 * time.Hour}
+	source := &fs.FileInfo{ModifiedAt: now.Add(-2 * time.Hour)}
+	replica := &fs.FileInfo{ModifiedAt: now.Add(-90 * time.Minute)}
+
+	lag, missing := replicaLag(rule, source, replica, true, now)
+	assertion.Equal(30*time.Minute, lag)
+	assertion.False(missing)
+
+	lag, missing = replicaLag(rule, source, nil, false, now)
+	assertion.Equal(2*time.Hour, lag)
+	assertion.False(missing)
+
+	source.ModifiedAt = now.Add(-7 * time.Hour)
+	_, missing = replicaLag(rule, source, nil, false, now)
+	assertion.True(missing)
+}
+
+func Test_GH31_evaluateGroup_checksAllSourceFiles(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	rule := &config.ReplicaConfiguration{Name: "offsite", MaxLag: 6 * time.Hour, Replica: &config.ReplicaEndpointConfiguration{}}
+	file := func(name string, modifiedAt time.Time) TemporalFile {
+		return TemporalFile{Time: modifiedAt, File: &fs.FileInfo{Name: name, ModifiedAt: modifiedAt}}
+	}
+
+	source := FileGroup{
+		file("d0", now.Add(-10*time.Hour)),
+		file("d1", now.Add(-34*time.Hour)),
+		file("d2", now.Add(-58*time.Hour)),
+		// older than all replicas, e.g. purged by a shorter retention of the replica
+		file("d3", now.Add(-82*time.Hour)),
+	}
+	replica := FileGroup{
+		file("d0", now.Add(-9*time.Hour)),
+		file("d2", now.Add(-57*time.Hour)),
+	}
+
+	lag, missing := evaluateGroup(rule, "", nil, source, nil, replica, now)
+	assertion.True(missing)
+	assertion.Equal(34*time.Hour, lag)
+
+	replica = append(replica, file("d1", now.Add(-33*time.Hour)))
+	lag, missing = evaluateGroup(rule, "", nil, source, nil, replica, now)
+	assertion.False(missing)
+	assertion.Equal(time.Hour, lag)
+
+	lag, missing = evaluateGroup(rule, "", nil, source, nil, nil, now)
+	assertion.True(missing)
+	assertion.Equal(82*time.Hour, lag)
+}
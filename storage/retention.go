@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+)
+
+// retention is the outcome of applying the retention policy of a file definition to a sorted FileGroup
+type retention struct {
+	// keep[i] is true if list[i] must be retained
+	keep []bool
+	// amount of files younger than the retention age
+	young uint64
+	// amount of files retained by each bucket; a file can be retained by multiple buckets
+	buckets map[string]uint64
+}
+
+func (r *retention) excess() int {
+	count := 0
+
+	for _, keep := range r.keep {
+		if !keep {
+			count++
+		}
+	}
+
+	return count
+}
+
+// applyRetention decides which files of the newest-first sorted list are retained. The newest `retention-count` files and
+// all files younger than `retention-age` are always retained. On top of that, each grandfather-father-son bucket
+// retains the newest file of each of its last periods, based upon each file's sort time.
+func applyRetention(list FileGroup, fileDef *backup.FileDefinition, now time.Time) *retention {
+	r := &retention{
+		keep:    make([]bool, len(list)),
+		buckets: make(map[string]uint64),
+	}
+
+	threshold := now.Add(-fileDef.RetentionAge)
+	r.young = uint64(sort.Search(len(list), func(i int) bool { return list[i].Time.Before(threshold) }))
+
+	keep := fileDef.RetentionCount
+	if r.young > keep {
+		keep = r.young
+	}
+
+	if keep > uint64(len(list)) {
+		keep = uint64(len(list))
+	}
+
+	for i := uint64(0); i < keep; i++ {
+		r.keep[i] = true
+	}
+
+	r.buckets[backup.RetentionBucketLatest] = keep
+
+	for _, bucket := range fileDef.RetentionBuckets() {
+		kept := uint64(0)
+		lastKey := ""
+
+		for i := 0; i < len(list) && kept < bucket.Count; i++ {
+			key := bucket.Key(list[i].Time)
+
+			if key == lastKey {
+				// an even newer file already represents this period
+				continue
+			}
+
+			lastKey = key
+			r.keep[i] = true
+			kept++
+		}
+
+		r.buckets[bucket.Name] = kept
+	}
+
+	return r
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// creates one file per day, newest first, beginning with `newest`
+func dailyFiles(newest time.Time, days int) FileGroup {
+	list := make(FileGroup, days)
+
+	for i := 0; i < days; i++ {
+		t := newest.AddDate(0, 0, -i)
+		list[i] = TemporalFile{Time: t, File: &fs.FileInfo{Name: t.Format("2006-01-02")}}
+	}
+
+	return list
+}
+
+func retainedNames(list FileGroup, r *retention) []string {
+	var names []string
+
+	for i, keep := range r.keep {
+		if keep {
+			names = append(names, list[i].File.Name)
+		}
+	}
+
+	return names
+}
+
+func Test_GH26_applyRetention_keepsNewestFilePerBucket(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now, 100)
+
+	sut := applyRetention(list, &backup.FileDefinition{
+		RetentionDaily:   3,
+		RetentionWeekly:  2,
+		RetentionMonthly: 3,
+	}, now.Add(time.Hour))
+
+	assertion.Equal([]string{
+		"2024-03-31", // daily, weekly (W13) and monthly (March)
+		"2024-03-30", // daily
+		"2024-03-29", // daily
+		"2024-03-24", // weekly (W12)
+		"2024-02-29", // monthly (February)
+		"2024-01-31", // monthly (January)
+	}, retainedNames(list, sut))
+
+	assertion.Equal(uint64(0), sut.buckets[backup.RetentionBucketLatest])
+	assertion.Equal(uint64(3), sut.buckets[backup.RetentionBucketDaily])
+	assertion.Equal(uint64(2), sut.buckets[backup.RetentionBucketWeekly])
+	assertion.Equal(uint64(3), sut.buckets[backup.RetentionBucketMonthly])
+	assertion.Equal(94, sut.excess())
+}
+
+func Test_GH26_applyRetention_combinesCountAndBuckets(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now, 400)
+
+	sut := applyRetention(list, &backup.FileDefinition{
+		RetentionCount:  2,
+		RetentionYearly: 5,
+	}, now.Add(time.Hour))
+
+	assertion.Equal([]string{"2024-03-31", "2024-03-30", "2023-12-31"}, retainedNames(list, sut))
+	assertion.Equal(uint64(2), sut.buckets[backup.RetentionBucketLatest])
+	// only two years are present
+	assertion.Equal(uint64(2), sut.buckets[backup.RetentionBucketYearly])
+}
+
+func Test_applyRetention_keepsYoungFiles(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now, 10)
+
+	sut := applyRetention(list, &backup.FileDefinition{
+		RetentionCount: 1,
+		RetentionAge:   72 * time.Hour,
+	}, now)
+
+	assertion.Equal(uint64(4), sut.young)
+	assertion.Equal(6, sut.excess())
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dreitier/backmon/history"
+)
+
+// ScanTrigger is a requested scan of all disks, of a single environment or of a single disk. Triggers requested while
+// another scan is pending are coalesced into the pending one, so that each caller can wait for the same scan; see #39.
+type ScanTrigger struct {
+	scan        *scanRequest
+	environment string
+	disk        string
+}
+
+// scanRequest is the pending scan of all coalesced triggers
+type scanRequest struct {
+	all bool
+	// disks per environment; an empty set includes all disks of the environment
+	scopes  map[string]map[string]bool
+	done    chan struct{}
+	results []history.Scan
+}
+
+// ErrUnknownScope the environment or disk of a scan does not exist
+var ErrUnknownScope = errors.New("unknown scan scope")
+
+var (
+	scanMutex   = &sync.Mutex{}
+	pendingScan *scanRequest
+	scanWake    = make(chan struct{}, 1)
+	scannerOnce sync.Once
+)
+
+// TriggerScan requests a scan of all disks, of a single environment or of a single disk of an environment. Empty names
+// include everything. The scan is run asynchronously; use Wait or Done to wait for its results.
+func TriggerScan(environment string, disk string) *ScanTrigger {
+	scannerOnce.Do(func() {
+		go runScanner()
+	})
+
+	scanMutex.Lock()
+	defer scanMutex.Unlock()
+
+	if pendingScan == nil {
+		pendingScan = &scanRequest{scopes: make(map[string]map[string]bool), done: make(chan struct{})}
+
+		select {
+		case scanWake <- struct{}{}:
+		default:
+		}
+	}
+
+	pendingScan.add(environment, disk)
+
+	return &ScanTrigger{scan: pendingScan, environment: environment, disk: disk}
+}
+
+// ValidateScanScope Return an error if the environment or the disk of the environment does not exist
+func ValidateScanScope(environment string, disk string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if environment == "" {
+		return nil
+	}
+
+	client, exists := clients[environment]
+
+	if !exists {
+		return fmt.Errorf("%w: environment '%s'", ErrUnknownScope, environment)
+	}
+
+	if _, exists = client.Disks[disk]; disk != "" && !exists {
+		return fmt.Errorf("%w: disk '%s' in environment '%s'", ErrUnknownScope, disk, environment)
+	}
+
+	return nil
+}
+
+func (t *scanRequest) add(environment string, disk string) {
+	if environment == "" {
+		t.all = true
+		return
+	}
+
+	disks, exists := t.scopes[environment]
+
+	if !exists {
+		disks = make(map[string]bool)
+		t.scopes[environment] = disks
+	} else if len(disks) == 0 {
+		// the whole environment has already been requested
+		return
+	}
+
+	if disk == "" {
+		// include the whole environment
+		t.scopes[environment] = make(map[string]bool)
+		return
+	}
+
+	disks[disk] = true
+}
+
+func (t *scanRequest) includes(environment string, disk string) bool {
+	if t.all {
+		return true
+	}
+
+	disks, exists := t.scopes[environment]
+
+	return exists && (disk == "" || len(disks) == 0 || disks[disk])
+}
+
+// Done is closed after the scan has finished
+func (t *ScanTrigger) Done() <-chan struct{} {
+	return t.scan.done
+}
+
+// Results Return the result of each disk in the scope of the trigger; the coalesced scan may have included other disks.
+// Only valid after the scan has finished.
+func (t *ScanTrigger) Results() []history.Scan {
+	r := []history.Scan{}
+
+	for _, scan := range t.scan.results {
+		// failures of an environment have no disk
+		if t.environment == "" || (scan.Environment == t.environment && (t.disk == "" || scan.Disk == "" || scan.Disk == t.disk)) {
+			r = append(r, scan)
+		}
+	}
+
+	return r
+}
+
+// Wait waits until the scan has finished and returns its results
+func (t *ScanTrigger) Wait() []history.Scan {
+	<-t.scan.done
+
+	return t.Results()
+}
+
+func runScanner() {
+	for range scanWake {
+		scanMutex.Lock()
+		trigger := pendingScan
+		pendingScan = nil
+		scanMutex.Unlock()
+
+		if trigger == nil {
+			continue
+		}
+
+		trigger.results = updateDisks(trigger.includes)
+
+		// #37: listeners are called outside the lock, so that they can access the updated disks
+		mutex.Lock()
+		current := append([]func(){}, listeners...)
+		mutex.Unlock()
+
+		for _, listener := range current {
+			listener()
+		}
+
+		close(trigger.done)
+	}
+}
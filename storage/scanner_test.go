@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/dreitier/backmon/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH39_ScanTrigger_coalescesScopes(t *testing.T) {
+	assertion := assert.New(t)
+	sut := &scanRequest{scopes: make(map[string]map[string]bool)}
+
+	sut.add("prod", "db")
+	sut.add("prod", "files")
+	sut.add("staging", "")
+	sut.add("staging", "db")
+
+	assertion.True(sut.includes("prod", ""))
+	assertion.True(sut.includes("prod", "db"))
+	assertion.True(sut.includes("prod", "files"))
+	assertion.False(sut.includes("prod", "logs"))
+	assertion.True(sut.includes("staging", "logs"))
+	assertion.False(sut.includes("dev", ""))
+
+	sut.add("prod", "")
+	assertion.True(sut.includes("prod", "logs"))
+
+	sut.add("", "")
+	assertion.True(sut.includes("dev", "logs"))
+}
+
+func Test_GH39_ScanTrigger_Results_filtersByScope(t *testing.T) {
+	assertion := assert.New(t)
+	scan := &scanRequest{results: []history.Scan{
+		{Environment: "prod", Disk: "db"},
+		{Environment: "prod", Disk: "files"},
+		{Environment: "staging", Error: "could not retrieve disk names"},
+	}}
+
+	assertion.Len((&ScanTrigger{scan: scan}).Results(), 3)
+	assertion.Equal(scan.results[:2], (&ScanTrigger{scan: scan, environment: "prod"}).Results())
+	assertion.Equal(scan.results[1:2], (&ScanTrigger{scan: scan, environment: "prod", disk: "files"}).Results())
+	assertion.Equal(scan.results[2:], (&ScanTrigger{scan: scan, environment: "staging", disk: "db"}).Results())
+	assertion.Empty((&ScanTrigger{scan: scan, environment: "dev"}).Results())
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+)
+
+// status of a group or disk, see #37
+const (
+	StatusOk = "ok"
+	// the latest backup file is older than the previous scheduled run
+	StatusLate = "late"
+	// the group contains no backup file of the file definition
+	StatusMissing = "missing"
+	// the backup definitions of the disk could not be loaded; directory, file and group are empty
+	StatusDefinitionsMissing = "definitions_missing"
+	// the backup job has reported a failure of its latest run, see #38
+	StatusFailed = "failed"
+	// the backup job has reported a success, but no backup file has appeared
+	StatusUnconfirmed = "unconfirmed"
+)
+
+// GroupStatus the status of the latest backup file of a file definition in a group
+type GroupStatus struct {
+	Environment string    `json:"environment"`
+	Disk        string    `json:"disk"`
+	Directory   string    `json:"directory,omitempty"`
+	File        string    `json:"file,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Status      string    `json:"status"`
+	Latest      time.Time `json:"latest,omitempty"`
+}
+
+var listeners []func()
+
+// OnUpdated registers a function which is called after each scan
+func OnUpdated(listener func()) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	listeners = append(listeners, listener)
+}
+
+// GetStatuses Return the status of all groups of all disks as of the latest update
+func GetStatuses() []GroupStatus {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var r []GroupStatus
+
+	for _, client := range clients {
+		for _, disk := range client.Disks {
+			r = append(r, disk.statuses...)
+		}
+	}
+
+	return r
+}
+
+func (disk *DiskData) addStatus(dirDef *backup.Directory, fileDef *backup.FileDefinition, group string, latest *TemporalFile, now time.Time) {
+	status := GroupStatus{
+		Environment: disk.Environment,
+		Disk:        disk.Name,
+		Directory:   dirDef.Alias,
+		File:        fileDef.Alias,
+		Group:       group,
+		Status:      StatusMissing,
+	}
+
+	if latest != nil {
+		status.Latest = latest.Time
+		status.Status = StatusOk
+
+		if backup.IsLate(fileDef.Schedule, latest.Time, now) {
+			status.Status = StatusLate
+		}
+	}
+
+	// #38: the heartbeats of the backup job tell more than the file
+	jobsMutex.Lock()
+	if job := disk.jobs[jobKey(dirDef.Alias, fileDef.Alias, group)]; job != nil && status.Status != StatusMissing {
+		if job.failed() {
+			status.Status = StatusFailed
+		} else if job.Unconfirmed {
+			status.Status = StatusUnconfirmed
+		}
+	}
+	jobsMutex.Unlock()
+
+	disk.statuses = append(disk.statuses, status)
+}
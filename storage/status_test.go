@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/backup"
+	"github.com/gorhill/cronexpr"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH37_addStatus_distinguishesOkLateAndMissing(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	disk := &DiskData{Environment: "prod", Name: "backups"}
+	dirDef := &backup.Directory{Alias: "db"}
+	fileDef := &backup.FileDefinition{Alias: "dump", Schedule: cronexpr.MustParse("0 2 * * *")}
+
+	disk.addStatus(dirDef, fileDef, "a", &TemporalFile{Time: now.Add(-9 * time.Hour)}, now)
+	disk.addStatus(dirDef, fileDef, "b", &TemporalFile{Time: now.Add(-11 * time.Hour)}, now)
+	disk.addStatus(dirDef, fileDef, "c", nil, now)
+
+	assertion.Equal(StatusOk, disk.statuses[0].Status)
+	assertion.Equal(StatusLate, disk.statuses[1].Status)
+	assertion.Equal(StatusMissing, disk.statuses[2].Status)
+	assertion.True(disk.statuses[2].Latest.IsZero())
+}
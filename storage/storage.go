@@ -7,13 +7,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dreitier/backmon/audit"
 	"github.com/dreitier/backmon/backup"
 	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/events"
+	"github.com/dreitier/backmon/history"
 	"github.com/dreitier/backmon/metrics"
 	fs "github.com/dreitier/backmon/storage/fs"
 	log "github.com/sirupsen/logrus"
@@ -111,6 +117,11 @@ func (client *clientData) updateDiskInfo(environmentName string) error {
 		}
 
 		if !exists {
+			// #27: the disk is still monitored, but its v1 API is not reachable
+			if IsReservedDiskName(diskName) {
+				log.Warnf("[env:%s] The disk '%s' is shadowed by the route /api/%s; use /api/v2/disks/%s instead of /api/%s", environmentName, diskName, diskName, url.PathEscape(diskName), diskName)
+			}
+
 			safeAlias, _ := backup.MakeLegalAlias(diskName)
 			// no warning for now to avoid spamming the logs on every refresh
 			// if warn {
@@ -118,15 +129,31 @@ func (client *clientData) updateDiskInfo(environmentName string) error {
 			// }
 
 			client.Disks[diskName] = &DiskData{
-				Name:     diskName,
-				SafeName: safeAlias,
-				metrics:  metrics.NewDisk(diskName),
+				Environment: environmentName,
+				Name:        diskName,
+				SafeName:    safeAlias,
+				metrics:     metrics.NewDisk(diskName),
 			}
 		}
 	}
 	return nil
 }
 
+// ReservedDiskNames the first path segments of the fixed routes below /api, which shadow the v1 API of disks with the
+// same name; see #27
+var ReservedDiskNames = []string{"audit", "events", "heartbeats", "history", "openapi.json", "purge", "report", "rescan", "silences", "v2"}
+
+// IsReservedDiskName Return true if the v1 API of the disk is shadowed by a fixed route
+func IsReservedDiskName(diskName string) bool {
+	for _, reserved := range ReservedDiskNames {
+		if diskName == reserved {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (client *clientData) dropAllDisks() {
 	for _, disk := range client.Disks {
 		disk.metrics.Drop()
@@ -135,13 +162,34 @@ func (client *clientData) dropAllDisks() {
 }
 
 type DiskData struct {
+	Environment     string
 	Name            string
 	SafeName        string
 	metrics         *metrics.DiskMetric
-	groups          []map[string][]*fs.FileInfo
 	quota           uint64
 	Definition      *backup.Definition
 	definitionsHash [sha1.Size]byte
+	// when the definitions have been changed while backmon was running; zero if they are unchanged since start
+	definitionsChangedAt time.Time
+	// retention locks of purge candidates and the locked files reported, see #30
+	locks  lockCache
+	locked reportedFiles
+	// purge candidates reported in dry-run mode, see #27
+	candidates reportedFiles
+	// purging has been paused by an admin, see #48
+	purgePaused bool
+	// listing of the previous scan and the files purged since, see #32
+	snapshot *scanSnapshot
+	purged   map[string]bool
+	// usage samples for the growth forecast, see #35
+	usage *usageSeries
+	// status of all groups as of the latest scan, see #37
+	statuses []GroupStatus
+	// heartbeats of the backup jobs, see #38
+	jobs map[string]*JobStatus
+	// all backup files of each group and file definition after purging and the result of the latest scan, see #41
+	files    []map[string][]*GroupFiles
+	lastScan *history.Scan
 }
 
 func (disk *DiskData) MarshalJSON() ([]byte, error) {
@@ -152,6 +200,7 @@ func (disk *DiskData) updateDefinitions(data io.Reader) {
 	var buf bytes.Buffer
 
 	duplicate := io.TeeReader(data, &buf)
+	initial := disk.definitionsHash == [sha1.Size]byte{}
 	changed, err := disk.hashChanged(duplicate)
 	if err != nil {
 		log.Errorf("Failed to update backup definitions in '%s': %s", disk.Name, err)
@@ -166,6 +215,11 @@ func (disk *DiskData) updateDefinitions(data io.Reader) {
 	}
 
 	log.Infof("Backup definitions in '%s' changed, parsing new definitions.", disk.Name)
+
+	if !initial {
+		disk.definitionsChangedAt = time.Now().UTC()
+	}
+
 	disk.Definition, err = backup.ParseDefinition(&buf)
 	if err != nil {
 		log.Errorf("Failed to parse backup definitions in '%s': %s", disk.Name, err)
@@ -179,7 +233,7 @@ func (disk *DiskData) updateDefinitions(data io.Reader) {
 
 	disk.metrics.DefinitionsUpdated()
 	disk.metrics.UpdateDiskQuota(disk.Definition.Quota)
-	disk.groups = make([]map[string][]*fs.FileInfo, len(disk.Definition.Directories))
+	disk.files = make([]map[string][]*GroupFiles, len(disk.Definition.Directories))
 }
 
 func (disk *DiskData) hashChanged(data io.Reader) (changed bool, err error) {
@@ -248,76 +302,380 @@ func (list FileGroup) Swap(i int, j int) {
 
 // END
 
-func (list FileGroup) Purge(fileDef *backup.FileDefinition, path string, disk string, client Client) (remainder FileGroup, young uint64) {
-	threshold := time.Now().UTC().Add(-fileDef.RetentionAge)
-	young = uint64(sort.Search(len(list), func(i int) bool { return list[i].Time.Before(threshold) }))
+// Purge deletes all files which are not retained by the file definition's retention policy. It returns the remaining
+// files, the amount of young files and the amount of files retained by each retention bucket.
+// In dry-run mode, the files are only reported as purge candidates.
+func (list FileGroup) Purge(dirDef *backup.Directory, fileDef *backup.FileDefinition, group string, disk *DiskData, client Client) (remainder FileGroup, young uint64, retained map[string]uint64) {
+	// #27: dry-run can be enabled globally or per file definition
+	dryRun := fileDef.PurgeDryRun || config.GetInstance().Global().PurgeDryRun()
+
+	remainder, young, retained, entries := list.purge(dirDef, fileDef, group, disk, client, dryRun, config.GetInstance().Global().PurgeGuards(), time.Now().UTC())
+
+	for _, entry := range entries {
+		audit.Log(entry)
+	}
+
+	return remainder, young, retained
+}
+
+// purge Purge the files not retained and return the remainder and the entries for the audit log
+func (list FileGroup) purge(dirDef *backup.Directory, fileDef *backup.FileDefinition, group string, disk *DiskData, client Client, dryRun bool, guards *config.PurgeGuardsConfiguration, now time.Time) (remainder FileGroup, young uint64, retained map[string]uint64, entries []audit.Entry) {
+	policy := applyRetention(list, fileDef, now)
+	excess := policy.excess()
 
-	keep := fileDef.RetentionCount
-	if young > keep {
-		keep = young
+	if !fileDef.Purge || excess == 0 {
+		return list, policy.young, policy.buckets, nil
 	}
 
-	if !fileDef.Purge || uint64(len(list)) <= keep {
-		return list, young
+	// #28: refuse to purge if anything looks suspicious
+	guard, reason := checkPurgeGuards(list, policy, fileDef, guards, disk.definitionsChangedAt, now)
+
+	// #48
+	if disk.purgePaused {
+		guard, reason = PurgeGuardPaused, "purging of the disk has been paused"
+	}
+
+	if guard != "" {
+		log.Warnf("Refusing to purge %d excess files matching %#q in %#q from disk %#q (guard: %s): %s", excess, fileDef.Pattern, group, disk.Name, guard, reason)
+		disk.metrics.PurgeRefused(dirDef.Alias, fileDef.Alias, guard)
+		return list, policy.young, policy.buckets, nil
+	}
+
+	if dryRun {
+		log.Debugf("[dry-run] %d excess files matching %#q in %#q from disk %#q would be purged", excess, fileDef.Pattern, group, disk.Name)
+	} else {
+		log.Infof("Purging %d excess files matching %#q in %#q from disk %#q", excess, fileDef.Pattern, group, disk.Name)
 	}
 
-	excess := list[keep:]
-	log.Infof("Purging %d excess files matching %#q in %#q from disk %#q", len(excess), fileDef.Pattern, path, disk)
+	remainder = make(FileGroup, 0, len(list)-excess)
 
-	for _, file := range excess {
-		err := client.Delete(disk, file.File)
+	for i, file := range list {
+		if policy.keep[i] {
+			remainder = append(remainder, file)
+			continue
+		}
+
+		entry := audit.Entry{
+			DryRun:      dryRun,
+			Environment: disk.Environment,
+			Disk:        disk.Name,
+			Directory:   dirDef.Alias,
+			File:        fileDef.Alias,
+			Group:       group,
+			Name:        file.File.Name,
+			Parent:      file.File.Parent,
+			Size:        file.File.Size,
+			SortTime:    file.Time,
+		}
+
+		if fileDef.PurgeAction == backup.PurgeActionMove {
+			entry.Target = fileDef.PurgeTarget
+
+			if fileDef.PurgeTargetDisk != "" {
+				entry.Target = fileDef.PurgeTargetDisk + ":" + fileDef.PurgeTarget
+			}
+		}
+
+		// #30: locked files cannot be purged, don't even try
+		if locked, status := disk.isLocked(client, file.File, now); locked {
+			remainder = append(remainder, file)
+
+			// only files which have become locked are reported
+			if !disk.locked.report(file.File) {
+				log.Debugf("Skipping purge of locked file '%s'", file.File.Name)
+				continue
+			}
+
+			log.Infof("Skipping purge of locked file '%s' (mode: %#q, retain until: %s, legal hold: %t)",
+				file.File.Name, status.Mode, status.RetainUntil.Format(time.RFC3339), status.LegalHold)
+			entry.Action = audit.ActionLocked
+			entries = append(entries, entry)
+			continue
+		}
+
+		if dryRun {
+			// the file still exists
+			remainder = append(remainder, file)
+
+			// #27: candidates are reported once instead of during each scan
+			if !disk.candidates.report(file.File) {
+				continue
+			}
+
+			log.Infof("[dry-run] Would purge file '%s' (%s)", file.File.Name, fileDef.PurgeAction)
+			entry.Action = audit.ActionCandidate
+			entries = append(entries, entry)
+			disk.metrics.FilePurged(dirDef.Alias, fileDef.Alias, fileDef.PurgeAction, true, file.File.Size)
+			continue
+		}
+
+		var err error
+
+		// #29: expired files can be moved to an archive instead of deleting them
+		if fileDef.PurgeAction == backup.PurgeActionMove {
+			err = client.Move(disk.Name, file.File, fileDef.PurgeTargetDisk, fileDef.PurgeTarget)
+		} else {
+			err = client.Delete(disk.Name, file.File)
+		}
 
 		if err != nil {
-			list[keep] = file
-			keep++
+			remainder = append(remainder, file)
 			log.Warnf("Could not purge file '%s': %s", file.File.Name, err)
+			entry.Action = audit.ActionFailed
+			entry.Error = err.Error()
 		} else {
-			log.Infof("Purged file '%s'", file.File.Name)
+			log.Infof("Purged file '%s' (%s)", file.File.Name, fileDef.PurgeAction)
+			disk.markPurged(file.File)
+			disk.publish(events.Event{
+				Type:      events.TypeFilePurged,
+				Directory: dirDef.Alias,
+				File:      fileDef.Alias,
+				Group:     group,
+				Name:      path.Join(file.File.Parent, file.File.Name),
+				Detail:    fileDef.PurgeAction,
+			})
+			entry.Action = audit.ActionDeleted
+
+			if fileDef.PurgeAction == backup.PurgeActionMove {
+				entry.Action = audit.ActionMoved
+			}
+
+			disk.metrics.FilePurged(dirDef.Alias, fileDef.Alias, fileDef.PurgeAction, false, file.File.Size)
 		}
+
+		entries = append(entries, entry)
 	}
-	return list[:keep], young
+
+	return remainder, policy.young, policy.buckets, entries
+}
+
+// reportedFiles remembers the files reported during the previous and the current scan, so that a file is only
+// reported once as long as it is unchanged
+type reportedFiles struct {
+	previous map[string]time.Time
+	current  map[string]time.Time
+}
+
+// rotate starts a new scan; files which are not reported again during the scan are forgotten
+func (r *reportedFiles) rotate() {
+	r.previous, r.current = r.current, nil
+}
+
+// report Return true if the file has neither been reported during the previous nor during the current scan, or has
+// been modified since
+func (r *reportedFiles) report(file *fs.FileInfo) bool {
+	key := path.Join(file.Parent, file.Name)
+	modified, reported := r.current[key]
+
+	if !reported {
+		modified, reported = r.previous[key]
+	}
+
+	if r.current == nil {
+		r.current = make(map[string]time.Time)
+	}
+
+	r.current[key] = file.ModifiedAt
+
+	return !reported || !modified.Equal(file.ModifiedAt)
 }
 
+// downloadDefinitions downloads and parses the backup definitions file of the disk
+func (client *clientData) downloadDefinitions(environmentName string, disk *DiskData) error {
+	log.Debugf("[env:%s][disk:%s] Downloading backup definitions file", environmentName, disk.Name)
+
+	buf, _, _, err := client.Client.Download(disk.Name, client.Definition)
+	if err != nil {
+		log.Errorf("[env:%s][disk:%s] Backup definitions file '%s' could not be opened: %v", environmentName, disk.Name, client.DefinitionFilename, err)
+		disk.metrics.DefinitionsMissing()
+		return fmt.Errorf("backup definitions file could not be opened: %v", err)
+	}
+
+	disk.updateDefinitions(buf)
+	_ = buf.Close()
+
+	return nil
+}
+
+// archivePaths Return the paths inside the disk below which the file definitions of all disks of the client move purged
+// files; see #29
+func (client *clientData) archivePaths(diskName string) []string {
+	var r []string
+
+	for name, disk := range client.Disks {
+		if disk.Definition == nil {
+			continue
+		}
+
+		for _, dirDef := range disk.Definition.Directories {
+			for _, fileDef := range dirDef.Files {
+				if !fileDef.Purge || fileDef.PurgeAction != backup.PurgeActionMove {
+					continue
+				}
+
+				targetDisk := fileDef.PurgeTargetDisk
+
+				if targetDisk == "" {
+					targetDisk = name
+				}
+
+				if targetDisk != diskName {
+					continue
+				}
+
+				target := fileDef.PurgeTarget
+
+				// absolute targets of local disks
+				if filepath.IsAbs(target) {
+					relative, err := filepath.Rel(diskName, target)
+
+					if err != nil || strings.HasPrefix(relative, "..") {
+						continue
+					}
+
+					target = filepath.ToSlash(relative)
+				}
+
+				r = append(r, strings.Trim(target, "/"))
+			}
+		}
+	}
+
+	return r
+}
+
+// excludeDirectory removes the directory with the slash separated path from the listing
+func excludeDirectory(root *fs.DirectoryInfo, dirPath string) {
+	segments := strings.Split(dirPath, "/")
+	parent := root
+
+	for _, segment := range segments[:len(segments)-1] {
+		if parent = parent.SubDirs[segment]; parent == nil {
+			return
+		}
+	}
+
+	delete(parent.SubDirs, segments[len(segments)-1])
+}
+
+// LoadDefinitions only loads the disks and their backup definitions, without scanning or purging any files
+func LoadDefinitions() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for environmentName, cd := range clients {
+		if err := cd.updateDiskInfo(environmentName); err != nil {
+			log.Errorf("[env:%s] Could not retrieve disk names from client: %v", environmentName, err)
+			continue
+		}
+
+		for _, disk := range cd.Disks {
+			_ = cd.downloadDefinitions(environmentName, disk)
+		}
+	}
+}
+
+// UpdateDiskInfo scans all disks and waits until the scan has finished; see TriggerScan
 func UpdateDiskInfo() {
+	TriggerScan("", "").Wait()
+}
+
+// updateDisks scans all disks included by the scope and returns the result of each scan
+func updateDisks(includes func(environment string, disk string) bool) []history.Scan {
 	log.Info("Updating disks info...")
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	results := []history.Scan{}
+
 	for environmentName, cd := range clients {
+		if !includes(environmentName, "") {
+			continue
+		}
+
 		log.Debugf("[env:%s] Updating disks", environmentName)
 
 		if err := cd.updateDiskInfo(environmentName); err != nil {
 			log.Errorf("[env:%s] Could not retrieve disk names from client: %v", environmentName, err)
+			results = append(results, history.Scan{
+				Time:        time.Now().UTC(),
+				Environment: environmentName,
+				Error:       fmt.Sprintf("could not retrieve disk names: %v", err),
+			})
 			continue
 		}
 
 		for diskName, disk := range cd.Disks {
-			log.Debugf("[env:%s][disk:%s] Downloading backup definitions file", environmentName, diskName)
+			if !includes(environmentName, diskName) {
+				continue
+			}
 
-			buf, _, _, err := cd.Client.Download(diskName, cd.Definition)
-			if err != nil {
-				log.Errorf("[env:%s][disk:%s] Backup definitions file '%s' could not be opened: %v", environmentName, diskName, cd.DefinitionFilename, err)
-				disk.metrics.DefinitionsMissing()
-			} else {
-				disk.updateDefinitions(buf)
-				_ = buf.Close()
+			startedAt := time.Now()
+			scan := history.Scan{Time: startedAt.UTC(), Environment: environmentName, Disk: diskName}
+			if err := cd.downloadDefinitions(environmentName, disk); err != nil {
+				scan.Error = err.Error()
 			}
 
-			files, err := cd.Client.GetFileNames(diskName, disk.maxDepth())
+			depth := disk.maxDepth()
+			files, err := cd.Client.GetFileNames(diskName, depth)
 			if err != nil {
 				log.Errorf("[env:%s][disk:%s] Failed to retrieve files from disk: %v", environmentName, diskName, err)
 				// don't just return, we still need to update the metrics!
 				files = &fs.DirectoryInfo{Name: diskName}
+				scan.Error = fmt.Sprintf("failed to retrieve files from disk: %v", err)
+			} else {
+				// #29: files moved into an archive must not be matched again
+				for _, archive := range cd.archivePaths(diskName) {
+					excludeDirectory(files, archive)
+				}
+
+				// #32: a failed listing must not look like a deleted disk
+				disk.updateAnomalies(cd.Client, files, depth)
+			}
+
+			observations := updateMetrics(cd.Client, disk, files)
+			scan.Files, scan.Bytes = gatherDirUsageStats(files, uint64(0), uint64(0))
+			scan.Definitions = history.Summarize(observations)
+			scan.DurationSeconds = time.Since(startedAt).Seconds()
+
+			// #35: must be updated before the scan is recorded, as the samples are restored from the history
+			disk.updateForecast(&scan, startedAt)
+
+			// #33: without a listing or definitions, the files have not disappeared but are unknown
+			if store := history.GetInstance(); store != nil {
+				if err := store.Record(scan, observations, scan.Error == "" && disk.Definition != nil); err != nil {
+					log.Errorf("[env:%s][disk:%s] Failed to record scan in history: %v", environmentName, diskName, err)
+				}
 			}
 
-			updateMetrics(cd.Client, disk, files)
+			disk.lastScan = &scan
+			results = append(results, scan)
+
+			// #38: heartbeats of new groups are accepted without waiting for the remaining disks
+			publishHeartbeatTargets()
+		}
+	}
+
+	// #38: removed disks do not accept heartbeats anymore
+	publishHeartbeatTargets()
+
+	if store := history.GetInstance(); store != nil {
+		if err := store.Prune(time.Now()); err != nil {
+			log.Errorf("Failed to prune history: %v", err)
 		}
 	}
 
+	// #31: replicas can only be checked after all environments have been updated
+	evaluateReplicas(config.GetInstance().Replicas(), time.Now())
+
 	log.Debug("... disks info updated")
+
+	return results
 }
 
-func updateMetrics(client Client, disk *DiskData, root *fs.DirectoryInfo) {
+// updateMetrics updates the metrics of the disk and purges excess files. It returns all backup files matched by the
+// backup definitions, including the purged ones.
+func updateMetrics(client Client, disk *DiskData, root *fs.DirectoryInfo) (observations []history.Observation) {
 	log.Debugf("Updating metrics ...")
 
 	now := time.Now()
@@ -325,10 +683,29 @@ func updateMetrics(client Client, disk *DiskData, root *fs.DirectoryInfo) {
 	objectCountTotal, objectSizeTotal := gatherDirUsageStats(root, uint64(0), uint64(0))
 	disk.metrics.UpdateUsageStats(objectCountTotal, objectSizeTotal)
 
+	// #36: estimate storage costs
+	costs := newCostCalculator(disk)
+
+	if costs != nil {
+		disk.metrics.UpdateDiskCost(costs.costs.Currency, costs.ofDir(root))
+	}
+
+	// #40: changes of the statuses are published after all groups have been updated
+	previousStatuses := disk.statuses
+	disk.statuses = nil
+	disk.dropJobs()
+	disk.candidates.rotate()
+	disk.locks.rotate()
+	disk.locked.rotate()
+
 	if disk.Definition == nil {
-		return
+		disk.statuses = []GroupStatus{{Environment: disk.Environment, Disk: disk.Name, Status: StatusDefinitionsMissing}}
+		disk.publishStatusChanges(previousStatuses)
+		return nil
 	}
 
+	grace := config.GetInstance().Global().Heartbeats().Grace
+
 	for iDir, dirDef := range disk.Definition.Directories {
 		log.Debugf("# %s", dirDef.Alias)
 		vars := make([]string, len(dirDef.Filter.Variables))
@@ -338,24 +715,66 @@ func updateMetrics(client Client, disk *DiskData, root *fs.DirectoryInfo) {
 		if len(fileGroups) == 0 {
 			log.Warnf("Could not find any file groups in directory %s below root %s. Either the root directory is "+
 				"wrong or no files are matching the defined pattern", dirDef.Alias, root.Name)
+
+			for _, fileDef := range dirDef.Files {
+				disk.addStatus(dirDef, fileDef, "", nil, now)
+			}
 		}
 
+		disk.confirmVanishedJobs(dirDef, fileGroups, grace, now)
+
 		for _, fileDef := range dirDef.Files {
 			lastRun := backup.FindPrevious(fileDef.Schedule, now)
 			disk.metrics.UpdateFileLimits(dirDef.Alias, fileDef.Alias, fileDef.RetentionCount, fileDef.RetentionAge, lastRun)
 		}
 
-		currentGroups := make(map[string][]*fs.FileInfo, len(fileGroups))
+		pastFiles := disk.files[iDir]
+		currentFiles := make(map[string][]*GroupFiles, len(fileGroups))
+		fileAliases := make([]string, len(dirDef.Files))
+
+		for k, fileDef := range dirDef.Files {
+			fileAliases[k] = fileDef.Alias
+		}
+
+		fileCosts := make([]float64, len(dirDef.Files))
+		fileSavings := make([]float64, len(dirDef.Files))
 
 		for group, fileMatches := range fileGroups {
 			latest := make([]*fs.FileInfo, len(dirDef.Files))
+			currentFiles[group] = make([]*GroupFiles, len(dirDef.Files))
 
 			for k, fileDef := range dirDef.Files {
 				matches := fileMatches[k]
 				sort.Sort(matches)
-				matches, young := matches.Purge(fileDef, group, disk.Name, client)
+
+				for _, match := range matches {
+					observations = append(observations, history.Observation{
+						Environment: disk.Environment,
+						Disk:        disk.Name,
+						Directory:   dirDef.Alias,
+						File:        fileDef.Alias,
+						Group:       group,
+						Name:        match.File.Name,
+						Parent:      match.File.Parent,
+						Size:        match.File.Size,
+						SortTime:    match.Time,
+						BornAt:      match.File.BornAt,
+						ModifiedAt:  match.File.ModifiedAt,
+						ArchivedAt:  match.File.ArchivedAt,
+					})
+				}
+
+				matches, young, retained := matches.Purge(dirDef, fileDef, group, disk, client)
 
 				disk.metrics.UpdateFileCounts(dirDef.Alias, fileDef.Alias, group, len(matches), young)
+				currentFiles[group][k] = &GroupFiles{Files: matches, Young: young}
+
+				if costs != nil {
+					cost, savings := costs.ofGroup(matches, fileDef, now.UTC())
+					fileCosts[k] += cost
+					fileSavings[k] += savings
+				}
+				disk.metrics.UpdateRetainedCounts(dirDef.Alias, fileDef.Alias, group, retained)
 
 				if len(matches) > 0 {
 					latest[k] = matches[0].File
@@ -368,25 +787,48 @@ func updateMetrics(client Client, disk *DiskData, root *fs.DirectoryInfo) {
 						group,
 						matches[0].File,
 						matches[0].Time)
+
+					updateImmutability(client, disk, dirDef, fileDef, group, matches[0].File, now)
+					disk.confirmJob(dirDef, fileDef, group, &matches[0], grace, now)
+					disk.addStatus(dirDef, fileDef, group, &matches[0], now)
+				} else {
+					disk.confirmJob(dirDef, fileDef, group, nil, grace, now)
+					disk.addStatus(dirDef, fileDef, group, nil, now)
 				}
 			}
 
-			currentGroups[group] = latest
+			disk.publishGroupChanges(dirDef.Alias, fileAliases, group, pastFiles, latest)
+		}
+
+		if costs != nil {
+			dirCost := 0.0
+
+			for k, fileDef := range dirDef.Files {
+				disk.metrics.UpdateFileCost(dirDef.Alias, fileDef.Alias, costs.costs.Currency, fileCosts[k], fileSavings[k])
+				dirCost += fileCosts[k]
+			}
+
+			disk.metrics.UpdateDirectoryCost(dirDef.Alias, costs.costs.Currency, dirCost)
 		}
 
-		pastGroups := disk.groups[iDir]
-		disk.groups[iDir] = currentGroups
+		disk.files[iDir] = currentFiles
 
-		for group := range pastGroups {
+		for group := range pastFiles {
 			if _, exists := fileGroups[group]; exists {
 				continue
 			}
 
+			disk.publish(events.Event{Type: events.TypeGroupDisappeared, Directory: dirDef.Alias, Group: group})
+
 			for _, fileDef := range dirDef.Files {
 				disk.metrics.DropFile(dirDef.Alias, fileDef.Alias, group)
 			}
 		}
 	}
+
+	disk.publishStatusChanges(previousStatuses)
+
+	return observations
 }
 
 func findMatchingDirs(
@@ -498,14 +940,7 @@ func assembleFromTemplate(template []string, varDefs []backup.VariableDefinition
 			continue
 		}
 
-		if v.Name[0] == backup.SubstitutionMarker {
-			str.WriteString(v.Name)
-			continue
-		}
-
-		str.WriteString("{{")
-		str.WriteString(v.Name)
-		str.WriteString("}}")
+		str.WriteString(fusedValue(v))
 	}
 
 	str.WriteString(template[len(template)-1])
@@ -513,6 +948,15 @@ func assembleFromTemplate(template []string, varDefs []backup.VariableDefinition
 	return str.String()
 }
 
+// fusedValue Return the placeholder of a fused variable, which replaces its values
+func fusedValue(v backup.VariableDefinition) string {
+	if v.Name[0] == backup.SubstitutionMarker {
+		return v.Name
+	}
+
+	return "{{" + v.Name + "}}"
+}
+
 func collectMatchingFiles(
 	files []*fs.FileInfo,
 	fileDef *backup.FileDefinition,
@@ -627,11 +1071,46 @@ func GetDisks() []*DiskData {
 	return disks
 }
 
+// FileDefinitionRef references a file definition in a disk
+type FileDefinitionRef struct {
+	Environment string
+	Disk        string
+	Directory   *backup.Directory
+	File        *backup.FileDefinition
+}
+
+// GetFileDefinitions returns the file definitions of all disks
+func GetFileDefinitions() []FileDefinitionRef {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var r []FileDefinitionRef
+
+	for _, client := range clients {
+		for _, disk := range client.Disks {
+			if disk.Definition == nil {
+				continue
+			}
+
+			for _, dirDef := range disk.Definition.Directories {
+				for _, fileDef := range dirDef.Files {
+					r = append(r, FileDefinitionRef{Environment: disk.Environment, Disk: disk.Name, Directory: dirDef, File: fileDef})
+				}
+			}
+		}
+	}
+
+	return r
+}
+
 func GetFilenames(
 	diskName string,
 	directoryName string,
 	fileName string,
 ) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	groups, file := findGroups(diskName, directoryName, fileName)
 	if groups == nil {
 		return nil
@@ -640,7 +1119,7 @@ func GetFilenames(
 	results := make([]string, 0, len(groups))
 
 	for groupName, files := range groups {
-		if files[file] != nil {
+		if files[file].latest() != nil {
 			results = append(results, groupName)
 		}
 	}
@@ -648,76 +1127,177 @@ func GetFilenames(
 	return results
 }
 
-func Download(
+// FindBackup Return the retained backup of a group with the given name. If no backup has that name and a timestamp
+// is given, the newest backup not younger than the timestamp is returned; without name and timestamp, the latest
+// backup is returned. See #45.
+func FindBackup(
 	diskName string,
 	directoryName string,
 	fileName string,
 	groupName string,
-) (bytes io.ReadCloser, length int64, contentType string, err error) {
-	groups, file := findGroups(diskName, directoryName, fileName)
+	name string,
+	at time.Time,
+) (*fs.FileInfo, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
 
+	groups, file := findGroups(diskName, directoryName, fileName)
 	if groups == nil {
-		return nil, -1, "", errors.New("the requested file does not exist")
+		return nil, fmt.Errorf("%w: '%s'", ErrFileNotFound, fileName)
 	}
 
-	var client *clientData
+	files, exists := groups[groupName]
+	if !exists || files[file] == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrGroupNotFound, groupName)
+	}
 
-	for _, client = range clients {
-		if _, found := client.Disks[diskName]; found {
-			break
+	r := selectBackup(files[file].Files, name, at)
+	if r == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrBackupNotFound, name)
+	}
+
+	return r, nil
+}
+
+// selectBackup Return the file matching the name, either with or without its parent directory, or the newest file
+// not younger than at; files are sorted newest first
+func selectBackup(files FileGroup, name string, at time.Time) *fs.FileInfo {
+	if name == "" && at.IsZero() {
+		if len(files) == 0 {
+			return nil
 		}
+
+		return files[0].File
+	}
+
+	for _, file := range files {
+		if file.File.Name == name || path.Join(file.File.Parent, file.File.Name) == name {
+			return file.File
+		}
+	}
+
+	if at.IsZero() {
+		return nil
 	}
 
+	for _, file := range files {
+		if !file.Time.After(at) {
+			return file.File
+		}
+	}
+
+	return nil
+}
+
+// OpenBackup Return a seekable reader of a backup found by FindBackup; see #46
+func OpenBackup(diskName string, file *fs.FileInfo) (io.ReadSeekCloser, error) {
+	mutex.Lock()
+	client := findClient(diskName)
+	mutex.Unlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrDiskNotFound, diskName)
+	}
+
+	return client.Client.Open(diskName, file)
+}
+
+// PresignBackup Return a URL which downloads the backup without credentials until it expires; errors.ErrUnsupported if
+// the storage of the disk does not support it. See #47
+func PresignBackup(diskName string, file *fs.FileInfo, attachmentName string, expiry time.Duration) (string, error) {
+	mutex.Lock()
+	client := findClient(diskName)
+	mutex.Unlock()
+
 	if client == nil {
-		return nil, -1, "", errors.New("the requested file does not exist")
+		return "", fmt.Errorf("%w: '%s'", ErrDiskNotFound, diskName)
 	}
 
-	return client.Client.Download(diskName, groups[groupName][file])
+	return client.Client.PresignedURL(diskName, file, attachmentName, expiry)
+}
+
+// findClient Return the client of the disk; nil if the disk does not exist or exists in several environments
+func findClient(diskName string) *clientData {
+	var r *clientData
+
+	for _, client := range clients {
+		if _, found := client.Disks[diskName]; found {
+			if r != nil {
+				return nil
+			}
+
+			r = client
+		}
+	}
+
+	return r
 }
 
 func findGroups(
 	diskName string,
 	directoryName string,
 	fileName string,
-) (map[string][]*fs.FileInfo, int) {
-	disk := FindDisk(diskName)
+) (map[string][]*GroupFiles, int) {
+	disk, dirI, err := findDirectoryIndex(diskName, directoryName)
 
-	if disk == nil {
+	if err != nil {
 		return nil, 0
 	}
 
-	var dirI int
-
-	for dirI = 0; dirI < len(disk.Definition.Directories); dirI++ {
-		if disk.Definition.Directories[dirI].Alias == directoryName {
-			break
+	for fileI, fileDef := range disk.Definition.Directories[dirI].Files {
+		if fileDef.Alias == fileName {
+			return disk.files[dirI], fileI
 		}
 	}
 
-	if dirI >= len(disk.Definition.Directories) {
-		return nil, 0
-	}
+	return nil, 0
+}
 
-	dir := disk.Definition.Directories[dirI]
-	var fileI int
+// EnvironmentsOfDisk Return the sorted environments containing a disk with the name; see #48
+func EnvironmentsOfDisk(diskName string) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
 
-	for fileI = 0; fileI < len(dir.Files); fileI++ {
-		if dir.Files[fileI].Alias == fileName {
-			return disk.groups[dirI], fileI
+	var r []string
+
+	for _, client := range clients {
+		if disk, found := client.Disks[diskName]; found {
+			r = append(r, disk.Environment)
 		}
 	}
 
-	return nil, 0
+	sort.Strings(r)
+
+	return r
 }
 
+// FindDisk Return the disk with the name; nil if it does not exist or if the name is ambiguous
 func FindDisk(diskName string) *DiskData {
+	disk, _ := lookupDisk(diskName)
+
+	return disk
+}
+
+// lookupDisk Return the disk with the name; disks with the same name in several environments cannot be addressed by
+// their name only
+func lookupDisk(diskName string) (*DiskData, error) {
+	var r *DiskData
+
 	for _, client := range clients {
 		if disk, found := client.Disks[diskName]; found {
-			return disk
+			if r != nil {
+				return nil, fmt.Errorf("%w: '%s'", ErrDiskAmbiguous, diskName)
+			}
+
+			r = disk
 		}
 	}
 
-	return nil
+	if r == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrDiskNotFound, diskName)
+	}
+
+	return r, nil
 }
 
 // gatherDirUsageStats returns the count and total size of all objects inside a directory
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/audit"
+	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/metrics"
+	fs "github.com/dreitier/backmon/storage/fs"
+	"github.com/gorhill/cronexpr"
+	"github.com/stretchr/testify/assert"
+)
+
+// purgeClient records purged files; files with a lock status are locked
+type purgeClient struct {
+	locks       map[string]*fs.LockStatus
+	lockQueries int
+	deleted     []string
+}
+
+func (c *purgeClient) GetDiskNames() ([]string, error) { return nil, nil }
+
+func (c *purgeClient) GetFileNames(string, uint64) (*fs.DirectoryInfo, error) { return nil, nil }
+
+func (c *purgeClient) Download(string, *fs.FileInfo) (io.ReadCloser, int64, string, error) {
+	return nil, 0, "", errors.ErrUnsupported
+}
+
+func (c *purgeClient) Open(string, *fs.FileInfo) (io.ReadSeekCloser, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (c *purgeClient) PresignedURL(string, *fs.FileInfo, string, time.Duration) (string, error) {
+	return "", errors.ErrUnsupported
+}
+
+func (c *purgeClient) Delete(_ string, file *fs.FileInfo) error {
+	c.deleted = append(c.deleted, file.Name)
+	return nil
+}
+
+func (c *purgeClient) Move(string, *fs.FileInfo, string, string) error { return errors.ErrUnsupported }
+
+func (c *purgeClient) GetLockStatus(_ string, file *fs.FileInfo) (*fs.LockStatus, error) {
+	c.lockQueries++
+
+	if status, locked := c.locks[file.Name]; locked {
+		return status, nil
+	}
+
+	return &fs.LockStatus{}, nil
+}
+
+func newPurgeDisk(t *testing.T) *DiskData {
+	disk := &DiskData{Name: "purge-test", metrics: metrics.NewDisk("purge-test")}
+	t.Cleanup(disk.metrics.Drop)
+
+	return disk
+}
+
+func actions(entries []audit.Entry) map[string]string {
+	r := make(map[string]string)
+
+	for _, entry := range entries {
+		r[entry.Name] = entry.Action
+	}
+
+	return r
+}
+
+func Test_GH27_purge_reportsDryRunCandidatesOnce(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	list := dailyFiles(now.Add(-10*time.Hour), 5)
+	fileDef := &backup.FileDefinition{Schedule: cronexpr.MustParse("0 2 * * *"), RetentionCount: 3, Purge: true}
+	dirDef := &backup.Directory{Alias: "db"}
+	disk := newPurgeDisk(t)
+	client := &purgeClient{}
+
+	scan := func(list FileGroup) (FileGroup, []audit.Entry) {
+		disk.candidates.rotate()
+		remainder, _, _, entries := list.purge(dirDef, fileDef, "", disk, client, true, &config.PurgeGuardsConfiguration{}, now)
+		return remainder, entries
+	}
+
+	remainder, entries := scan(list)
+	assertion.Len(remainder, 5)
+	assertion.Equal(map[string]string{"2024-03-28": audit.ActionCandidate, "2024-03-27": audit.ActionCandidate}, actions(entries))
+
+	_, entries = scan(list)
+	assertion.Empty(entries)
+
+	// modified candidates are reported again
+	list[4].File.ModifiedAt = now
+	_, entries = scan(list)
+	assertion.Equal(map[string]string{"2024-03-27": audit.ActionCandidate}, actions(entries))
+
+	// candidates which have not been reported during the previous scan are forgotten
+	scan(nil)
+	_, entries = scan(list)
+	assertion.Len(entries, 2)
+	assertion.Empty(client.deleted)
+}
+
+func Test_GH29_archivePaths_excludesPurgeTargetsFromListing(t *testing.T) {
+	assertion := assert.New(t)
+	move := func(target string, targetDisk string) *backup.Definition {
+		return &backup.Definition{Directories: []*backup.Directory{{Files: []*backup.FileDefinition{
+			{Purge: true, PurgeAction: backup.PurgeActionMove, PurgeTarget: target, PurgeTargetDisk: targetDisk},
+			{Purge: true, PurgeAction: backup.PurgeActionDelete},
+		}}}}
+	}
+	client := &clientData{Disks: map[string]*DiskData{
+		"/backups": {Definition: move("archive/old/", "")},
+		"other":    {Definition: move("/backups/from-other", "/backups")},
+		"third":    {Definition: move("/elsewhere", "/backups")},
+		"fourth":   {},
+	}}
+
+	archives := client.archivePaths("/backups")
+	assertion.ElementsMatch([]string{"archive/old", "from-other"}, archives)
+	assertion.Empty(client.archivePaths("fourth"))
+
+	root := &fs.DirectoryInfo{SubDirs: map[string]*fs.DirectoryInfo{
+		"archive": {SubDirs: map[string]*fs.DirectoryInfo{"old": {}, "keep": {}}},
+		"db":      {},
+	}}
+
+	for _, archive := range append(archives, "missing/dir") {
+		excludeDirectory(root, archive)
+	}
+
+	assertion.Contains(root.SubDirs, "db")
+	assertion.NotContains(root.SubDirs["archive"].SubDirs, "old")
+	assertion.Contains(root.SubDirs["archive"].SubDirs, "keep")
+}
+
+func Test_GH30_purge_skipsLockedFiles(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	fileDef := &backup.FileDefinition{Schedule: cronexpr.MustParse("0 2 * * *"), RetentionCount: 2, Purge: true}
+	dirDef := &backup.Directory{Alias: "db"}
+	disk := newPurgeDisk(t)
+	client := &purgeClient{locks: map[string]*fs.LockStatus{
+		"2024-03-29": {Mode: "COMPLIANCE", RetainUntil: now.AddDate(0, 0, 1)},
+		"2024-03-28": {LegalHold: true},
+	}}
+
+	scan := func(list FileGroup) (FileGroup, []audit.Entry) {
+		disk.candidates.rotate()
+		disk.locks.rotate()
+		disk.locked.rotate()
+		remainder, _, _, entries := list.purge(dirDef, fileDef, "", disk, client, false, &config.PurgeGuardsConfiguration{}, now)
+		return remainder, entries
+	}
+
+	remainder, entries := scan(dailyFiles(now.Add(-10*time.Hour), 5))
+	assertion.Len(remainder, 4)
+	assertion.Equal(map[string]string{
+		"2024-03-29": audit.ActionLocked,
+		"2024-03-28": audit.ActionLocked,
+		"2024-03-27": audit.ActionDeleted,
+	}, actions(entries))
+	assertion.Equal([]string{"2024-03-27"}, client.deleted)
+	assertion.Equal(3, client.lockQueries)
+
+	// locked files are reported once; the retention lock is cached, the legal hold is checked again
+	remainder, entries = scan(remainder)
+	assertion.Len(remainder, 4)
+	assertion.Empty(entries)
+	assertion.Equal(4, client.lockQueries)
+
+	// released files are purged
+	delete(client.locks, "2024-03-28")
+	_, entries = scan(remainder)
+	assertion.Equal(map[string]string{"2024-03-28": audit.ActionDeleted}, actions(entries))
+
+	// the locks of files which are no purge candidates anymore are evicted
+	scan(nil)
+	scan(nil)
+	assertion.Nil(disk.locks.get("2024-03-29"))
+}
+
+func Test_GH48_purge_keepsFilesOfPausedDisks(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	fileDef := &backup.FileDefinition{Schedule: cronexpr.MustParse("0 2 * * *"), RetentionCount: 2, Purge: true}
+	dirDef := &backup.Directory{Alias: "db"}
+	disk := newPurgeDisk(t)
+	client := &purgeClient{}
+	previous := clients
+	t.Cleanup(func() { clients = previous })
+	clients = map[string]*clientData{"prod": {Disks: map[string]*DiskData{disk.Name: disk}}}
+
+	state, err := PausePurging("prod", disk.Name, true)
+	assertion.NoError(err)
+	assertion.Equal([]PurgeState{*state}, GetPurgeStates())
+
+	remainder, _, _, entries := dailyFiles(now.Add(-10*time.Hour), 5).purge(dirDef, fileDef, "", disk, client, false, &config.PurgeGuardsConfiguration{}, now)
+	assertion.Len(remainder, 5)
+	assertion.Empty(entries)
+	assertion.Empty(client.deleted)
+
+	_, err = PausePurging("prod", disk.Name, false)
+	assertion.NoError(err)
+
+	remainder, _, _, _ = dailyFiles(now.Add(-10*time.Hour), 5).purge(dirDef, fileDef, "", disk, client, false, &config.PurgeGuardsConfiguration{}, now)
+	assertion.Len(remainder, 2)
+	assertion.Len(client.deleted, 3)
+
+	_, err = PausePurging("prod", "unknown", true)
+	assertion.ErrorIs(err, ErrDiskNotFound)
+}
@@ -0,0 +1,448 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dreitier/backmon/storage"
+	termbox "github.com/nsf/termbox-go"
+)
+
+const (
+	help = "↑↓ select  ←→ collapse/expand  r rescan disk  R rescan all  p purge candidates  q quit"
+	// width of the labels in the details pane
+	labelWidth = 14
+)
+
+type segment struct {
+	text string
+	fg   termbox.Attribute
+}
+
+type line []segment
+
+func healthColor(health string) termbox.Attribute {
+	switch health {
+	case storage.StatusOk:
+		return termbox.ColorGreen
+	case storage.StatusLate:
+		return termbox.ColorYellow
+	case storage.StatusUnconfirmed:
+		return termbox.ColorCyan
+	case storage.StatusFailed, storage.StatusMissing:
+		return termbox.ColorRed | termbox.AttrBold
+	case storage.StatusDefinitionsMissing:
+		return termbox.ColorMagenta
+	}
+
+	return termbox.ColorDefault
+}
+
+func title(text string) line {
+	return line{{text, termbox.ColorDefault | termbox.AttrBold}}
+}
+
+func field(label string, value string) line {
+	return line{{fmt.Sprintf("%-*s", labelWidth, label), termbox.ColorDefault}, {value, termbox.ColorDefault}}
+}
+
+func healthField(health string) line {
+	return line{{fmt.Sprintf("%-*s", labelWidth, "Health"), termbox.ColorDefault}, {health, healthColor(health)}}
+}
+
+// entry is a line of a list of children, prefixed with their health
+func entry(health string, text string) line {
+	return line{{"  ● ", healthColor(health)}, {text, termbox.ColorDefault}}
+}
+
+func formatBytes(bytes int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(bytes)
+	i := 0
+
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// formatAge returns the distance between the time and now, e.g. "3h 5m ago" or "in 20m"
+func formatAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	d := now.Sub(t)
+	suffix := " ago"
+	prefix := ""
+
+	if d < 0 {
+		d = -d
+		suffix = ""
+		prefix = "in "
+	}
+
+	seconds := int64(d.Round(time.Second).Seconds())
+	units := []struct {
+		name   string
+		length int64
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}}
+	var parts []string
+
+	for _, unit := range units {
+		if seconds >= unit.length || (len(parts) == 0 && unit.length == 1) {
+			parts = append(parts, fmt.Sprintf("%d%s", seconds/unit.length, unit.name))
+			seconds %= unit.length
+		}
+
+		if len(parts) == 2 {
+			break
+		}
+	}
+
+	return prefix + strings.Join(parts, " ") + suffix
+}
+
+func formatTime(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%s (%s)", t.Local().Format(time.DateTime), formatAge(t, now))
+}
+
+func (d *dashboard) treeHeight() int {
+	_, h := termbox.Size()
+
+	return max(1, h-d.logHeight()-3)
+}
+
+func (d *dashboard) logHeight() int {
+	_, h := termbox.Size()
+
+	return max(3, h/4)
+}
+
+func (d *dashboard) draw() {
+	d.terminal.Lock()
+	defer d.terminal.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	_ = termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+
+	w, h := termbox.Size()
+	treeWidth := min(w, max(24, w*2/5))
+	treeHeight := d.treeHeight()
+	now := time.Now()
+
+	// header
+	fill(0, 0, w, termbox.ColorDefault|termbox.AttrReverse)
+	x := text(0, 0, w, " backmon ", termbox.ColorDefault|termbox.AttrReverse|termbox.AttrBold)
+	text(x+1, 0, w, help, termbox.ColorDefault|termbox.AttrReverse)
+
+	// tree
+	if d.selected < d.offset {
+		d.offset = d.selected
+	} else if d.selected >= d.offset+treeHeight {
+		d.offset = d.selected - treeHeight + 1
+	}
+
+	if len(d.visible) == 0 {
+		text(1, 1, treeWidth, "Loading...", termbox.ColorDefault)
+	}
+
+	for i := 0; i < treeHeight && d.offset+i < len(d.visible); i++ {
+		d.drawNode(d.visible[d.offset+i], d.offset+i == d.selected, 1+i, treeWidth)
+	}
+
+	for y := 1; y <= treeHeight; y++ {
+		termbox.SetCell(treeWidth, y, '│', termbox.ColorDefault, termbox.ColorDefault)
+	}
+
+	// details
+	var lines []line
+
+	if node := d.current(); node != nil {
+		if d.showCandidates {
+			lines = d.candidateLines(node, now)
+		} else {
+			lines = details(node, now)
+		}
+	}
+
+	if len(d.errors) > 0 {
+		lines = append(lines, nil, title("Recent scan errors"))
+
+		for i := len(d.errors) - 1; i >= 0; i-- {
+			scan := d.errors[i]
+			lines = append(lines, line{
+				{scan.Time.Local().Format(time.DateTime) + " ", termbox.ColorDefault},
+				{strings.Trim(scan.Environment+"/"+scan.Disk, "/") + ": " + scan.Error, termbox.ColorRed},
+			})
+		}
+	}
+
+	for i := 0; i < treeHeight && i < len(lines); i++ {
+		x := treeWidth + 2
+
+		for _, segment := range lines[i] {
+			x = text(x, 1+i, w, segment.text, segment.fg)
+		}
+	}
+
+	// log
+	logTop := treeHeight + 1
+	text(0, logTop, w, "── Log "+strings.Repeat("─", max(0, w-7)), termbox.ColorDefault)
+
+	for i, logLine := range d.logs.tail(h - logTop - 2) {
+		text(0, logTop+1+i, w, logLine, termbox.ColorDefault)
+	}
+
+	// status line
+	text(0, h-1, w, d.message, termbox.ColorDefault|termbox.AttrBold)
+
+	_ = termbox.Flush()
+}
+
+func (d *dashboard) drawNode(n *node, selected bool, y int, width int) {
+	fg := termbox.ColorDefault
+
+	if selected {
+		fg |= termbox.AttrReverse
+		fill(0, y, width, fg)
+	}
+
+	marker := "  "
+
+	if len(n.children) > 0 {
+		marker = "▸ "
+
+		if d.expanded[n.key()] {
+			marker = "▾ "
+		}
+	}
+
+	name := n.name
+
+	if name == "" {
+		name = "(default)"
+	}
+
+	x := text(n.depth()*2, y, width, marker, fg)
+	x = text(x, y, width, "● ", healthColor(n.health)|(fg&termbox.AttrReverse))
+	text(x, y, width, name, fg)
+}
+
+// fill sets the background of the line up to width
+func fill(x int, y int, width int, attribute termbox.Attribute) {
+	for ; x < width; x++ {
+		termbox.SetCell(x, y, ' ', attribute, termbox.ColorDefault)
+	}
+}
+
+// text draws the string, truncated at maxX, and returns the column after it
+func text(x int, y int, maxX int, s string, fg termbox.Attribute) int {
+	for _, r := range s {
+		if x >= maxX {
+			break
+		}
+
+		termbox.SetCell(x, y, r, fg, termbox.ColorDefault)
+		x++
+	}
+
+	return x
+}
+
+// details describes the resource of the node
+func details(n *node, now time.Time) []line {
+	switch n.kind {
+	case kindEnvironment:
+		r := []line{title("Environment " + n.name), field("Disks", fmt.Sprint(len(n.children)))}
+
+		for _, child := range n.children {
+			r = append(r, entry(child.health, child.name))
+		}
+
+		return r
+	case kindDisk:
+		disk := n.disk
+		r := []line{title("Disk " + disk.Name), healthField(disk.Health), field("Environment", disk.Environment)}
+
+		if disk.Quota > 0 {
+			r = append(r, field("Quota", formatBytes(int64(disk.Quota))))
+		}
+
+		if scan := disk.LastScan; scan != nil {
+			r = append(r,
+				field("Last scan", formatTime(scan.Time, now)),
+				field("Duration", fmt.Sprintf("%.1fs", scan.DurationSeconds)),
+				field("Files", fmt.Sprintf("%d (%s)", scan.Files, formatBytes(int64(scan.Bytes)))),
+			)
+
+			if scan.Error != "" {
+				r = append(r, line{{fmt.Sprintf("%-*s", labelWidth, "Error"), termbox.ColorDefault}, {scan.Error, termbox.ColorRed}})
+			}
+		} else {
+			r = append(r, field("Last scan", "not scanned yet"))
+		}
+
+		r = append(r, nil, title("Directories"))
+
+		for _, child := range n.children {
+			r = append(r, entry(child.health, child.name))
+		}
+
+		return r
+	case kindDirectory:
+		dir := n.directory
+		r := []line{
+			title("Directory " + dir.Alias),
+			healthField(dir.Health),
+			field("Pattern", dir.Pattern),
+			field("Variables", strings.Join(dir.Variables, ", ")),
+			nil,
+			title("File definitions"),
+		}
+
+		for _, child := range n.children {
+			r = append(r, entry(child.health, child.name+" ("+child.file.Pattern+")"))
+		}
+
+		return r
+	case kindFile:
+		file := n.file
+		purge := "disabled"
+
+		if file.Purge {
+			purge = "enabled (" + file.PurgeAction + ")"
+		}
+
+		r := []line{
+			title("File definition " + file.Alias),
+			healthField(file.Health),
+			field("Pattern", file.Pattern),
+			field("Schedule", file.Schedule),
+			field("Expected at", formatTime(file.ExpectedAt, now)),
+			field("Next run", formatTime(file.NextRunAt, now)),
+			field("Retention", fmt.Sprintf("count %d, age %s, daily %d, weekly %d, monthly %d, yearly %d",
+				file.Retention.Count, time.Duration(file.Retention.AgeSeconds*float64(time.Second)), file.Retention.Daily,
+				file.Retention.Weekly, file.Retention.Monthly, file.Retention.Yearly)),
+			field("Purge", purge),
+			nil,
+			title("Groups"),
+		}
+
+		for _, child := range n.children {
+			latest := "no backup file"
+
+			if child.group.Latest != nil {
+				latest = "latest " + formatAge(child.group.Latest.SortTime, now)
+			}
+
+			name := child.name
+
+			if name == "" {
+				name = "(default)"
+			}
+
+			r = append(r, entry(child.health, fmt.Sprintf("%s: %s, %d files", name, latest, child.group.FileCount)))
+		}
+
+		return r
+	case kindGroup:
+		group := n.group
+		r := []line{
+			title("Group " + group.Name),
+			healthField(group.Health),
+			field("Files", fmt.Sprintf("%d (%d young)", group.FileCount, group.YoungCount)),
+			nil,
+			title("Latest file"),
+		}
+
+		latest := group.Latest
+
+		if latest == nil {
+			return append(r, field("", "No backup file has been found."))
+		}
+
+		r = append(r,
+			field("Name", latest.Name),
+			field("Parent", latest.Parent),
+			field("Size", formatBytes(latest.Size)),
+			field("Sort time", formatTime(latest.SortTime, now)),
+			field("Born", formatTime(latest.BornAt, now)),
+			field("Modified", formatTime(latest.ModifiedAt, now)),
+			field("Archived", formatTime(latest.ArchivedAt, now)),
+		)
+
+		if latest.InterpolatedAt != nil {
+			r = append(r, field("Interpolated", formatTime(*latest.InterpolatedAt, now)))
+		}
+
+		if latest.Checksum != "" {
+			r = append(r, field("Checksum", latest.Checksum))
+		}
+
+		if latest.StorageClass != "" {
+			r = append(r, field("Storage class", latest.StorageClass))
+		}
+
+		return r
+	}
+
+	return nil
+}
+
+func (d *dashboard) candidateLines(n *node, now time.Time) []line {
+	r := []line{title("Purge candidates of " + n.name)}
+
+	if n.kind == kindEnvironment {
+		return append(r, field("", "Select a disk, directory, file definition or group."))
+	}
+
+	if d.candidates == nil || d.candidates.key != n.key() {
+		return append(r, field("", "Loading..."))
+	}
+
+	if d.candidates.err != nil {
+		return append(r, line{{d.candidates.err.Error(), termbox.ColorRed}})
+	}
+
+	if len(d.candidates.items) == 0 {
+		return append(r, field("", "No files exceed the retention policy."))
+	}
+
+	var total int64
+
+	for _, candidate := range d.candidates.items {
+		total += candidate.Size
+	}
+
+	r = append(r, field("Files", fmt.Sprintf("%d (%s)", len(d.candidates.items), formatBytes(total))), nil)
+
+	for _, candidate := range d.candidates.items {
+		description := fmt.Sprintf("%s/%s/%s: %s, %s, %s", candidate.Directory, candidate.File, candidate.Group,
+			candidate.Name, formatBytes(candidate.Size), formatAge(candidate.SortTime, now))
+		fg := termbox.ColorDefault
+
+		if !candidate.Purge {
+			description += " (purging disabled)"
+			fg = termbox.ColorBlue
+		}
+
+		r = append(r, line{{description, fg}})
+	}
+
+	return r
+}
@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// amount of log lines kept for the log pane
+const logLines = 500
+
+// logBuffer keeps the latest log lines while the dashboard is shown. Once detached, e.g. on exit or on a fatal error,
+// all further lines are written to the fallback.
+type logBuffer struct {
+	mutex    sync.Mutex
+	lines    []string
+	partial  string
+	fallback io.Writer
+	detached bool
+	// called after new lines have been written
+	onWrite func()
+}
+
+func newLogBuffer(fallback io.Writer) *logBuffer {
+	return &logBuffer{fallback: fallback}
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+
+	if b.detached {
+		b.mutex.Unlock()
+		return b.fallback.Write(p)
+	}
+
+	data := b.partial + string(p)
+	lines := strings.Split(data, "\n")
+	b.partial = lines[len(lines)-1]
+	b.lines = append(b.lines, lines[:len(lines)-1]...)
+
+	if len(b.lines) > logLines {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-logLines:]...)
+	}
+
+	onWrite := b.onWrite
+	b.mutex.Unlock()
+
+	if onWrite != nil {
+		onWrite()
+	}
+
+	return len(p), nil
+}
+
+// tail returns the latest n lines, oldest first
+func (b *logBuffer) tail(n int) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if n > len(b.lines) {
+		n = len(b.lines)
+	}
+
+	return append([]string(nil), b.lines[len(b.lines)-n:]...)
+}
+
+// detach writes the latest n lines to the fallback, so that they are not lost when the dashboard is closed
+func (b *logBuffer) detach(n int) {
+	lines := b.tail(n)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.detached {
+		return
+	}
+
+	b.detached = true
+
+	for _, line := range lines {
+		_, _ = io.WriteString(b.fallback, line+"\n")
+	}
+}
+
+// fatalHook closes the dashboard before logrus exits the process, otherwise the fatal message would not be visible;
+// see #12
+type fatalHook struct {
+	close func()
+}
+
+func (h *fatalHook) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel}
+}
+
+func (h *fatalHook) Fire(*log.Entry) error {
+	h.close()
+	return nil
+}
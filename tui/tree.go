@@ -0,0 +1,152 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/dreitier/backmon/storage"
+)
+
+type nodeKind int
+
+const (
+	kindEnvironment nodeKind = iota
+	kindDisk
+	kindDirectory
+	kindFile
+	kindGroup
+)
+
+// node of the tree of environments, disks, directories, file definitions and groups. Depending on its kind, the
+// corresponding resource is set.
+type node struct {
+	kind     nodeKind
+	name     string
+	health   string
+	parent   *node
+	children []*node
+
+	environment *storage.EnvironmentResource
+	disk        *storage.DiskResource
+	directory   *storage.DirectoryResource
+	file        *storage.FileDefinitionResource
+	group       *storage.GroupResource
+}
+
+// key identifies the node across reloads
+func (n *node) key() string {
+	var path []string
+
+	for current := n; current != nil; current = current.parent {
+		path = append([]string{current.name}, path...)
+	}
+
+	return strings.Join(path, "\x00")
+}
+
+func (n *node) depth() int {
+	r := 0
+
+	for current := n.parent; current != nil; current = current.parent {
+		r++
+	}
+
+	return r
+}
+
+// scope returns the environment, disk, directory, file definition and group the node belongs to; names below the
+// node's kind are empty
+func (n *node) scope() (environment string, disk string, directory string, file string, group string) {
+	for current := n; current != nil; current = current.parent {
+		switch current.kind {
+		case kindEnvironment:
+			environment = current.name
+		case kindDisk:
+			disk = current.name
+		case kindDirectory:
+			directory = current.name
+		case kindFile:
+			file = current.name
+		case kindGroup:
+			group = current.name
+		}
+	}
+
+	return
+}
+
+func (n *node) add(child *node) {
+	child.parent = n
+	n.children = append(n.children, child)
+
+	if storage.Severity(child.health) > storage.Severity(n.health) {
+		n.health = child.health
+	}
+}
+
+// loadTree describes all environments with their disks, directories, file definitions and groups
+func loadTree() []*node {
+	var r []*node
+
+	for _, environment := range storage.DescribeEnvironments() {
+		envNode := &node{kind: kindEnvironment, name: environment.Name, health: storage.StatusOk, environment: &environment}
+
+		for _, diskName := range environment.Disks {
+			disk, err := storage.DescribeDisk(diskName)
+
+			if err != nil {
+				continue
+			}
+
+			diskNode := &node{kind: kindDisk, name: disk.Name, health: disk.Health, disk: disk}
+
+			for _, dirSummary := range disk.Directories {
+				dir, err := storage.DescribeDirectory(disk.Name, dirSummary.Alias)
+
+				if err != nil {
+					continue
+				}
+
+				diskNode.add(newDirectoryNode(dir))
+			}
+
+			envNode.add(diskNode)
+		}
+
+		r = append(r, envNode)
+	}
+
+	return r
+}
+
+func newDirectoryNode(dir *storage.DirectoryResource) *node {
+	r := &node{kind: kindDirectory, name: dir.Alias, health: dir.Health, directory: dir}
+
+	for i := range dir.Files {
+		file := &dir.Files[i]
+		fileNode := &node{kind: kindFile, name: file.Alias, health: file.Health, file: file}
+
+		for k := range file.Groups {
+			group := &file.Groups[k]
+			fileNode.add(&node{kind: kindGroup, name: group.Name, health: group.Health, group: group})
+		}
+
+		r.add(fileNode)
+	}
+
+	return r
+}
+
+// flatten returns the visible nodes, depth-first; children are only visible if their parent is expanded
+func flatten(nodes []*node, expanded map[string]bool) []*node {
+	var r []*node
+
+	for _, n := range nodes {
+		r = append(r, n)
+
+		if expanded[n.key()] {
+			r = append(r, flatten(n.children, expanded)...)
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,380 @@
+package tui
+
+// Interactive terminal dashboard for operators running backmon directly on a backup host; see #44. It replaces the raw
+// keypress loop of #7: the tree of environments, disks, directories, file definitions and groups can be navigated with
+// the arrow keys, while the details of the selected node, recent scan errors and the log are shown beside it.
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dreitier/backmon/history"
+	"github.com/dreitier/backmon/storage"
+	termbox "github.com/nsf/termbox-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the resources are reloaded and the ages refreshed in this interval
+	refreshInterval = 5 * time.Second
+	// amount of scan errors shown
+	scanErrors = 10
+	// lines of the log written to the terminal after the dashboard has been closed
+	logLinesOnExit = 20
+)
+
+type dashboard struct {
+	// guards the terminal, which is also closed by the fatal hook
+	terminal sync.Mutex
+	closed   bool
+
+	logs     *logBuffer
+	roots    []*node
+	visible  []*node
+	expanded map[string]bool
+	selected int
+	// first visible line of the tree
+	offset int
+
+	errors []history.Scan
+	// purge candidates of the selected node; shown instead of its details
+	showCandidates bool
+	candidates     *purgeCandidates
+	// shown in the status line, e.g. after a rescan has been triggered
+	message string
+
+	events chan termbox.Event
+	redraw chan struct{}
+	scans  chan []history.Scan
+	// the resources are loaded in the background, as the storage is locked while scanning
+	reloads chan struct{}
+	trees   chan []*node
+	loaded  chan *purgeCandidates
+}
+
+type purgeCandidates struct {
+	// key of the node whose candidates have been loaded
+	key   string
+	items []storage.PurgeCandidate
+	err   error
+}
+
+// Start shows the dashboard until the user quits, which exits the process
+func Start() error {
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+
+	termbox.SetInputMode(termbox.InputEsc)
+
+	d := &dashboard{
+		logs:     newLogBuffer(os.Stderr),
+		expanded: map[string]bool{},
+		events:   make(chan termbox.Event),
+		redraw:   make(chan struct{}, 1),
+		scans:    make(chan []history.Scan),
+		reloads:  make(chan struct{}, 1),
+		trees:    make(chan []*node),
+		loaded:   make(chan *purgeCandidates),
+	}
+
+	d.logs.onWrite = func() { notify(d.redraw) }
+	log.SetOutput(d.logs)
+	log.AddHook(&fatalHook{close: d.close})
+	storage.OnUpdated(func() { notify(d.reloads) })
+
+	go d.poll()
+	go d.load()
+	go d.loop()
+
+	notify(d.reloads)
+
+	return nil
+}
+
+// notify signals the channel without blocking; pending signals are coalesced
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dashboard) poll() {
+	for {
+		d.events <- termbox.PollEvent()
+	}
+}
+
+func (d *dashboard) load() {
+	for range d.reloads {
+		d.trees <- loadTree()
+	}
+}
+
+func (d *dashboard) loop() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev := <-d.events:
+			switch ev.Type {
+			case termbox.EventKey:
+				d.handleKey(ev)
+			case termbox.EventError:
+				d.message = fmt.Sprintf("Terminal error: %s", ev.Err)
+			}
+		case roots := <-d.trees:
+			d.apply(roots)
+		case loaded := <-d.loaded:
+			if node := d.current(); node != nil && node.key() == loaded.key {
+				d.candidates = loaded
+			}
+		case results := <-d.scans:
+			d.message = fmt.Sprintf("Scan of %d disk(s) finished", len(results))
+			d.recordScanErrors(results)
+		case <-d.redraw:
+		case <-ticker.C:
+			notify(d.reloads)
+		}
+
+		d.draw()
+	}
+}
+
+// close restores the terminal; the log is written to stderr from now on
+func (d *dashboard) close() {
+	d.terminal.Lock()
+	defer d.terminal.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	d.closed = true
+	termbox.Close()
+	d.logs.detach(logLinesOnExit)
+}
+
+func (d *dashboard) quit() {
+	log.Printf("Exiting...")
+	d.close()
+	history.Close()
+	os.Exit(0)
+}
+
+// apply replaces the tree, keeping the expanded nodes and the selection
+func (d *dashboard) apply(roots []*node) {
+	var selectedKey string
+
+	if node := d.current(); node != nil {
+		selectedKey = node.key()
+	}
+
+	if d.roots == nil {
+		for _, root := range roots {
+			d.expanded[root.key()] = true
+		}
+	}
+
+	d.roots = roots
+
+	for _, root := range d.roots {
+		for _, disk := range root.children {
+			if disk.disk.LastScan != nil {
+				d.recordScanErrors([]history.Scan{*disk.disk.LastScan})
+			}
+		}
+	}
+
+	d.visible = flatten(d.roots, d.expanded)
+	d.selected = max(0, min(d.selected, len(d.visible)-1))
+
+	for i, node := range d.visible {
+		if node.key() == selectedKey {
+			d.selected = i
+			break
+		}
+	}
+
+	if d.showCandidates {
+		d.loadCandidates()
+	}
+}
+
+// recordScanErrors keeps the latest failed scans, each only once
+func (d *dashboard) recordScanErrors(scans []history.Scan) {
+	for _, scan := range scans {
+		if scan.Error == "" || d.hasScanError(scan) {
+			continue
+		}
+
+		d.errors = append(d.errors, scan)
+
+		if len(d.errors) > scanErrors {
+			d.errors = d.errors[len(d.errors)-scanErrors:]
+		}
+	}
+}
+
+func (d *dashboard) hasScanError(scan history.Scan) bool {
+	for _, known := range d.errors {
+		if known.Environment == scan.Environment && known.Disk == scan.Disk && known.Time.Equal(scan.Time) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *dashboard) current() *node {
+	if d.selected < 0 || d.selected >= len(d.visible) {
+		return nil
+	}
+
+	return d.visible[d.selected]
+}
+
+func (d *dashboard) handleKey(ev termbox.Event) {
+	switch {
+	case ev.Key == termbox.KeyEsc || ev.Key == termbox.KeyCtrlC || ev.Ch == 'q':
+		d.quit()
+	case ev.Key == termbox.KeyArrowUp || ev.Ch == 'k':
+		d.move(-1)
+	case ev.Key == termbox.KeyArrowDown || ev.Ch == 'j':
+		d.move(1)
+	case ev.Key == termbox.KeyPgup:
+		d.move(-d.treeHeight())
+	case ev.Key == termbox.KeyPgdn:
+		d.move(d.treeHeight())
+	case ev.Key == termbox.KeyHome || ev.Ch == 'g':
+		d.move(-len(d.visible))
+	case ev.Key == termbox.KeyEnd || ev.Ch == 'G':
+		d.move(len(d.visible))
+	case ev.Key == termbox.KeyArrowRight || ev.Key == termbox.KeyEnter || ev.Key == termbox.KeySpace || ev.Ch == 'l':
+		d.expand()
+	case ev.Key == termbox.KeyArrowLeft || ev.Ch == 'h':
+		d.collapse()
+	case ev.Ch == 'r':
+		d.rescan(d.current())
+	case ev.Key == termbox.KeyCtrlR || ev.Ch == 'R':
+		d.rescan(nil)
+	case ev.Ch == 'p':
+		d.showCandidates = !d.showCandidates
+
+		if d.showCandidates {
+			d.loadCandidates()
+		}
+	}
+}
+
+func (d *dashboard) move(delta int) {
+	previous := d.selected
+	d.selected = max(0, min(d.selected+delta, len(d.visible)-1))
+
+	if d.showCandidates && d.selected != previous {
+		d.loadCandidates()
+	}
+}
+
+// expand shows the children of the selected node; if they are already shown, the first child is selected
+func (d *dashboard) expand() {
+	node := d.current()
+
+	if node == nil || len(node.children) == 0 {
+		return
+	}
+
+	if d.expanded[node.key()] {
+		d.move(1)
+		return
+	}
+
+	d.expanded[node.key()] = true
+	d.visible = flatten(d.roots, d.expanded)
+}
+
+// collapse hides the children of the selected node; if they are already hidden, the parent is selected
+func (d *dashboard) collapse() {
+	node := d.current()
+
+	if node == nil {
+		return
+	}
+
+	if d.expanded[node.key()] && len(node.children) > 0 {
+		delete(d.expanded, node.key())
+		d.visible = flatten(d.roots, d.expanded)
+		return
+	}
+
+	for i, candidate := range d.visible {
+		if candidate == node.parent {
+			d.move(i - d.selected)
+			return
+		}
+	}
+}
+
+// rescan triggers a scan of the disk of the node, of all disks of an environment or, without a node, of all disks
+func (d *dashboard) rescan(node *node) {
+	var environment, disk string
+
+	if node != nil {
+		environment, disk, _, _, _ = node.scope()
+	}
+
+	switch {
+	case disk != "":
+		d.message = fmt.Sprintf("Scanning disk '%s'...", disk)
+	case environment != "":
+		d.message = fmt.Sprintf("Scanning all disks of environment '%s'...", environment)
+	default:
+		d.message = "Scanning all disks..."
+	}
+
+	trigger := storage.TriggerScan(environment, disk)
+
+	go func() {
+		d.scans <- trigger.Wait()
+	}()
+}
+
+// loadCandidates loads the purge candidates of the selected node in the background
+func (d *dashboard) loadCandidates() {
+	node := d.current()
+
+	if node == nil {
+		d.candidates = nil
+		return
+	}
+
+	key := node.key()
+
+	if d.candidates != nil && d.candidates.key != key {
+		d.candidates = nil
+	}
+
+	if node.kind == kindEnvironment {
+		d.candidates = &purgeCandidates{key: key}
+		return
+	}
+
+	_, disk, directory, file, group := node.scope()
+
+	go func() {
+		r := &purgeCandidates{key: key}
+		items, err := storage.PurgeCandidates(disk, directory, file)
+		r.err = err
+
+		for _, item := range items {
+			if group == "" || item.Group == group {
+				r.items = append(r.items, item)
+			}
+		}
+
+		d.loaded <- r
+	}()
+}
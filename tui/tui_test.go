@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/history"
+	"github.com/dreitier/backmon/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTree() []*node {
+	env := &node{kind: kindEnvironment, name: "prod", health: storage.StatusOk}
+	disk := &node{kind: kindDisk, name: "bucket", health: storage.StatusOk, disk: &storage.DiskResource{Name: "bucket"}}
+	dir := &node{kind: kindDirectory, name: "db", health: storage.StatusOk}
+	file := &node{kind: kindFile, name: "dump", health: storage.StatusOk}
+	file.add(&node{kind: kindGroup, name: "a", health: storage.StatusOk})
+	file.add(&node{kind: kindGroup, name: "b", health: storage.StatusLate})
+	dir.add(file)
+	disk.add(dir)
+	env.add(disk)
+
+	return []*node{env}
+}
+
+func Test_GH44_add_propagatesMostSevereHealth(t *testing.T) {
+	assertion := assert.New(t)
+	env := testTree()[0]
+
+	assertion.Equal(storage.StatusLate, env.health)
+	assertion.Equal(storage.StatusLate, env.children[0].children[0].health)
+	assertion.Equal(4, env.children[0].children[0].children[0].children[1].depth())
+}
+
+func Test_GH44_scope_returnsNamesOfAncestors(t *testing.T) {
+	assertion := assert.New(t)
+	group := testTree()[0].children[0].children[0].children[0].children[1]
+
+	environment, disk, directory, file, name := group.scope()
+
+	assertion.Equal([]string{"prod", "bucket", "db", "dump", "b"}, []string{environment, disk, directory, file, name})
+
+	environment, disk, directory, file, name = group.parent.parent.parent.scope()
+
+	assertion.Equal([]string{"prod", "bucket", "", "", ""}, []string{environment, disk, directory, file, name})
+}
+
+func Test_GH44_flatten_onlyIncludesChildrenOfExpandedNodes(t *testing.T) {
+	assertion := assert.New(t)
+	roots := testTree()
+	env := roots[0]
+	disk := env.children[0]
+	dir := disk.children[0]
+
+	assertion.Len(flatten(roots, map[string]bool{}), 1)
+	// the directory is collapsed, so its files are hidden
+	assertion.Len(flatten(roots, map[string]bool{env.key(): true, disk.key(): true}), 3)
+	// children of collapsed ancestors stay hidden
+	assertion.Len(flatten(roots, map[string]bool{env.key(): true, dir.key(): true}), 2)
+	assertion.Len(flatten(roots, map[string]bool{env.key(): true, disk.key(): true, dir.key(): true, dir.children[0].key(): true}), 6)
+}
+
+func Test_GH44_apply_keepsSelectionAcrossReloads(t *testing.T) {
+	assertion := assert.New(t)
+	sut := &dashboard{expanded: map[string]bool{}}
+
+	// environments are expanded initially
+	sut.apply(testTree())
+	assertion.Len(sut.visible, 2)
+
+	sut.expand()
+	assertion.Equal("bucket", sut.current().name)
+
+	sut.apply(testTree())
+	assertion.Equal("bucket", sut.current().name)
+
+	sut.collapse()
+	assertion.Equal("prod", sut.current().name)
+
+	sut.collapse()
+	assertion.Len(sut.visible, 1)
+}
+
+func Test_GH44_recordScanErrors_keepsEachErrorOnce(t *testing.T) {
+	assertion := assert.New(t)
+	sut := &dashboard{}
+	now := time.Now()
+
+	for i := 0; i < scanErrors+5; i++ {
+		scan := history.Scan{Time: now.Add(time.Duration(i) * time.Second), Disk: "bucket", Error: "access denied"}
+		sut.recordScanErrors([]history.Scan{scan, scan, {Time: now, Disk: "other"}})
+	}
+
+	assertion.Len(sut.errors, scanErrors)
+	assertion.Equal(now.Add(time.Duration(scanErrors+4)*time.Second), sut.errors[scanErrors-1].Time)
+}
+
+func Test_GH44_logBuffer_keepsLinesUntilDetached(t *testing.T) {
+	assertion := assert.New(t)
+	fallback := &bytes.Buffer{}
+	sut := newLogBuffer(fallback)
+	writes := 0
+	sut.onWrite = func() { writes++ }
+
+	_, _ = sut.Write([]byte("first\nsec"))
+	_, _ = sut.Write([]byte("ond\nthird\n"))
+
+	assertion.Equal([]string{"first", "second", "third"}, sut.tail(5))
+	assertion.Equal([]string{"third"}, sut.tail(1))
+	assertion.Equal(2, writes)
+	assertion.Empty(fallback.String())
+
+	sut.detach(2)
+	_, _ = sut.Write([]byte("fourth\n"))
+
+	assertion.Equal("second\nthird\nfourth\n", fallback.String())
+}
+
+func Test_GH44_formatAge(t *testing.T) {
+	assertion := assert.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	assertion.Equal("-", formatAge(time.Time{}, now))
+	assertion.Equal("0s ago", formatAge(now, now))
+	assertion.Equal("3h 5m ago", formatAge(now.Add(-3*time.Hour-5*time.Minute-10*time.Second), now))
+	assertion.Equal("2d 1h ago", formatAge(now.Add(-49*time.Hour), now))
+	assertion.Equal("in 20m", formatAge(now.Add(20*time.Minute), now))
+	assertion.Equal("512 B", formatBytes(512))
+	assertion.Equal("1.5 KiB", formatBytes(1536))
+}
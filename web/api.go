@@ -1,16 +1,38 @@
 package web
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"github.com/dreitier/backmon/audit"
+	"github.com/dreitier/backmon/auth"
 	"github.com/dreitier/backmon/backup"
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/events"
+	"github.com/dreitier/backmon/history"
+	"github.com/dreitier/backmon/notify"
+	"github.com/dreitier/backmon/report"
 	"github.com/dreitier/backmon/storage"
+	fs "github.com/dreitier/backmon/storage/fs"
+	log "github.com/sirupsen/logrus"
 	"io"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
 )
 
-func GetDisks(w http.ResponseWriter) {
-	disks := storage.GetDisks()
+func GetDisks(w http.ResponseWriter, principal *auth.Principal) {
+	disks := []*storage.DiskData{}
+
+	for _, disk := range storage.GetDisks() {
+		if principal.CanAccess(disk.Environment, disk.Name) {
+			disks = append(disks, disk)
+		}
+	}
 
 	writeData(w, disks)
 }
@@ -55,35 +77,495 @@ func GetVariations(
 	writeData(w, filenames)
 }
 
+// presigned URLs only need to be valid until the client has followed the redirect
+const presignExpiry = 5 * time.Minute
+
 func Download(
 	w http.ResponseWriter,
+	r *http.Request,
+	downloads *config.DownloadsConfiguration,
 	diskName string,
 	directoryName string,
 	fileName string,
 	variation string,
 ) {
-	data, length, contentType, err := storage.Download(diskName, directoryName, fileName, variation)
+	file, err := storage.FindBackup(diskName, directoryName, fileName, variation, "", time.Time{})
 	if err != nil {
 		groupNotFound(w, variation)
+		return
+	}
+
+	serveBackup(w, r, downloads, diskName, fileName, file)
+}
+
+// GetBackups lists all retained backups of a group, newest first; see #45
+func GetBackups(
+	w http.ResponseWriter,
+	diskName string,
+	directoryName string,
+	fileName string,
+	group string,
+) {
+	described, err := storage.DescribeGroup(diskName, directoryName, fileName, group)
+	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
 
+	writeData(w, described.Files)
+}
+
+// DownloadBackup downloads a retained backup of a group by its name or, if no backup has that name, by a timestamp; see
+// #45
+func DownloadBackup(
+	w http.ResponseWriter,
+	r *http.Request,
+	downloads *config.DownloadsConfiguration,
+	diskName string,
+	directoryName string,
+	fileName string,
+	group string,
+	name string,
+) {
+	// the timestamp is optional, as most names are no timestamps
+	at, _ := parseTimestamp(name)
+
+	file, err := storage.FindBackup(diskName, directoryName, fileName, group, name, at)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	serveBackup(w, r, downloads, diskName, file.Name, file)
+}
+
+// serveBackup serves the backup as attachment. Range, conditional and HEAD requests are handled by http.ServeContent
+// with the ETag and modification time of the file; see #46. With `s3_redirect`, GET requests of S3 objects are
+// redirected to a presigned URL instead; see #47.
+func serveBackup(
+	w http.ResponseWriter,
+	r *http.Request,
+	downloads *config.DownloadsConfiguration,
+	diskName string,
+	attachmentName string,
+	file *fs.FileInfo,
+) {
+	if downloads.S3Redirect && r.Method == http.MethodGet {
+		location, err := storage.PresignBackup(diskName, file, attachmentName, presignExpiry)
+
+		if err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, location, http.StatusFound)
+			return
+		}
+
+		// local files are always streamed
+		if !errors.Is(err, errors.ErrUnsupported) {
+			log.Warnf("Could not presign '%s' of disk '%s', streaming it instead: %s", file.Name, diskName, err)
+		}
+	}
+
+	content, err := storage.OpenBackup(diskName, file)
+	if errors.Is(err, os.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	defer func() { _ = content.Close() }()
+
+	// otherwise, ServeContent would read the beginning of the file to detect its content type
+	contentType := mime.TypeByExtension(path.Ext(file.Name))
+
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", length))
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	w.Header().Set("ETag", entityTag(file))
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+attachmentName+"\"")
+
+	http.ServeContent(w, r, file.Name, file.ModifiedAt, content)
+}
 
-	_, err = io.Copy(w, data)
+// entityTag Return the strong ETag of the file: its checksum, if known, or its modification time and size
+func entityTag(file *fs.FileInfo) string {
+	if file.Checksum != "" {
+		return "\"" + file.Checksum + "\""
+	}
+
+	return fmt.Sprintf("\"%x-%x\"", file.ModifiedAt.UnixNano(), file.Size)
+}
+
+func GetAuditLog(
+	w http.ResponseWriter,
+	principal *auth.Principal,
+	filter url.Values,
+	limit int,
+) {
+	matches := func(value string, key string) bool {
+		return !filter.Has(key) || filter.Get(key) == value
+	}
 
-	if err != nil && err != io.EOF {
+	entries, err := audit.Entries(limit, func(entry *audit.Entry) bool {
+		return principal.CanAccess(entry.Environment, entry.Disk) &&
+			matches(entry.Disk, "disk") &&
+			matches(entry.Directory, "dir") &&
+			matches(entry.File, "file") &&
+			matches(entry.Group, "group") &&
+			matches(entry.Action, "action")
+	})
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	writeData(w, entries)
+}
+
+func GetHistoryScans(
+	w http.ResponseWriter,
+	principal *auth.Principal,
+	query history.Query,
+) {
+	store := history.GetInstance()
+
+	if store == nil {
+		historyDisabled(w)
+		return
+	}
+
+	scans, err := store.Scans(query)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	visible := []history.Scan{}
+
+	for _, scan := range scans {
+		if principal.CanAccess(scan.Environment, scan.Disk) {
+			visible = append(visible, scan)
+		}
+	}
+
+	writeData(w, visible)
+}
+
+func GetHistoryFiles(
+	w http.ResponseWriter,
+	principal *auth.Principal,
+	query history.Query,
+) {
+	store := history.GetInstance()
+
+	if store == nil {
+		historyDisabled(w)
+		return
+	}
+
+	files, err := store.Files(query)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	visible := []history.Observation{}
+
+	for _, file := range files {
+		if principal.CanAccess(file.Environment, file.Disk) {
+			visible = append(visible, file)
+		}
+	}
+
+	writeData(w, visible)
+}
+
+func GetReport(
+	w http.ResponseWriter,
+	principal *auth.Principal,
+	options report.Options,
+	format string,
+) {
+	store := history.GetInstance()
+
+	if store == nil {
+		historyDisabled(w)
+		return
+	}
+
+	var definitions []storage.FileDefinitionRef
+
+	for _, definition := range storage.GetFileDefinitions() {
+		if principal.CanAccess(definition.Environment, definition.Disk) {
+			definitions = append(definitions, definition)
+		}
+	}
+
+	generated, err := report.Generate(store, definitions, options, time.Now())
+
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
-	_ = data.Close()
+
+	var buf bytes.Buffer
+
+	if err = generated.Write(&buf, format); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	_, _ = w.Write(buf.Bytes())
+}
+
+func Rescan(
+	w http.ResponseWriter,
+	r *http.Request,
+	environmentName string,
+	diskName string,
+	wait bool,
+) {
+	if err := storage.ValidateScanScope(environmentName, diskName); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	trigger := storage.TriggerScan(environmentName, diskName)
+
+	if !wait {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"accepted"}`))
+		return
+	}
+
+	select {
+	case <-trigger.Done():
+		writeData(w, trigger.Results())
+	case <-r.Context().Done():
+		// the client is gone, the scan continues anyway
+	}
+}
+
+// interval of comments keeping idle event streams open through proxies
+const eventsKeepAlive = 30 * time.Second
+
+func StreamEvents(
+	w http.ResponseWriter,
+	r *http.Request,
+	filter events.Filter,
+	lastID uint64,
+) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`Streaming is not supported.`))
+		return
+	}
+
+	received, cancel := events.GetInstance().Subscribe(filter, lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event := <-received:
+			if !allowed(r, event.Environment, event.Disk) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+func GetJobs(w http.ResponseWriter, principal *auth.Principal) {
+	jobs := []storage.JobStatus{}
+
+	for _, job := range storage.GetJobs() {
+		if principal.CanAccess(job.Environment, job.Disk) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	writeData(w, jobs)
+}
+
+func GetSilences(w http.ResponseWriter, principal *auth.Principal) {
+	silences := []notify.Silence{}
+
+	for _, silence := range notify.GetSilences(time.Now()) {
+		if canSilence(principal, silence.Labels) {
+			silences = append(silences, silence)
+		}
+	}
+
+	writeData(w, silences)
+}
+
+// canSilence Return whether the principal may access all alerts matched by the labels; scoped principals must name the
+// environment and disk of the silenced alerts
+func canSilence(principal *auth.Principal, labels map[string]string) bool {
+	return principal.CanAccess(labels[notify.LabelEnvironment], labels[notify.LabelDisk])
+}
+
+func CreateSilence(
+	w http.ResponseWriter,
+	principal *auth.Principal,
+	labels map[string]string,
+	duration time.Duration,
+	comment string,
+) {
+	if !canSilence(principal, labels) {
+		forbidden(w)
+		return
+	}
+
+	now := time.Now().UTC()
+	silence := notify.Silence{Labels: labels, Comment: comment, CreatedAt: now, Until: now.Add(duration)}
+
+	if principal != nil {
+		silence.CreatedBy = principal.Name
+	}
+
+	created, err := notify.AddSilence(silence)
+
+	if errors.Is(err, notify.ErrInvalidSilence) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`Labels must not be empty and only contain environment, disk, dir, file, group and status.`))
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Infof("Silenced alerts matching %v until %s", created.Labels, created.Until.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+func DeleteSilence(w http.ResponseWriter, principal *auth.Principal, id string) {
+	silence := notify.GetSilence(id)
+
+	if silence == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`Silence does not exist.`))
+		return
+	}
+
+	if !canSilence(principal, silence.Labels) {
+		forbidden(w)
+		return
+	}
+
+	notify.RemoveSilence(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetPurgeStates(w http.ResponseWriter, principal *auth.Principal) {
+	states := []storage.PurgeState{}
+
+	for _, state := range storage.GetPurgeStates() {
+		if principal.CanAccess(state.Environment, state.Disk) {
+			states = append(states, state)
+		}
+	}
+
+	writeData(w, states)
+}
+
+func PausePurging(w http.ResponseWriter, environmentName string, diskName string, paused bool) {
+	state, err := storage.PausePurging(environmentName, diskName, paused)
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	writeData(w, state)
+}
+
+func PostHeartbeat(
+	w http.ResponseWriter,
+	diskName string,
+	directoryName string,
+	fileName string,
+	group string,
+	heartbeat storage.Heartbeat,
+) {
+	job, err := storage.RecordHeartbeat(diskName, directoryName, fileName, group, heartbeat)
+
+	if errors.Is(err, storage.ErrUnknownDefinition) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	writeData(w, job)
+}
+
+func historyDisabled(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(history.ErrDisabled.Error()))
 }
 
 func writeData(w http.ResponseWriter, data interface{}) {
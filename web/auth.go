@@ -0,0 +1,125 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/dreitier/backmon/auth"
+	"github.com/dreitier/backmon/storage"
+	"github.com/gorilla/mux"
+)
+
+// authMiddleware authenticates each request and stores the principal in its context. Scoped principals may only access
+// routes of the environments and disks they are restricted to; see #48. Without any configured credentials, requests
+// are not authenticated.
+func authMiddleware(authenticator *auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authenticator.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r)
+
+			// #49: valid JWTs without a mapped role
+			if errors.Is(err, auth.ErrNoRole) {
+				forbidden(w)
+				return
+			}
+
+			if err != nil {
+				unauthorized(w, r)
+				return
+			}
+
+			if !canAccessRoute(principal, r) {
+				forbidden(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// require restricts the handler to principals with at least the role
+func require(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.FromContext(r.Context()).Has(role) {
+			forbidden(w)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// unscoped restricts the handler to principals which are not restricted to some environments or disks, e.g. because the
+// response cannot be filtered
+func unscoped(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.FromContext(r.Context()).Scoped() {
+			forbidden(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canAccessRoute Return whether the principal may access the environment and disk of the route. Routes without
+// environment and disk, e.g. listings, filter their responses by the principal.
+func canAccessRoute(principal *auth.Principal, r *http.Request) bool {
+	if !principal.Scoped() {
+		return true
+	}
+
+	// the variables are unescaped by the handlers again
+	environment, _ := url.PathUnescape(mux.Vars(r)["env"])
+	disk, _ := url.PathUnescape(mux.Vars(r)["disk"])
+
+	if environment == "" && disk == "" {
+		return true
+	}
+
+	if environment != "" {
+		return principal.CanAccess(environment, disk)
+	}
+
+	// the disk may exist in several environments, each of them must be accessible
+	environments := storage.EnvironmentsOfDisk(disk)
+
+	if len(environments) == 0 {
+		return principal.CanAccess("", disk)
+	}
+
+	for _, environment := range environments {
+		if !principal.CanAccess(environment, disk) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allowed Return whether the principal of the request may access the disk of the environment
+func allowed(r *http.Request, environment string, disk string) bool {
+	return auth.FromContext(r.Context()).CanAccess(environment, disk)
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	// browsers with an expired session of the web UI are not asked for basic auth credentials
+	if _, err := r.Cookie(auth.SessionCookie); err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+	}
+
+	w.WriteHeader(http.StatusUnauthorized)
+	_, _ = w.Write([]byte(`Unauthorized`))
+}
+
+func forbidden(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write([]byte(`Forbidden`))
+}
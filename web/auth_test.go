@@ -0,0 +1,191 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/notify"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_GH48_routes_enforceRolesAndScopes(t *testing.T) {
+	assertion := assert.New(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+
+	raw, err := config.ParseFromString(fmt.Sprintf(
+		`
+http:
+  metrics_auth: true
+  users:
+    viewer:
+      password: %[1]s
+    scoped:
+      password: %[1]s
+      role: operator
+      disks: [db-backups]
+    production:
+      password: %[1]s
+      environments: [prod]
+environments:
+  default:
+    s3:
+      region: eu-central-1
+`, hash))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newRouter(config.NewConfigurationInstance(raw))
+
+	serve := func(method string, target string, username string) int {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, nil)
+
+		if username != "" {
+			r.SetBasicAuth(username, "secret")
+		}
+
+		router.ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	assertion.Equal(http.StatusUnauthorized, serve(http.MethodGet, "/api/v2/disks", ""))
+	assertion.Equal(http.StatusUnauthorized, serve(http.MethodGet, "/metrics", ""))
+	assertion.Equal(http.StatusOK, serve(http.MethodGet, "/metrics", "viewer"))
+	// the metrics cannot be filtered for scoped users
+	assertion.Equal(http.StatusForbidden, serve(http.MethodGet, "/metrics", "scoped"))
+
+	assertion.Equal(http.StatusForbidden, serve(http.MethodPost, "/api/rescan", "viewer"))
+	assertion.Equal(http.StatusForbidden, serve(http.MethodPost, "/api/rescan", "scoped"))
+	assertion.Equal(http.StatusForbidden, serve(http.MethodGet, "/api/other-disk/dumps", "scoped"))
+	assertion.Equal(http.StatusNotFound, serve(http.MethodGet, "/api/db-backups/dumps", "scoped"))
+
+	// listings are filtered instead of being forbidden for scoped users
+	for _, path := range []string{"/api", "/api/v2/disks", "/api/v2/environments", "/api/heartbeats", "/ui/", "/ui/config.json"} {
+		assertion.Equal(http.StatusOK, serve(http.MethodGet, path, "scoped"), path)
+		assertion.Equal(http.StatusOK, serve(http.MethodGet, path, "production"), path)
+	}
+
+	assertion.Equal(http.StatusForbidden, serve(http.MethodPost, "/api/rescan/prod", "scoped"))
+}
+
+func Test_GH38_routes_acceptHeartbeatTokenOnlyForPosts(t *testing.T) {
+	assertion := assert.New(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+
+	raw, err := config.ParseFromString(fmt.Sprintf(
+		`
+heartbeats:
+  token: heartbeat-token
+http:
+  users:
+    operator:
+      password: %s
+      role: operator
+environments:
+  default:
+    s3:
+      region: eu-central-1
+`, hash))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newRouter(config.NewConfigurationInstance(raw))
+
+	serve := func(method string, target string, token string) int {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, nil)
+
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			r.SetBasicAuth("operator", "secret")
+		}
+
+		router.ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	// the job does not exist, but the request has been authenticated
+	assertion.Equal(http.StatusNotFound, serve(http.MethodPost, "/api/heartbeats/db/dumps/dump/acme/success", "heartbeat-token"))
+	assertion.Equal(http.StatusUnauthorized, serve(http.MethodPost, "/api/heartbeats/db/dumps/dump/acme/success", "invalid"))
+	assertion.Equal(http.StatusUnauthorized, serve(http.MethodGet, "/api/heartbeats/db/dumps/dump/acme/success", "heartbeat-token"))
+	assertion.Equal(http.StatusUnauthorized, serve(http.MethodGet, "/api/heartbeats", "heartbeat-token"))
+
+	assertion.Equal(http.StatusNotFound, serve(http.MethodGet, "/api/heartbeats/db/dumps/dump/acme/success", ""))
+	assertion.Equal(http.StatusOK, serve(http.MethodGet, "/api/heartbeats", ""))
+}
+
+func Test_GH48_routes_requireRolesForSilencesAndPurgeControls(t *testing.T) {
+	assertion := assert.New(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+
+	raw, err := config.ParseFromString(fmt.Sprintf(
+		`
+http:
+  users:
+    viewer:
+      password: %[1]s
+    operator:
+      password: %[1]s
+      role: operator
+    scoped:
+      password: %[1]s
+      role: operator
+      environments: [prod]
+    admin:
+      password: %[1]s
+      role: admin
+environments:
+  default:
+    s3:
+      region: eu-central-1
+`, hash))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newRouter(config.NewConfigurationInstance(raw))
+
+	serve := func(method string, target string, username string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetBasicAuth(username, "secret")
+
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	silence := `{"labels": {"environment": "prod", "group": "a"}, "duration": "1h"}`
+
+	assertion.Equal(http.StatusForbidden, serve(http.MethodPost, "/api/silences", "viewer", silence).Code)
+	assertion.Equal(http.StatusBadRequest, serve(http.MethodPost, "/api/silences", "operator", `{"labels": {"environment": "prod"}, "duration": "soon"}`).Code)
+	// scoped operators may only silence alerts of their environments
+	assertion.Equal(http.StatusForbidden, serve(http.MethodPost, "/api/silences", "scoped", `{"labels": {"group": "a"}, "duration": "1h"}`).Code)
+
+	created := serve(http.MethodPost, "/api/silences", "scoped", silence)
+	assertion.Equal(http.StatusCreated, created.Code)
+
+	var body notify.Silence
+	assertion.NoError(json.Unmarshal(created.Body.Bytes(), &body))
+	assertion.Equal("scoped", body.CreatedBy)
+	assertion.Contains(serve(http.MethodGet, "/api/silences", "viewer", "").Body.String(), body.ID)
+
+	assertion.Equal(http.StatusForbidden, serve(http.MethodDelete, "/api/silences/"+body.ID, "viewer", "").Code)
+	assertion.Equal(http.StatusNoContent, serve(http.MethodDelete, "/api/silences/"+body.ID, "operator", "").Code)
+	assertion.Equal(http.StatusNotFound, serve(http.MethodDelete, "/api/silences/"+body.ID, "operator", "").Code)
+
+	assertion.Equal(http.StatusForbidden, serve(http.MethodPost, "/api/purge/prod/db/pause", "operator", "").Code)
+	assertion.Equal(http.StatusNotFound, serve(http.MethodPost, "/api/purge/prod/db/pause", "admin", "").Code)
+	assertion.Equal(http.StatusOK, serve(http.MethodGet, "/api/purge", "viewer", "").Code)
+}
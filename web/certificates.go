@@ -0,0 +1,139 @@
+package web
+
+import (
+	"crypto/tls"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/dreitier/backmon/auth"
+	"github.com/dreitier/backmon/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// certificateReloadInterval the certificate and the key are checked for modifications in this interval
+const certificateReloadInterval = time.Minute
+
+// certificateReloader serves the server certificate and reloads it from disk once the certificate or the key have been
+// modified, so that rotated certificates are used without a restart; see #50
+type certificateReloader struct {
+	certificatePath string
+	keyPath         string
+	// the certificate is read during each TLS handshake, without a lock
+	certificate atomic.Pointer[tls.Certificate]
+	// the modification times of the loaded certificate and key; only accessed by reload
+	modified [2]time.Time
+}
+
+func newCertificateReloader(certificatePath string, keyPath string) (*certificateReloader, error) {
+	r := &certificateReloader{certificatePath: certificatePath, keyPath: keyPath}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate Return the current certificate
+func (c *certificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.certificate.Load(), nil
+}
+
+// watch Reloads the certificate in the interval. While the certificate and the key do not match, e.g. during a
+// rotation, the previous certificate is served.
+func (c *certificateReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := c.reload(); err != nil {
+				log.Errorf("Unable to reload TLS certificate '%s': %s", c.certificatePath, err)
+			}
+		}
+	}()
+}
+
+// reload Loads the certificate and the key unless they are unchanged since the last reload
+func (c *certificateReloader) reload() error {
+	var modified [2]time.Time
+
+	for i, path := range []string{c.certificatePath, c.keyPath} {
+		stat, err := os.Stat(path)
+
+		if err != nil {
+			return err
+		}
+
+		modified[i] = stat.ModTime()
+	}
+
+	if c.certificate.Load() != nil && modified == c.modified {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certificatePath, c.keyPath)
+
+	if err != nil {
+		return err
+	}
+
+	if c.certificate.Load() != nil {
+		log.Infof("Reloaded TLS certificate '%s'", c.certificatePath)
+	}
+
+	c.certificate.Store(&certificate)
+	c.modified = modified
+
+	return nil
+}
+
+// clientAuthTypes TLS client authentication of the `client_auth` modes. Certificates which are not verified during the
+// handshake are verified before authenticating the request.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	config.ClientAuthRequest: tls.RequestClientCert,
+	config.ClientAuthRequire: tls.RequireAnyClientCert,
+	config.ClientAuthVerify:  tls.RequireAndVerifyClientCert,
+}
+
+// newTLSConfig Return the TLS configuration of the server and the reloader of its certificate
+func newTLSConfig(cfg *config.TlsConfiguration) (*tls.Config, *certificateReloader, error) {
+	r := &tls.Config{}
+
+	// `strict: true` sets the TLS configuration to something SSLLabs prefers
+	// @see https://gist.github.com/denji/12b3a568f092ab951456
+	if cfg.IsStrict {
+		r = &tls.Config{
+			MinVersion:       tls.VersionTLS12,
+			CurvePreferences: []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+			},
+		}
+	}
+
+	// #50: rotated certificates are reloaded without a restart
+	certificates, err := newCertificateReloader(cfg.CertificatePath, cfg.PrivateKeyPath)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.GetCertificate = certificates.GetCertificate
+
+	// #50: client certificates of the client CA
+	if cfg.ClientCaPath != "" {
+		clientCAs, err := auth.ReadCertPool(cfg.ClientCaPath)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		r.ClientCAs = clientCAs
+		r.ClientAuth = clientAuthTypes[cfg.ClientAuth]
+	}
+
+	return r, certificates, nil
+}
@@ -0,0 +1,238 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCertificate Return a certificate signed by the parent, or a self-signed one without parent
+func newCertificate(t *testing.T, template *x509.Certificate, parent *tls.Certificate) *tls.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := template, interface{}(key)
+
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, _ := x509.ParseCertificate(der)
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeCertificate writes the certificate and its key as PEM files, modified at the time
+func writeCertificate(t *testing.T, certificate *tls.Certificate, certificatePath string, keyPath string, modified time.Time) {
+	key, _ := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+
+	for path, block := range map[string]*pem.Block{
+		certificatePath: {Type: "CERTIFICATE", Bytes: certificate.Certificate[0]},
+		keyPath:         {Type: "PRIVATE KEY", Bytes: key},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newServerCertificate(t *testing.T) *tls.Certificate {
+	return newCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "backmon"},
+		DNSNames:    []string{"localhost"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil)
+}
+
+func Test_GH50_certificateReloader_reloadsModifiedCertificates(t *testing.T) {
+	assertion := assert.New(t)
+	dir := t.TempDir()
+	certificatePath, keyPath := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	now := time.Now()
+
+	first := newServerCertificate(t)
+	writeCertificate(t, first, certificatePath, keyPath, now)
+
+	sut, err := newCertificateReloader(certificatePath, keyPath)
+	assertion.NoError(err)
+
+	served, err := sut.GetCertificate(nil)
+	assertion.NoError(err)
+	assertion.Equal(first.Certificate[0], served.Certificate[0])
+
+	rotated := newServerCertificate(t)
+	writeCertificate(t, rotated, certificatePath, keyPath, now.Add(time.Minute))
+
+	// the certificate is only reloaded by the ticker
+	served, _ = sut.GetCertificate(nil)
+	assertion.Equal(first.Certificate[0], served.Certificate[0])
+
+	sut.watch(10 * time.Millisecond)
+	assertion.Eventually(func() bool {
+		served, _ = sut.GetCertificate(nil)
+		return string(served.Certificate[0]) == string(rotated.Certificate[0])
+	}, time.Second, 10*time.Millisecond)
+
+	// while only the certificate has been replaced, the previous one is served
+	incomplete := newServerCertificate(t)
+	writeCertificate(t, incomplete, certificatePath, filepath.Join(dir, "other.key"), now.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+
+	served, err = sut.GetCertificate(nil)
+	assertion.NoError(err)
+	assertion.Equal(rotated.Certificate[0], served.Certificate[0])
+
+	_, err = newCertificateReloader(filepath.Join(dir, "missing.crt"), keyPath)
+	assertion.Error(err)
+}
+
+func Test_GH50_newTLSConfig_configuresClientCertificates(t *testing.T) {
+	assertion := assert.New(t)
+	dir := t.TempDir()
+	certificatePath, keyPath := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	server := newServerCertificate(t)
+	writeCertificate(t, server, certificatePath, keyPath, time.Now())
+	// the client CA only needs to contain a certificate
+	caPath := certificatePath
+
+	sut, certificates, err := newTLSConfig(&config.TlsConfiguration{CertificatePath: certificatePath, PrivateKeyPath: keyPath})
+	assertion.NoError(err)
+	assertion.NotNil(certificates)
+	assertion.Nil(sut.ClientCAs)
+	assertion.Equal(tls.NoClientCert, sut.ClientAuth)
+	assertion.Zero(sut.MinVersion)
+
+	served, _ := sut.GetCertificate(nil)
+	assertion.Equal(server.Certificate[0], served.Certificate[0])
+
+	// the strict configuration must not drop the certificate and the client authentication
+	sut, _, err = newTLSConfig(&config.TlsConfiguration{
+		CertificatePath: certificatePath,
+		PrivateKeyPath:  keyPath,
+		IsStrict:        true,
+		ClientCaPath:    caPath,
+		ClientAuth:      config.ClientAuthRequire,
+	})
+	assertion.NoError(err)
+	assertion.Equal(uint16(tls.VersionTLS12), sut.MinVersion)
+	assertion.NotNil(sut.ClientCAs)
+	assertion.Equal(tls.RequireAnyClientCert, sut.ClientAuth)
+	assertion.NotNil(sut.GetCertificate)
+
+	_, _, err = newTLSConfig(&config.TlsConfiguration{
+		CertificatePath: certificatePath,
+		PrivateKeyPath:  keyPath,
+		ClientCaPath:    filepath.Join(dir, "missing.pem"),
+	})
+	assertion.Error(err)
+}
+
+func Test_GH50_routes_authenticateClientCertificates(t *testing.T) {
+	assertion := assert.New(t)
+	dir := t.TempDir()
+
+	ca := newCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Internal CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	writeCertificate(t, ca, filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca.key"), time.Now())
+	server := newServerCertificate(t)
+	writeCertificate(t, server, filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), time.Now())
+
+	raw, err := config.ParseFromString(fmt.Sprintf(
+		`
+http:
+  tls:
+    certificate: %[1]s/server.crt
+    key: %[1]s/server.key
+    client_ca: %[1]s/ca.pem
+    client_auth: request
+    clients:
+      - subject: prometheus
+environments:
+  default:
+    s3:
+      region: eu-central-1
+`, dir))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.NewConfigurationInstance(raw)
+	tlsConfig, _, err := newTLSConfig(cfg.Http().Tls)
+	assertion.NoError(err)
+
+	sut := httptest.NewUnstartedServer(newRouter(cfg))
+	sut.TLS = tlsConfig
+	sut.StartTLS()
+	defer sut.Close()
+
+	request := func(clientCertificate *tls.Certificate) int {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Leaf)
+		clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+
+		if clientCertificate != nil {
+			clientConfig.Certificates = []tls.Certificate{*clientCertificate}
+		}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		response, err := client.Get(sut.URL + "/api/v2/disks")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = response.Body.Close()
+
+		return response.StatusCode
+	}
+
+	prometheus := newCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "prometheus"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	unknown := newCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "unknown"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	selfSigned := newCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "prometheus"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil)
+
+	assertion.Equal(http.StatusOK, request(prometheus))
+	assertion.Equal(http.StatusForbidden, request(unknown))
+	assertion.Equal(http.StatusUnauthorized, request(selfSigned))
+	assertion.Equal(http.StatusUnauthorized, request(nil))
+}
@@ -0,0 +1,204 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/dreitier/backmon/auth"
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/storage"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// Signed download links allow downloading a backup without the credentials of the API until they expire, e.g. by a
+// restore engineer; see #47. A link pins the backup by its path, so that it keeps downloading the same file after newer
+// backups have been taken.
+
+var (
+	errInvalidLink = errors.New("download link is invalid")
+	errExpiredLink = errors.New("download link has expired")
+)
+
+// DownloadLink a signed download link
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type linkSigner struct {
+	key []byte
+}
+
+func newLinkSigner(downloads *config.DownloadsConfiguration) *linkSigner {
+	key := []byte(downloads.SigningKey)
+
+	if len(key) == 0 {
+		key = make([]byte, sha256.Size)
+
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+
+		log.Info("No signing key for download links configured, links are invalidated on restart")
+	}
+
+	return &linkSigner{key: key}
+}
+
+// signature Return the HMAC of the backup and the expiry
+func (s *linkSigner) signature(disk string, dir string, file string, group string, name string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+
+	for _, value := range []string{disk, dir, file, group, name, strconv.FormatInt(expires, 10)} {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path Return the path and query of the signed link
+func (s *linkSigner) path(disk string, dir string, file string, group string, name string, expiresAt time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", s.signature(disk, dir, file, group, name, expiresAt.Unix()))
+
+	r := "/download"
+
+	for _, segment := range []string{disk, dir, file, group, name} {
+		r += "/" + url.PathEscape(segment)
+	}
+
+	return r + "?" + query.Encode()
+}
+
+// verify Return an error if the signature does not match the backup or the link has expired
+func (s *linkSigner) verify(disk string, dir string, file string, group string, name string, query url.Values, now time.Time) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+
+	if err != nil {
+		return errInvalidLink
+	}
+
+	expected := s.signature(disk, dir, file, group, name, expires)
+
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return errInvalidLink
+	}
+
+	if now.Unix() > expires {
+		return errExpiredLink
+	}
+
+	return nil
+}
+
+// registerDownloadLinks registers the endpoint creating signed links below /api and the endpoint serving them, which is
+// not protected by basic auth
+func registerDownloadLinks(apiEndpoint *mux.Router, router *mux.Router, downloads *config.DownloadsConfiguration) {
+	signer := newLinkSigner(downloads)
+
+	apiEndpoint.HandleFunc("/{disk}/{dir}/{file}/{variant}/files/{name}/link", require(auth.RoleDownloader, DownloadLinkHandler(signer, downloads))).Methods(HttpMethodPost)
+
+	downloadEndpoint := router.PathPrefix("/download").Subrouter()
+	downloadEndpoint.Use(loggingMiddleware)
+	downloadEndpoint.HandleFunc("/{disk}/{dir}/{file}/{variant}/{name}", SignedDownloadHandler(signer, downloads)).Methods(HttpMethodGet, HttpMethodHead)
+}
+
+// DownloadLinkHandler creates a signed link of a backup, found by name or timestamp like /files/{name}. The lifetime of
+// the link can be set with `expires_in`.
+func DownloadLinkHandler(signer *linkSigner, downloads *config.DownloadsConfiguration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		unescape(vars)
+
+		expiry := downloads.LinkExpiry
+
+		if value := r.URL.Query().Get("expires_in"); value != "" {
+			parsed, err := parseDuration(value)
+
+			if err != nil || parsed <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("Invalid expiry '%s'.", value)))
+				return
+			}
+
+			if parsed > downloads.MaxLinkExpiry {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("The expiry must not exceed %s.", downloads.MaxLinkExpiry)))
+				return
+			}
+
+			expiry = parsed
+		}
+
+		// the timestamp is optional, as most names are no timestamps
+		at, _ := parseTimestamp(vars["name"])
+		file, err := storage.FindBackup(vars["disk"], vars["dir"], vars["file"], vars["variant"], vars["name"], at)
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		pinned := path.Join(file.Parent, file.Name)
+		expiresAt := time.Unix(time.Now().Add(expiry).Unix(), 0).UTC()
+
+		log.Infof("Created download link of '%s' in disk '%s', valid until %s", pinned, vars["disk"], expiresAt)
+
+		writeData(w, DownloadLink{
+			URL:       baseURL(r) + signer.path(vars["disk"], vars["dir"], vars["file"], vars["variant"], pinned, expiresAt),
+			ExpiresAt: expiresAt,
+		})
+	}
+}
+
+// SignedDownloadHandler serves the backup of a signed link
+func SignedDownloadHandler(signer *linkSigner, downloads *config.DownloadsConfiguration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		unescape(vars)
+
+		if err := signer.verify(vars["disk"], vars["dir"], vars["file"], vars["variant"], vars["name"], r.URL.Query(), time.Now()); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		file, err := storage.FindBackup(vars["disk"], vars["dir"], vars["file"], vars["variant"], vars["name"], time.Time{})
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		serveBackup(w, r, downloads, vars["disk"], file.Name, file)
+	}
+}
+
+// baseURL Return the scheme and host the request has been sent to, respecting the scheme of a reverse proxy
+func baseURL(r *http.Request) string {
+	scheme := "http"
+
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+
+	return scheme + "://" + r.Host
+}
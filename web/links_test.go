@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH47_linkSigner_verify_rejectsTamperedAndExpiredLinks(t *testing.T) {
+	assertion := assert.New(t)
+	sut := newLinkSigner(&config.DownloadsConfiguration{SigningKey: "secret"})
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	link, err := url.Parse(sut.path("disk", "dumps", "pgdump", "db", "db/dump-1.sql", now.Add(time.Hour)))
+	assertion.NoError(err)
+	assertion.Equal("/download/disk/dumps/pgdump/db/db%2Fdump-1.sql", link.EscapedPath())
+
+	query := link.Query()
+
+	assertion.NoError(sut.verify("disk", "dumps", "pgdump", "db", "db/dump-1.sql", query, now))
+	assertion.ErrorIs(sut.verify("disk", "dumps", "pgdump", "db", "db/dump-2.sql", query, now), errInvalidLink)
+	assertion.ErrorIs(sut.verify("disk", "dumps", "pgdump", "db", "db/dump-1.sql", query, now.Add(2*time.Hour)), errExpiredLink)
+
+	query.Set("expires", query.Get("expires")+"0")
+	assertion.ErrorIs(sut.verify("disk", "dumps", "pgdump", "db", "db/dump-1.sql", query, now), errInvalidLink)
+
+	// links are only valid with the key they have been signed with
+	other := newLinkSigner(&config.DownloadsConfiguration{})
+	assertion.ErrorIs(other.verify("disk", "dumps", "pgdump", "db", "db/dump-1.sql", link.Query(), now), errInvalidLink)
+}
+
+func Test_GH47_routes_rejectInvalidLinksAndExpiries(t *testing.T) {
+	assertion := assert.New(t)
+	router := newTestRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/download/disk/dumps/pgdump/db/dump.sql?expires=4102444800&signature=00", nil))
+	assertion.Equal(http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/disk/dumps/pgdump/db/files/dump.sql/link?expires_in=48h", nil))
+	assertion.Equal(http.StatusBadRequest, recorder.Code)
+	assertion.Equal("The expiry must not exceed 24h0m0s.", recorder.Body.String())
+}
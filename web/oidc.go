@@ -0,0 +1,145 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dreitier/backmon/auth"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// the state and the PKCE verifier of a pending login
+const loginCookie = "backmon_login"
+
+// registerLogin registers the OIDC authorization code flow of the web UI; see #49. It must be registered before the
+// web UI, as these routes do not require authentication.
+func registerLogin(router *mux.Router, authenticator *auth.Authenticator) {
+	router.HandleFunc("/ui/login", LoginHandler(authenticator)).Methods(HttpMethodGet)
+	router.HandleFunc("/ui/callback", LoginCallbackHandler(authenticator)).Methods(HttpMethodGet)
+}
+
+// LoginHandler redirects to the authorization endpoint of the issuer
+func LoginHandler(authenticator *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg, err := authenticator.OAuth2Config(r.Context(), baseURL(r)+"/ui/callback")
+
+		if err != nil {
+			log.Errorf("Unable to sign in through OIDC: %s", err)
+			loginError(w, http.StatusBadGateway, "The OIDC issuer is not available.")
+			return
+		}
+
+		state := make([]byte, 16)
+		_, _ = rand.Read(state)
+		verifier := oauth2.GenerateVerifier()
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     loginCookie,
+			Value:    hex.EncodeToString(state) + "." + verifier,
+			Path:     "/ui/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		http.Redirect(w, r, cfg.AuthCodeURL(hex.EncodeToString(state), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+	}
+}
+
+// LoginCallbackHandler exchanges the authorization code and stores the ID token in the session cookie
+func LoginCallbackHandler(authenticator *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pending, err := r.Cookie(loginCookie)
+
+		if err != nil {
+			loginError(w, http.StatusBadRequest, "No login is pending.")
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: loginCookie, Path: "/ui/", MaxAge: -1})
+
+		state, verifier, _ := strings.Cut(pending.Value, ".")
+
+		if query.Get("state") != state {
+			loginError(w, http.StatusBadRequest, "The state of the login does not match.")
+			return
+		}
+
+		if reason := query.Get("error"); reason != "" {
+			log.Warnf("Login through OIDC has been rejected by the issuer: %s %s", reason, query.Get("error_description"))
+			loginError(w, http.StatusUnauthorized, "The login has been rejected by the issuer.")
+			return
+		}
+
+		cfg, err := authenticator.OAuth2Config(r.Context(), baseURL(r)+"/ui/callback")
+
+		if err != nil {
+			log.Errorf("Unable to sign in through OIDC: %s", err)
+			loginError(w, http.StatusBadGateway, "The OIDC issuer is not available.")
+			return
+		}
+
+		token, err := cfg.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(verifier))
+
+		if err != nil {
+			log.Errorf("Unable to exchange the authorization code: %s", err)
+			loginError(w, http.StatusBadGateway, "The authorization code could not be exchanged.")
+			return
+		}
+
+		idToken, _ := token.Extra("id_token").(string)
+		principal, err := authenticator.AuthenticateIDToken(r.Context(), idToken)
+
+		if errors.Is(err, auth.ErrNoRole) {
+			forbidden(w)
+			return
+		}
+
+		if err != nil {
+			loginError(w, http.StatusUnauthorized, "The ID token is invalid.")
+			return
+		}
+
+		log.Infof("User '%s' signed in to the web UI with role '%s'", principal.Name, principal.Role)
+
+		// SameSite prevents cross-site requests with the session, e.g. triggering rescans
+		http.SetCookie(w, &http.Cookie{
+			Name:     auth.SessionCookie,
+			Value:    idToken,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+	}
+}
+
+// loginMiddleware redirects browsers without a valid session to the login, instead of asking for basic auth
+func loginMiddleware(authenticator *auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == HttpMethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				if _, err := authenticator.Authenticate(r); err != nil && !errors.Is(err, auth.ErrNoRole) {
+					http.Redirect(w, r, "/ui/login", http.StatusFound)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func loginError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(message))
+}
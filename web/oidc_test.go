@@ -0,0 +1,166 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dreitier/backmon/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMockIssuer Return an in-process OIDC issuer, which issues an ID token with the claims for each authorization code
+func newMockIssuer(t *testing.T, claims map[string]interface{}) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "test"}}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 server.URL,
+			"jwks_uri":               server.URL + "/jwks",
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Use: "sig"}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "valid-code" || r.PostFormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		claims["iss"] = server.URL
+		claims["aud"] = "backmon"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+
+		payload, _ := json.Marshal(claims)
+		signed, _ := signer.Sign(payload)
+		idToken, _ := signed.CompactSerialize()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func Test_GH49_routes_signInThroughAuthorizationCodeFlow(t *testing.T) {
+	assertion := assert.New(t)
+	issuer := newMockIssuer(t, map[string]interface{}{"sub": "0815", "groups": []string{"backmon-ops"}})
+
+	raw, err := config.ParseFromString(fmt.Sprintf(
+		`
+http:
+  oidc:
+    issuer: %s
+    client_id: backmon
+    client_secret: secret
+    roles:
+      - value: backmon-ops
+        role: operator
+environments:
+  default:
+    s3:
+      region: eu-central-1
+`, issuer.URL))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newRouter(config.NewConfigurationInstance(raw))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	// browsers are redirected to the login
+	page := httptest.NewRequest(http.MethodGet, "/ui/", nil)
+	page.Header.Set("Accept", "text/html,application/xhtml+xml")
+	recorder := serve(page)
+	assertion.Equal(http.StatusFound, recorder.Code)
+	assertion.Equal("/ui/login", recorder.Header().Get("Location"))
+
+	recorder = serve(httptest.NewRequest(http.MethodGet, "/ui/login", nil))
+	assertion.Equal(http.StatusFound, recorder.Code)
+
+	authorization, _ := url.Parse(recorder.Header().Get("Location"))
+	assertion.Equal(issuer.URL+"/authorize", authorization.Scheme+"://"+authorization.Host+authorization.Path)
+	assertion.Equal("backmon", authorization.Query().Get("client_id"))
+	assertion.Equal("http://example.com/ui/callback", authorization.Query().Get("redirect_uri"))
+	assertion.Equal("S256", authorization.Query().Get("code_challenge_method"))
+
+	pending := recorder.Result().Cookies()[0]
+
+	// the state must match the pending login
+	callback := httptest.NewRequest(http.MethodGet, "/ui/callback?code=valid-code&state=forged", nil)
+	callback.AddCookie(pending)
+	assertion.Equal(http.StatusBadRequest, serve(callback).Code)
+
+	callback = httptest.NewRequest(http.MethodGet, "/ui/callback?code=valid-code&state="+authorization.Query().Get("state"), nil)
+	callback.AddCookie(pending)
+	recorder = serve(callback)
+	assertion.Equal(http.StatusFound, recorder.Code)
+	assertion.Equal("/ui/", recorder.Header().Get("Location"))
+
+	var session *http.Cookie
+
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "backmon_session" {
+			session = cookie
+		}
+	}
+
+	if !assertion.NotNil(session) {
+		return
+	}
+
+	assertion.True(session.HttpOnly)
+
+	api := httptest.NewRequest(http.MethodGet, "/api/v2/disks", nil)
+	api.AddCookie(session)
+	assertion.Equal(http.StatusOK, serve(api).Code)
+
+	// the ID token is also accepted as bearer token; an invalid parameter is only rejected after the role check
+	api = httptest.NewRequest(http.MethodPost, "/api/rescan?wait=maybe", nil)
+	api.Header.Set("Authorization", "Bearer "+session.Value)
+	assertion.Equal(http.StatusBadRequest, serve(api).Code)
+
+	// expired sessions are not asked for basic auth credentials
+	api = httptest.NewRequest(http.MethodGet, "/api/v2/disks", nil)
+	api.AddCookie(&http.Cookie{Name: "backmon_session", Value: "a.b.c"})
+	recorder = serve(api)
+	assertion.Equal(http.StatusUnauthorized, recorder.Code)
+	assertion.Empty(recorder.Header().Get("WWW-Authenticate"))
+}
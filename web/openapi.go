@@ -0,0 +1,10 @@
+package web
+
+import (
+	_ "embed"
+)
+
+// openAPISpecification describes all endpoints of newRouter; see #42. web/openapi_test.go ensures that both match.
+//
+//go:embed openapi.json
+var openAPISpecification []byte
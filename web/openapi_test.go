@@ -0,0 +1,393 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/history"
+	"github.com/dreitier/backmon/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type specification struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// schema the subset of the OpenAPI schema object used by openapi.json
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Nullable   bool               `json:"nullable"`
+	Enum       []string           `json:"enum"`
+	Properties map[string]*schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *schema            `json:"items"`
+	AllOf      []*schema          `json:"allOf"`
+}
+
+// responseSchema Return the schema of the JSON response of the operation with the status
+func (spec specification) responseSchema(t *testing.T, method string, path string, status string) *schema {
+	var operation struct {
+		Responses map[string]struct {
+			Content map[string]struct {
+				Schema *schema `json:"schema"`
+			} `json:"content"`
+		} `json:"responses"`
+	}
+
+	if err := json.Unmarshal(spec.Paths[path][method], &operation); err != nil {
+		t.Fatalf("operation %s %s is invalid: %v", method, path, err)
+	}
+
+	r := operation.Responses[status].Content["application/json"].Schema
+
+	if r == nil {
+		t.Fatalf("operation %s %s has no JSON response %s", method, path, status)
+	}
+
+	return r
+}
+
+// validate Return the violations of the schema by the decoded JSON value; properties which are not specified are
+// violations as well, so that the specification cannot fall behind the resources
+func (spec specification) validate(s *schema, value interface{}, at string) []string {
+	if s.Ref != "" {
+		return spec.validate(spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")], value, at)
+	}
+
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+
+		return []string{at + ": must not be null"}
+	}
+
+	var r []string
+
+	for _, sub := range s.AllOf {
+		r = append(r, spec.validate(sub, value, at)...)
+	}
+
+	mismatch := []string{fmt.Sprintf("%s: %v is no %s", at, value, s.Type)}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+
+		if !ok {
+			return mismatch
+		}
+
+		for _, name := range s.Required {
+			if _, exists := object[name]; !exists {
+				r = append(r, at+"."+name+": is required")
+			}
+		}
+
+		for name, property := range object {
+			if sub, specified := s.Properties[name]; specified {
+				r = append(r, spec.validate(sub, property, at+"."+name)...)
+			} else if len(s.Properties) > 0 {
+				r = append(r, at+"."+name+": is not specified")
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+
+		if !ok {
+			return mismatch
+		}
+
+		for i, item := range items {
+			r = append(r, spec.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+
+		if !ok {
+			return mismatch
+		}
+
+		if len(s.Enum) > 0 && !contains(s.Enum, text) {
+			r = append(r, fmt.Sprintf("%s: %q is not one of %v", at, text, s.Enum))
+		}
+
+		if _, err := time.Parse(time.RFC3339, text); s.Format == "date-time" && err != nil {
+			r = append(r, fmt.Sprintf("%s: %q is no date-time", at, text))
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+
+		if !ok || (s.Type == "integer" && number != float64(int64(number))) {
+			return mismatch
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch
+		}
+	}
+
+	return r
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decode Return the decoded JSON body of the response
+func decode(t *testing.T, recorder *httptest.ResponseRecorder) interface{} {
+	var r interface{}
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &r); err != nil {
+		t.Fatalf("response is no JSON: %v", err)
+	}
+
+	return r
+}
+
+func newTestRouter(t *testing.T) *mux.Router {
+	raw, err := config.ParseFromString(
+		`
+port: 8080
+http:
+downloads:
+  enabled: true
+environments:
+  default:
+    s3:
+      region: eu-central-1
+`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newRouter(config.NewConfigurationInstance(raw))
+}
+
+func loadSpecification(t *testing.T) specification {
+	var spec specification
+
+	if err := json.Unmarshal(openAPISpecification, &spec); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+
+	return spec
+}
+
+// registeredOperations returns each operation of the router as "METHOD path"; routes without methods accept GET
+func registeredOperations(t *testing.T, router *mux.Router) []string {
+	var r []string
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		path, err := route.GetPathTemplate()
+
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+
+		if err != nil {
+			methods = []string{HttpMethodGet}
+		}
+
+		for _, method := range methods {
+			r = append(r, method+" "+path)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(r)
+
+	return r
+}
+
+func specifiedOperations(spec specification) []string {
+	var r []string
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+
+			r = append(r, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(r)
+
+	return r
+}
+
+func Test_GH42_openAPISpecification_matchesRoutes(t *testing.T) {
+	assertion := assert.New(t)
+	spec := loadSpecification(t)
+
+	assertion.True(strings.HasPrefix(spec.OpenAPI, "3."))
+	assertion.Equal(registeredOperations(t, newTestRouter(t)), specifiedOperations(spec))
+}
+
+func Test_GH27_routes_reserveDiskNamesOfFixedRoutes(t *testing.T) {
+	assertion := assert.New(t)
+
+	for _, operation := range registeredOperations(t, newTestRouter(t)) {
+		path := strings.SplitN(operation, " ", 2)[1]
+
+		if !strings.HasPrefix(path, "/api/") {
+			continue
+		}
+
+		segment := strings.SplitN(strings.TrimPrefix(path, "/api/"), "/", 2)[0]
+
+		if segment != "" && !strings.HasPrefix(segment, "{") {
+			assertion.True(storage.IsReservedDiskName(segment), "disks named '%s' are shadowed by %s", segment, operation)
+		}
+	}
+}
+
+func Test_GH42_OpenAPIHandler_servesSpecification(t *testing.T) {
+	assertion := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	newTestRouter(t).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	assertion.Equal(http.StatusOK, recorder.Code)
+	assertion.True(strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json"))
+	assertion.True(json.Valid(recorder.Body.Bytes()))
+}
+
+func Test_GH42_v2Errors_matchErrorSchema(t *testing.T) {
+	assertion := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	newTestRouter(t).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/disks/unknown", nil))
+
+	var body struct {
+		Error *APIError `json:"error"`
+	}
+
+	assertion.Equal(http.StatusNotFound, recorder.Code)
+	assertion.NoError(json.Unmarshal(recorder.Body.Bytes(), &body))
+	assertion.NotNil(body.Error)
+	assertion.Equal(http.StatusNotFound, body.Error.Status)
+	assertion.NotEmpty(body.Error.Code)
+	assertion.NotEmpty(body.Error.Message)
+}
+
+func Test_GH41_writeResource_refusesAmbiguousDisks(t *testing.T) {
+	assertion := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	writeResource(recorder, nil, fmt.Errorf("%w: 'shared'", storage.ErrDiskAmbiguous))
+
+	var body struct {
+		Error *APIError `json:"error"`
+	}
+
+	assertion.Equal(http.StatusConflict, recorder.Code)
+	assertion.NoError(json.Unmarshal(recorder.Body.Bytes(), &body))
+	assertion.Equal("disk_ambiguous", body.Error.Code)
+}
+
+func Test_GH42_v2Responses_matchSchemas(t *testing.T) {
+	assertion := assert.New(t)
+	spec := loadSpecification(t)
+	router := newTestRouter(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	// the responses of the handlers without any scanned disk
+	for _, path := range []string{"/api/v2/environments", "/api/v2/disks"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assertion.Equal(http.StatusOK, recorder.Code, path)
+		assertion.Empty(spec.validate(spec.responseSchema(t, "get", path, "200"), decode(t, recorder), "$"), path)
+	}
+
+	for _, path := range []string{"/api/v2/disks/unknown", "/api/v2/disks/unknown/directories/db/files/dump/groups/a"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assertion.Equal(http.StatusNotFound, recorder.Code, path)
+		assertion.Empty(spec.validate(&schema{Ref: "#/components/schemas/Error"}, decode(t, recorder), "$"), path)
+	}
+
+	// samples of the resources written by the handlers, with all fields set
+	interpolatedAt := now.Add(-10 * time.Hour)
+	file := storage.FileResource{
+		Name: "dump.sql", Parent: "db", Size: 1024, SortTime: now, BornAt: now, ModifiedAt: now, ArchivedAt: now,
+		InterpolatedAt: &interpolatedAt, Checksum: "d41d8cd98f00b204e9800998ecf8427e", StorageClass: "STANDARD",
+	}
+	group := storage.GroupResource{Name: "a", Health: storage.StatusLate, FileCount: 2, YoungCount: 1, Latest: &file, Files: []storage.FileResource{file, file}}
+	fileDefinition := storage.FileDefinitionResource{
+		Alias: "dump", Pattern: "dump.sql", Schedule: "0 2 * * *", ExpectedAt: now, NextRunAt: now,
+		Retention: storage.RetentionResource{Count: 3, AgeSeconds: 86400, Daily: 7, Weekly: 4, Monthly: 12, Yearly: 1},
+		Purge:     true, PurgeAction: "move", Health: storage.StatusOk, Groups: []storage.GroupResource{group},
+	}
+	directory := storage.DirectoryResource{Alias: "db", Pattern: "{{var}}", Variables: []string{"var"}, Health: storage.StatusOk, Files: []storage.FileDefinitionResource{fileDefinition}}
+	disk := storage.DiskResource{
+		Environment: "default", Name: "backups", Health: storage.StatusMissing, Quota: 1 << 30,
+		LastScan: &history.Scan{
+			Time: now, Environment: "default", Disk: "backups", DurationSeconds: 1.5, Files: 3, Bytes: 3072, Error: "failed",
+			Definitions: []history.DefinitionUsage{{Directory: "db", File: "dump", Files: 3, Bytes: 3072}},
+		},
+		Directories: []storage.DirectoryResource{directory},
+	}
+	unscanned := disk
+	unscanned.LastScan = nil
+	group.Latest = nil
+
+	for path, sample := range map[string]interface{}{
+		"/api/v2/environments":                                               []storage.EnvironmentResource{{Name: "default", Disks: []string{"backups"}}},
+		"/api/v2/disks":                                                      []storage.DiskResource{disk, unscanned},
+		"/api/v2/disks/{disk}":                                               disk,
+		"/api/v2/disks/{disk}/directories/{dir}":                             directory,
+		"/api/v2/disks/{disk}/directories/{dir}/files/{file}":                fileDefinition,
+		"/api/v2/disks/{disk}/directories/{dir}/files/{file}/groups/{group}": group,
+	} {
+		recorder := httptest.NewRecorder()
+		writeData(recorder, sample)
+
+		assertion.Empty(spec.validate(spec.responseSchema(t, "get", path, "200"), decode(t, recorder), "$"), path)
+	}
+
+	// the validation detects drift between the resources and the specification
+	assertion.ElementsMatch([]string{
+		"$.health: \"bogus\" is not one of [ok late unconfirmed failed missing definitions_missing]",
+		"$.directories: 1 is no array",
+		"$.name: is required",
+		"$.undocumented: is not specified",
+	}, spec.validate(&schema{Ref: "#/components/schemas/Disk"}, map[string]interface{}{
+		"environment": "default", "health": "bogus", "directories": float64(1), "undocumented": true, "last_scan": nil,
+	}, "$"))
+}
@@ -1,14 +1,24 @@
 package web
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/dreitier/backmon/auth"
 	"github.com/dreitier/backmon/config"
+	"github.com/dreitier/backmon/events"
+	"github.com/dreitier/backmon/history"
 	"github.com/dreitier/backmon/metrics"
-	"github.com/goji/httpauth"
+	"github.com/dreitier/backmon/report"
+	"github.com/dreitier/backmon/storage"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type RouteConfiguration struct {
@@ -20,44 +30,131 @@ var (
 	once     sync.Once
 )
 
-const HttpMethodGet = "GET"
+const (
+	HttpMethodGet  = "GET"
+	HttpMethodPost = "POST"
+	HttpMethodHead = "HEAD"
+	// #48
+	HttpMethodDelete = "DELETE"
+)
+
+// log excerpts sent by backup jobs are read up to this size, before they are truncated to `heartbeats.max_log_size`
+const maxHeartbeatBodySize = 1024 * 1024
+
+// silences are small JSON objects
+const maxSilenceBodySize = 64 * 1024
 
 func GetInstance() *RouteConfiguration {
 	once.Do(func() {
 		instance = &RouteConfiguration{
-			endpointsRouter: mux.NewRouter().UseEncodedPath(),
+			endpointsRouter: newRouter(config.GetInstance()),
 		}
+	})
+
+	return instance
+}
+
+// newRouter registers all endpoints, depending on the configuration
+func newRouter(cfg *config.Configuration) *mux.Router {
+	router := mux.NewRouter().UseEncodedPath()
 
-		instance.endpointsRouter.StrictSlash(true)
-		instance.endpointsRouter.HandleFunc("/", BaseHandler)
-		instance.endpointsRouter.Handle("/metrics", metrics.Handler())
+	router.StrictSlash(true)
+	router.HandleFunc("/", BaseHandler)
 
-		// #2: for /api, we are using an HTTP Basic Auth middleware
-		apiEndpoint := instance.endpointsRouter.PathPrefix("/api").Subrouter()
+	// #48: users and API tokens with roles, optionally scoped to environments and disks
+	authenticator, err := auth.NewAuthenticator(cfg.Http())
 
-		apiEndpoint.Use(loggingMiddleware)
+	if err != nil {
+		log.Fatalf("Authentication could not be configured: %s", err)
+	}
 
-		if config.GetInstance().Http().BasicAuth != nil {
-			log.Debug("Registering Basic Auth middleware")
+	metricsHandler := metrics.Handler()
 
-			apiEndpoint.Use(httpauth.SimpleBasicAuth(
-				config.GetInstance().Http().BasicAuth.Username,
-				config.GetInstance().Http().BasicAuth.Password,
-			))
+	if cfg.Http().MetricsAuth {
+		if !authenticator.Enabled() {
+			log.Warn("Authentication of /metrics is enabled, but no credentials have been configured")
 		}
 
-		apiEndpoint.HandleFunc("", EnvHandler)
-		apiEndpoint.HandleFunc("/{disk}", DiskInfoHandler).Methods(HttpMethodGet)
-		apiEndpoint.HandleFunc("/{disk}/{dir}", DirectoryInfoHandler).Methods(HttpMethodGet)
-		apiEndpoint.HandleFunc("/{disk}/{dir}/{file}", FileInfoHandler).Methods(HttpMethodGet)
+		// the metrics cannot be filtered by environment or disk
+		metricsHandler = authMiddleware(authenticator)(unscoped(metricsHandler))
+	}
+
+	router.Handle("/metrics", metricsHandler)
+
+	// #38: backup jobs may post their heartbeats with the heartbeat token; must be registered before /api
+	heartbeatsEndpoint := router.PathPrefix("/api/heartbeats").Subrouter()
+	heartbeatsEndpoint.Use(loggingMiddleware)
+	heartbeatsEndpoint.Handle("", authMiddleware(authenticator)(http.HandlerFunc(HeartbeatsHandler))).Methods(HttpMethodGet)
+	heartbeatsEndpoint.Handle("/{disk}/{dir}/{file}/{group}/{kind}", heartbeatAuthMiddleware(cfg, authenticator, false)(require(auth.RoleOperator, HeartbeatHandler))).Methods(HttpMethodGet)
+	heartbeatsEndpoint.Handle("/{disk}/{dir}/{file}/{group}/{kind}", heartbeatAuthMiddleware(cfg, authenticator, true)(require(auth.RoleOperator, HeartbeatHandler))).Methods(HttpMethodPost)
 
-		if config.GetInstance().Downloads().Enabled {
-			log.Debug("Registering GET handler for artifact downloads")
-			apiEndpoint.HandleFunc("/{disk}/{dir}/{file}/{variant}", LatestFileHandler).Methods(HttpMethodGet)
+	// #49: the login of the web UI does not require authentication
+	if authenticator.LoginEnabled() {
+		registerLogin(router, authenticator)
+	}
+
+	// #2: for /api, we are using an HTTP Basic Auth middleware
+	apiEndpoint := router.PathPrefix("/api").Subrouter()
+	// #43: the web UI requests /api/v2 with the same credentials
+	uiEndpoint := router.PathPrefix("/ui/").Subrouter()
+
+	apiEndpoint.Use(loggingMiddleware)
+	uiEndpoint.Use(loggingMiddleware)
+
+	if authenticator.Enabled() {
+		log.Debug("Registering authentication middleware")
+
+		apiEndpoint.Use(authMiddleware(authenticator))
+
+		if authenticator.LoginEnabled() {
+			uiEndpoint.Use(loginMiddleware(authenticator))
 		}
-	})
 
-	return instance
+		uiEndpoint.Use(authMiddleware(authenticator))
+	}
+
+	registerUI(uiEndpoint, cfg, authenticator)
+
+	apiEndpoint.HandleFunc("", EnvHandler)
+	// #42
+	apiEndpoint.HandleFunc("/openapi.json", OpenAPIHandler).Methods(HttpMethodGet)
+	// #41: must be registered before the disk routes
+	registerV2(apiEndpoint.PathPrefix("/v2").Subrouter())
+	// #27: must be registered before the disk routes
+	apiEndpoint.HandleFunc("/audit", AuditHandler).Methods(HttpMethodGet)
+	// #33
+	apiEndpoint.HandleFunc("/history/scans", HistoryScansHandler).Methods(HttpMethodGet)
+	apiEndpoint.HandleFunc("/history/files", HistoryFilesHandler).Methods(HttpMethodGet)
+	// #34
+	apiEndpoint.HandleFunc("/report", ReportHandler).Methods(HttpMethodGet)
+	// #40
+	apiEndpoint.HandleFunc("/events", EventsHandler).Methods(HttpMethodGet)
+	// #39
+	apiEndpoint.HandleFunc("/rescan", require(auth.RoleOperator, RescanHandler)).Methods(HttpMethodPost)
+	apiEndpoint.HandleFunc("/rescan/{env}", require(auth.RoleOperator, RescanHandler)).Methods(HttpMethodPost)
+	apiEndpoint.HandleFunc("/rescan/{env}/{disk}", require(auth.RoleOperator, RescanHandler)).Methods(HttpMethodPost)
+	// #48: operators may silence alerts, admins may pause purging
+	apiEndpoint.HandleFunc("/silences", SilencesHandler).Methods(HttpMethodGet)
+	apiEndpoint.HandleFunc("/silences", require(auth.RoleOperator, CreateSilenceHandler)).Methods(HttpMethodPost)
+	apiEndpoint.HandleFunc("/silences/{id}", require(auth.RoleOperator, DeleteSilenceHandler)).Methods(HttpMethodDelete)
+	apiEndpoint.HandleFunc("/purge", PurgeStatesHandler).Methods(HttpMethodGet)
+	apiEndpoint.HandleFunc("/purge/{env}/{disk}/pause", require(auth.RoleAdmin, PausePurgingHandler(true))).Methods(HttpMethodPost)
+	apiEndpoint.HandleFunc("/purge/{env}/{disk}/resume", require(auth.RoleAdmin, PausePurgingHandler(false))).Methods(HttpMethodPost)
+	apiEndpoint.HandleFunc("/{disk}", DiskInfoHandler).Methods(HttpMethodGet)
+	apiEndpoint.HandleFunc("/{disk}/{dir}", DirectoryInfoHandler).Methods(HttpMethodGet)
+	apiEndpoint.HandleFunc("/{disk}/{dir}/{file}", FileInfoHandler).Methods(HttpMethodGet)
+	// #45
+	apiEndpoint.HandleFunc("/{disk}/{dir}/{file}/{variant}/files", BackupsHandler).Methods(HttpMethodGet)
+
+	if cfg.Downloads().Enabled {
+		log.Debug("Registering GET handler for artifact downloads")
+		apiEndpoint.HandleFunc("/{disk}/{dir}/{file}/{variant}", require(auth.RoleDownloader, LatestFileHandler(cfg.Downloads()))).Methods(HttpMethodGet, HttpMethodHead)
+		apiEndpoint.HandleFunc("/{disk}/{dir}/{file}/{variant}/files/{name}", require(auth.RoleDownloader, BackupFileHandler(cfg.Downloads()))).Methods(HttpMethodGet, HttpMethodHead)
+		// #47
+		registerDownloadLinks(apiEndpoint, router, cfg.Downloads())
+	}
+
+	return router
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -69,21 +166,387 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// BaseHandler Base route to access the API Documentation.
+// heartbeatAuthMiddleware accepts the heartbeat token, either as bearer token or as `token` parameter, and the
+// credentials of the API. If neither is configured, the heartbeat endpoints are not protected, like the rest of the API.
+// Unless `acceptToken` is set, the token is refused; if no credentials are configured either, the route is not accessible.
+func heartbeatAuthMiddleware(cfg *config.Configuration, authenticator *auth.Authenticator, acceptToken bool) mux.MiddlewareFunc {
+	token := cfg.Global().Heartbeats().Token
+
+	return func(next http.Handler) http.Handler {
+		protected := authMiddleware(authenticator)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" {
+				provided := r.URL.Query().Get("token")
+
+				if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+					provided = bearer
+				}
+
+				if acceptToken && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				if !authenticator.Enabled() {
+					w.WriteHeader(http.StatusUnauthorized)
+					_, _ = w.Write([]byte(`Invalid or missing heartbeat token.`))
+					return
+				}
+			}
+
+			protected.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BaseHandler redirects to the web UI; not permanently, as / redirected to /api before #43
 func BaseHandler(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, "/api", http.StatusMovedPermanently)
+	http.Redirect(w, r, "/ui/", http.StatusFound)
+}
+
+func EnvHandler(w http.ResponseWriter, r *http.Request) {
+	GetDisks(w, auth.FromContext(r.Context()))
+}
+
+// AuditHandler returns the purge audit log. It can be filtered by the query parameters `disk`, `dir`, `file`, `group` and
+// `action`; `limit` restricts the amount of returned entries (default: 100).
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, ok := parseLimit(w, query)
+
+	if !ok {
+		return
+	}
+
+	GetAuditLog(w, auth.FromContext(r.Context()), query, limit)
+}
+
+// SilencesHandler returns the silences which have not expired yet
+func SilencesHandler(w http.ResponseWriter, r *http.Request) {
+	GetSilences(w, auth.FromContext(r.Context()))
+}
+
+// CreateSilenceHandler silences the alerts matching the `labels` of the JSON body for its `duration` (e.g. 4h); the
+// `comment` is optional
+func CreateSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Labels   map[string]string `json:"labels"`
+		Duration string            `json:"duration"`
+		Comment  string            `json:"comment"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxSilenceBodySize)).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`Body must be a JSON object with labels, duration and comment.`))
+		return
+	}
+
+	duration, err := time.ParseDuration(body.Duration)
+
+	if err != nil || duration <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`Duration must be a positive duration, e.g. 4h.`))
+		return
+	}
+
+	CreateSilence(w, auth.FromContext(r.Context()), body.Labels, duration, body.Comment)
+}
+
+// DeleteSilenceHandler expires a silence
+func DeleteSilenceHandler(w http.ResponseWriter, r *http.Request) {
+	DeleteSilence(w, auth.FromContext(r.Context()), mux.Vars(r)["id"])
+}
+
+// PurgeStatesHandler returns whether purging of each disk has been paused
+func PurgeStatesHandler(w http.ResponseWriter, r *http.Request) {
+	GetPurgeStates(w, auth.FromContext(r.Context()))
+}
+
+// PausePurgingHandler pauses or resumes purging of a disk
+func PausePurgingHandler(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		unescape(vars)
+
+		PausePurging(w, vars["env"], vars["disk"], paused)
+	}
+}
+
+// HistoryScansHandler returns the recorded scans. They can be filtered by the query parameters `env` and `disk` and by
+// the time range `from` and `to`, either as RFC 3339 timestamps or Unix timestamps; `limit` restricts the amount of
+// returned scans to the latest ones (default: 100).
+func HistoryScansHandler(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseHistoryQuery(w, r.URL.Query())
+
+	if !ok {
+		return
+	}
+
+	GetHistoryScans(w, auth.FromContext(r.Context()), query)
+}
+
+// HistoryFilesHandler returns the observed backup files which existed in the time range `from` to `to`. Additionally to
+// the parameters of HistoryScansHandler, they can be filtered by `dir`, `file` and `group`.
+func HistoryFilesHandler(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseHistoryQuery(w, r.URL.Query())
+
+	if !ok {
+		return
+	}
+
+	GetHistoryFiles(w, auth.FromContext(r.Context()), query)
+}
+
+// ReportHandler returns the SLA report of the last `days` days (default: 30) as `markdown` (default), `html`, `csv` or
+// `json`, depending on the `format` parameter. It can be restricted by `env`, `disk`, `dir` and `file`; with `grace`
+// (e.g. 1h), backups modified later than the grace period after their scheduled run are counted as late.
+func ReportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := report.Options{
+		Days:        30,
+		Environment: query.Get("env"),
+		Disk:        query.Get("disk"),
+		Directory:   query.Get("dir"),
+		File:        query.Get("file"),
+	}
+
+	if query.Has("days") {
+		days, err := strconv.Atoi(query.Get("days"))
+
+		if err != nil || days <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'days' must be a positive number.`))
+			return
+		}
+
+		options.Days = days
+	}
+
+	if query.Has("grace") {
+		grace, err := time.ParseDuration(query.Get("grace"))
+
+		if err != nil || grace < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'grace' must be a positive duration, e.g. 1h30m.`))
+			return
+		}
+
+		options.Grace = grace
+	}
+
+	format := report.FormatMarkdown
+
+	if query.Has("format") {
+		format = query.Get("format")
+
+		if !report.IsFormat(format) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'format' must be one of markdown, html, csv or json.`))
+			return
+		}
+	}
+
+	GetReport(w, auth.FromContext(r.Context()), options, format)
 }
 
-func EnvHandler(w http.ResponseWriter, _ *http.Request) {
-	GetDisks(w)
-	//_, _ = w.Write([]byte(`<h1>Available Disks:</h1>`))
-	//for _, disk := range response {
-	//	_, _ = w.Write([]byte(`<a href="/env/`))
-	//	_, _ = w.Write([]byte(disk.SafeName))
-	//	_, _ = w.Write([]byte(`/">`))
-	//	_, _ = w.Write([]byte(disk.Name))
-	//	_, _ = w.Write([]byte(`</a><br>`))
-	//}
+// RescanHandler triggers a scan of all disks, of the environment `env` or of its disk `disk`. With `wait=true`, the
+// results of the scan are returned after it has finished; otherwise the scan runs in the background.
+func RescanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unescape(vars)
+	wait := false
+
+	if r.URL.Query().Has("wait") {
+		parsed, err := strconv.ParseBool(r.URL.Query().Get("wait"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'wait' must be true or false.`))
+			return
+		}
+
+		wait = parsed
+	}
+
+	// scoped principals may only scan the environments and disks they are restricted to
+	if vars["env"] == "" && auth.FromContext(r.Context()).Scoped() {
+		forbidden(w)
+		return
+	}
+
+	Rescan(w, r, vars["env"], vars["disk"], wait)
+}
+
+// EventsHandler streams the changes of backup files as Server-Sent Events. The events can be filtered by `disk`, `dir`,
+// `file` and `type`, a comma-separated list of event types. Clients reconnecting with the `Last-Event-ID` header
+// receive the recent events they have missed.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := events.Filter{
+		Disk:      query.Get("disk"),
+		Directory: query.Get("dir"),
+		File:      query.Get("file"),
+	}
+
+	for _, types := range query["type"] {
+		for _, t := range strings.Split(types, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	var lastID uint64
+
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		parsed, err := strconv.ParseUint(header, 10, 64)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Header 'Last-Event-ID' must be a positive number.`))
+			return
+		}
+
+		lastID = parsed
+	}
+
+	StreamEvents(w, r, filter, lastID)
+}
+
+// OpenAPIHandler returns the OpenAPI specification of the HTTP API
+func OpenAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(openAPISpecification)
+}
+
+// HeartbeatsHandler returns the latest heartbeats of all backup jobs
+func HeartbeatsHandler(w http.ResponseWriter, r *http.Request) {
+	GetJobs(w, auth.FromContext(r.Context()))
+}
+
+// HeartbeatHandler records the heartbeat `start`, `success` or `fail` of the backup job of a group. The job may report
+// the `duration` of the run (in seconds or e.g. 1h30m) and the `size` of the backup in bytes; the request body is
+// stored as log excerpt.
+func HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unescape(vars)
+	query := r.URL.Query()
+
+	heartbeat := storage.Heartbeat{
+		Kind: vars["kind"],
+		Time: time.Now(),
+	}
+
+	switch heartbeat.Kind {
+	case storage.HeartbeatStart, storage.HeartbeatSuccess, storage.HeartbeatFail:
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`Heartbeat must be one of start, success or fail.`))
+		return
+	}
+
+	if query.Has("duration") {
+		duration, err := parseDuration(query.Get("duration"))
+
+		if err != nil || duration < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'duration' must be a positive number of seconds or a duration, e.g. 1h30m.`))
+			return
+		}
+
+		heartbeat.Duration = duration
+	}
+
+	if query.Has("size") {
+		size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+
+		if err != nil || size < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'size' must be a positive number of bytes.`))
+			return
+		}
+
+		heartbeat.Size = size
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHeartbeatBodySize))
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`Unable to read request body.`))
+		return
+	}
+
+	heartbeat.Log = string(body)
+
+	PostHeartbeat(w, vars["disk"], vars["dir"], vars["file"], vars["group"], heartbeat)
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func parseLimit(w http.ResponseWriter, query url.Values) (limit int, ok bool) {
+	limit = 100
+
+	if query.Has("limit") {
+		parsed, err := strconv.Atoi(query.Get("limit"))
+
+		if err != nil || parsed < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter 'limit' must be a positive number.`))
+			return 0, false
+		}
+
+		limit = parsed
+	}
+
+	return limit, true
+}
+
+func parseHistoryQuery(w http.ResponseWriter, query url.Values) (r history.Query, ok bool) {
+	r.Limit, ok = parseLimit(w, query)
+
+	if !ok {
+		return r, false
+	}
+
+	r.Environment = query.Get("env")
+	r.Disk = query.Get("disk")
+	r.Directory = query.Get("dir")
+	r.File = query.Get("file")
+	r.Group = query.Get("group")
+
+	for param, target := range map[string]*time.Time{"from": &r.From, "to": &r.To} {
+		if !query.Has(param) {
+			continue
+		}
+
+		parsed, err := parseTimestamp(query.Get(param))
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`Parameter '` + param + `' must be an RFC 3339 or a Unix timestamp.`))
+			return r, false
+		}
+
+		*target = parsed
+	}
+
+	return r, true
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
 }
 
 func DiskInfoHandler(w http.ResponseWriter, r *http.Request) {
@@ -141,15 +604,33 @@ func groupNotFound(w http.ResponseWriter, group string) {
 	_, _ = w.Write([]byte(`' does not exist.`))
 }
 
-func LatestFileHandler(w http.ResponseWriter, r *http.Request) {
+func LatestFileHandler(downloads *config.DownloadsConfiguration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		unescape(vars)
+		diskName := vars["disk"]
+		dirName := vars["dir"]
+		fileName := vars["file"]
+		variant := vars["variant"]
+
+		Download(w, r, downloads, diskName, dirName, fileName, variant)
+	}
+}
+
+func BackupsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	unescape(vars)
-	diskName := vars["disk"]
-	dirName := vars["dir"]
-	fileName := vars["file"]
-	variant := vars["variant"]
 
-	Download(w, diskName, dirName, fileName, variant)
+	GetBackups(w, vars["disk"], vars["dir"], vars["file"], vars["variant"])
+}
+
+func BackupFileHandler(downloads *config.DownloadsConfiguration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		unescape(vars)
+
+		DownloadBackup(w, r, downloads, vars["disk"], vars["dir"], vars["file"], vars["variant"], vars["name"])
+	}
 }
 
 func unescape(vars map[string]string) {
@@ -24,21 +24,18 @@ func StartServer() {
 	userDefinedTlsConfiguration := config.GetInstance().Http().Tls
 
 	if userDefinedTlsConfiguration != nil {
-		// `strict: true` sets the TLS configuration to something SSLLabs prefers
-		// @see https://gist.github.com/denji/12b3a568f092ab951456
-		if userDefinedTlsConfiguration.IsStrict {
-			tlsServerConfig = &tls.Config{
-				MinVersion:       tls.VersionTLS12,
-				CurvePreferences: []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-				CipherSuites: []uint16{
-					tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-					tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-				},
-			}
+		var certificates *certificateReloader
+		var err error
+
+		tlsServerConfig, certificates, err = newTLSConfig(userDefinedTlsConfiguration)
+
+		if err != nil {
+			log.Errorf("Unable to configure TLS: %s", err)
+			return
 		}
 
+		certificates.watch(certificateReloadInterval)
+
 		// provide an empty hashmap to disable any other TLS ciphers
 		restrictedProtos := make(map[string]func(*http.Server, *tls.Conn, http.Handler))
 		tlsNextProto = restrictedProtos
@@ -57,7 +54,8 @@ func StartServer() {
 
 	// if the user has provided a TLS configuration, start with TLS
 	if userDefinedTlsConfiguration != nil {
-		log.Error(srv.ListenAndServeTLS(userDefinedTlsConfiguration.CertificatePath, userDefinedTlsConfiguration.PrivateKeyPath))
+		// the certificate is provided by the TLS configuration
+		log.Error(srv.ListenAndServeTLS("", ""))
 	} else {
 		// if no TLS configuration is present, work in unencrypted mode
 		log.Error(srv.ListenAndServe())
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/dreitier/backmon/auth"
+	"github.com/dreitier/backmon/config"
+	"github.com/gorilla/mux"
+)
+
+// The web UI is a static, dependency-free page which renders the resources of /api/v2; see #43.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+type uiConfiguration struct {
+	// whether the latest file of each group can be downloaded through /api/{disk}/{dir}/{file}/{variant}
+	Downloads bool `json:"downloads"`
+	// whether the UI signs in through OIDC, so that it can sign in again once the session expired
+	Login bool `json:"login,omitempty"`
+}
+
+// registerUI registers the web UI below /ui/
+func registerUI(router *mux.Router, cfg *config.Configuration, authenticator *auth.Authenticator) {
+	files, err := fs.Sub(uiFiles, "ui")
+
+	if err != nil {
+		panic(err)
+	}
+
+	router.HandleFunc("/config.json", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, uiConfiguration{
+			// #48: only offered to principals which may download
+			Downloads: cfg.Downloads().Enabled && auth.FromContext(r.Context()).Has(auth.RoleDownloader),
+			Login:     authenticator.LoginEnabled(),
+		})
+	}).Methods(HttpMethodGet)
+	router.PathPrefix("/").Handler(http.StripPrefix("/ui/", http.FileServer(http.FS(files)))).Methods(HttpMethodGet)
+}
@@ -0,0 +1,393 @@
+'use strict';
+
+// Dashboard of backmon, see #43. It only uses the resources of /api/v2; all paths are relative, so that the UI also
+// works behind a reverse proxy with a path prefix.
+
+const API = '../api';
+const REFRESH_INTERVAL = 60 * 1000;
+// group details, i.e. the sizes for the sparklines, are fetched with this many parallel requests
+const SPARKLINE_CONCURRENCY = 4;
+const SPARKLINE_WIDTH = 120;
+const SPARKLINE_HEIGHT = 24;
+
+let settings = {downloads: false};
+// incremented on each render, so that requests of a previous render do not touch the page anymore
+let generation = 0;
+
+async function fetchJSON(path) {
+    const response = await fetch(path, {credentials: 'same-origin', headers: {'Accept': 'application/json'}});
+
+    // #49: the session of the OIDC login has expired
+    if (response.status === 401 && settings.login) {
+        window.location.href = 'login';
+    }
+
+    if (!response.ok) {
+        let message = response.status + ' ' + response.statusText;
+
+        try {
+            const body = await response.json();
+
+            if (body.error && body.error.message) {
+                message = body.error.message;
+            }
+        } catch (e) {
+            // plain text error of the v1 API
+        }
+
+        throw new Error(message);
+    }
+
+    return response.json();
+}
+
+// el creates an element with the attributes and children; null children and attributes are skipped
+function el(tag, attributes, ...children) {
+    const r = document.createElement(tag);
+
+    for (const [name, value] of Object.entries(attributes || {})) {
+        if (value !== undefined && value !== null && value !== false) {
+            r.setAttribute(name, value === true ? '' : value);
+        }
+    }
+
+    for (const child of children.flat()) {
+        if (child !== undefined && child !== null) {
+            r.append(child instanceof Node ? child : String(child));
+        }
+    }
+
+    return r;
+}
+
+function segment(value) {
+    return encodeURIComponent(value);
+}
+
+// parseTime returns null for zero timestamps of Go
+function parseTime(value) {
+    if (!value) {
+        return null;
+    }
+
+    const r = new Date(value);
+
+    return isNaN(r.getTime()) || r.getUTCFullYear() <= 1 ? null : r;
+}
+
+function formatDuration(seconds) {
+    seconds = Math.round(Math.abs(seconds));
+
+    const units = [['d', 86400], ['h', 3600], ['m', 60], ['s', 1]];
+    const parts = [];
+
+    for (const [unit, length] of units) {
+        if (seconds >= length || (parts.length === 0 && unit === 's')) {
+            parts.push(Math.floor(seconds / length) + unit);
+            seconds %= length;
+        }
+
+        if (parts.length === 2) {
+            break;
+        }
+    }
+
+    return parts.join(' ');
+}
+
+function formatAge(time) {
+    if (!time) {
+        return '–';
+    }
+
+    const seconds = (Date.now() - time.getTime()) / 1000;
+
+    return seconds >= 0 ? formatDuration(seconds) + ' ago' : 'in ' + formatDuration(seconds);
+}
+
+function formatTime(time) {
+    return time ? time.toLocaleString() : '–';
+}
+
+function formatBytes(bytes) {
+    if (bytes === undefined || bytes === null) {
+        return '–';
+    }
+
+    const units = ['B', 'KiB', 'MiB', 'GiB', 'TiB', 'PiB'];
+    let i = 0;
+
+    while (bytes >= 1024 && i < units.length - 1) {
+        bytes /= 1024;
+        i++;
+    }
+
+    return (i === 0 ? bytes : bytes.toFixed(1)) + ' ' + units[i];
+}
+
+function healthBadge(health) {
+    return el('span', {class: 'badge health-' + health}, health.replace('_', ' '));
+}
+
+function showError(error) {
+    const box = document.getElementById('error');
+
+    if (error) {
+        box.textContent = error.message || String(error);
+        box.hidden = false;
+    } else {
+        box.hidden = true;
+    }
+}
+
+function setBreadcrumbs(...items) {
+    const nav = document.getElementById('breadcrumbs');
+    nav.replaceChildren();
+
+    for (const [label, href] of items) {
+        nav.append(' / ', href ? el('a', {href: href}, label) : el('span', {}, label));
+    }
+}
+
+// sparkline draws the sizes of the files of a group, oldest first
+function sparkline(files) {
+    const sizes = files.slice().reverse().map(file => file.size);
+    const svg = document.createElementNS('http://www.w3.org/2000/svg', 'svg');
+    svg.setAttribute('class', 'sparkline');
+    svg.setAttribute('viewBox', '0 0 ' + SPARKLINE_WIDTH + ' ' + SPARKLINE_HEIGHT);
+
+    if (sizes.length === 0) {
+        return svg;
+    }
+
+    const min = Math.min(...sizes);
+    const max = Math.max(...sizes);
+    const step = sizes.length > 1 ? (SPARKLINE_WIDTH - 4) / (sizes.length - 1) : 0;
+    const points = sizes.map((size, i) => {
+        const x = 2 + i * step;
+        const y = max === min ? SPARKLINE_HEIGHT / 2 : SPARKLINE_HEIGHT - 2 - (size - min) / (max - min) * (SPARKLINE_HEIGHT - 4);
+
+        return [x.toFixed(1), y.toFixed(1)];
+    });
+
+    const line = document.createElementNS('http://www.w3.org/2000/svg', 'polyline');
+    line.setAttribute('points', points.map(point => point.join(',')).join(' '));
+    svg.append(line);
+
+    const last = document.createElementNS('http://www.w3.org/2000/svg', 'circle');
+    last.setAttribute('cx', points[points.length - 1][0]);
+    last.setAttribute('cy', points[points.length - 1][1]);
+    last.setAttribute('r', '2');
+    svg.append(last);
+
+    const title = document.createElementNS('http://www.w3.org/2000/svg', 'title');
+    title.textContent = sizes.length + ' files, ' + formatBytes(min) + ' to ' + formatBytes(max);
+    svg.append(title);
+
+    return svg;
+}
+
+// loadSparklines fetches the files of each group and replaces the placeholders with sparklines
+async function loadSparklines(tasks, current) {
+    const queue = tasks.slice();
+
+    const worker = async () => {
+        while (queue.length > 0 && current === generation) {
+            const task = queue.shift();
+
+            try {
+                const group = await fetchJSON(task.path);
+
+                if (current === generation) {
+                    task.cell.replaceChildren(sparkline(group.files || []));
+                }
+            } catch (e) {
+                task.cell.replaceChildren(el('span', {class: 'muted', title: e.message}, '–'));
+            }
+        }
+    };
+
+    await Promise.all(Array.from({length: SPARKLINE_CONCURRENCY}, worker));
+}
+
+async function renderOverview(content) {
+    setBreadcrumbs();
+
+    const [environments, disks] = await Promise.all([
+        fetchJSON(API + '/v2/environments'),
+        fetchJSON(API + '/v2/disks'),
+    ]);
+
+    const byName = new Map(disks.map(disk => [disk.environment + '/' + disk.name, disk]));
+    const r = [];
+
+    if (environments.length === 0) {
+        r.push(el('p', {class: 'muted'}, 'No environments are configured.'));
+    }
+
+    for (const environment of environments) {
+        const cards = environment.disks.map(name => {
+            const disk = byName.get(environment.name + '/' + name);
+
+            if (!disk) {
+                return null;
+            }
+
+            const scan = disk.last_scan;
+            const details = [];
+
+            if (!scan) {
+                details.push('not scanned yet');
+            } else {
+                details.push('scanned ' + formatAge(parseTime(scan.time)));
+                details.push(scan.files + ' files, ' + formatBytes(scan.bytes));
+            }
+
+            if (disk.quota > 0) {
+                details.push('quota ' + formatBytes(disk.quota));
+            }
+
+            return el('a', {class: 'card health-' + disk.health, href: '#/disks/' + segment(disk.name)},
+                el('div', {class: 'name'}, disk.name, ' ', healthBadge(disk.health)),
+                el('div', {class: 'details'}, details.join(' · ')),
+                scan && scan.error ? el('div', {class: 'details', style: 'color: var(--failed)'}, scan.error) : null,
+            );
+        });
+
+        r.push(el('h2', {}, environment.name), el('div', {class: 'cards'}, cards));
+    }
+
+    content.replaceChildren(...r);
+}
+
+async function renderDisk(content, diskName) {
+    setBreadcrumbs([diskName, null]);
+
+    const disk = await fetchJSON(API + '/v2/disks/' + segment(diskName));
+    const directories = await Promise.all((disk.directories || []).map(dir =>
+        fetchJSON(API + '/v2/disks/' + segment(diskName) + '/directories/' + segment(dir.alias))
+    ));
+
+    setBreadcrumbs([disk.environment, '#/'], [disk.name, null]);
+
+    const current = generation;
+    const sparklines = [];
+    const r = [
+        el('p', {},
+            healthBadge(disk.health), ' ',
+            disk.last_scan ? 'Last scan ' + formatTime(parseTime(disk.last_scan.time)) : 'Not scanned yet',
+            disk.quota > 0 ? ' · quota ' + formatBytes(disk.quota) : '',
+        ),
+    ];
+
+    if (disk.last_scan && disk.last_scan.error) {
+        r.push(el('div', {class: 'error'}, disk.last_scan.error));
+    }
+
+    if (directories.length === 0) {
+        r.push(el('p', {class: 'muted'}, 'No backup definitions have been found on this disk.'));
+    }
+
+    for (const dir of directories) {
+        const rows = [];
+
+        for (const file of dir.files || []) {
+            const next = parseTime(file.next_run_at);
+
+            rows.push(el('tr', {class: 'file'},
+                el('td', {}, file.alias, ' ', el('span', {class: 'muted'}, file.pattern)),
+                el('td', {}, healthBadge(file.health)),
+                el('td', {colspan: 4}, el('span', {class: 'muted'}, file.schedule ? 'schedule ' + file.schedule : 'no schedule')),
+                el('td', {}, next ? formatTime(next) + ' (' + formatAge(next) + ')' : '–'),
+                settings.downloads ? el('td', {}) : null,
+            ));
+
+            if (!file.groups || file.groups.length === 0) {
+                rows.push(el('tr', {},
+                    el('td', {class: 'group muted', colspan: settings.downloads ? 8 : 7}, 'No backup files have been found.'),
+                ));
+            }
+
+            for (const group of file.groups || []) {
+                const latest = group.latest;
+                const cell = el('td', {}, el('span', {class: 'muted'}, '…'));
+                const download = API + '/' + segment(diskName) + '/' + segment(dir.alias) + '/' + segment(file.alias) + '/' + segment(group.name);
+
+                sparklines.push({
+                    path: API + '/v2/disks/' + segment(diskName) + '/directories/' + segment(dir.alias) + '/files/' + segment(file.alias) + '/groups/' + segment(group.name),
+                    cell: cell,
+                });
+
+                rows.push(el('tr', {},
+                    el('td', {class: 'group'}, group.name || el('span', {class: 'muted'}, '(default)')),
+                    el('td', {}, healthBadge(group.health)),
+                    el('td', {}, latest ? latest.name : '–'),
+                    el('td', {title: latest ? formatTime(parseTime(latest.sort_time)) : null}, latest ? formatAge(parseTime(latest.sort_time)) : '–'),
+                    el('td', {class: 'number'}, latest ? formatBytes(latest.size) : '–', el('div', {class: 'muted'}, group.file_count + ' files')),
+                    cell,
+                    el('td', {class: 'muted'}, 'expected ' + formatTime(parseTime(file.expected_at))),
+                    settings.downloads ? el('td', {},
+                        latest ? el('a', {href: download, download: latest.name}, 'Download') : null,
+                    ) : null,
+                ));
+            }
+        }
+
+        r.push(
+            el('h2', {}, dir.alias, ' ', healthBadge(dir.health)),
+            el('table', {},
+                el('thead', {}, el('tr', {},
+                    el('th', {}, 'File / group'),
+                    el('th', {}, 'Health'),
+                    el('th', {}, 'Latest file'),
+                    el('th', {}, 'Age'),
+                    el('th', {}, 'Size'),
+                    el('th', {}, 'Sizes'),
+                    el('th', {}, 'Next run'),
+                    settings.downloads ? el('th', {}, '') : null,
+                )),
+                el('tbody', {}, rows),
+            ),
+        );
+    }
+
+    content.replaceChildren(...r);
+    loadSparklines(sparklines, current);
+}
+
+async function render() {
+    const current = ++generation;
+    const content = document.getElementById('content');
+    const route = location.hash.replace(/^#\/?/, '').split('/');
+
+    try {
+        if (route[0] === 'disks' && route.length > 1) {
+            await renderDisk(content, decodeURIComponent(route[1]));
+        } else {
+            await renderOverview(content);
+        }
+
+        if (current === generation) {
+            showError(null);
+            document.getElementById('updated').textContent = 'Updated ' + new Date().toLocaleTimeString();
+        }
+    } catch (e) {
+        if (current === generation) {
+            showError(e);
+        }
+    }
+}
+
+async function main() {
+    try {
+        settings = await fetchJSON('config.json');
+    } catch (e) {
+        showError(e);
+    }
+
+    window.addEventListener('hashchange', render);
+    setInterval(render, REFRESH_INTERVAL);
+    await render();
+}
+
+main();
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GH43_BaseHandler_redirectsToUI(t *testing.T) {
+	assertion := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	newTestRouter(t).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assertion.Equal(http.StatusFound, recorder.Code)
+	assertion.Equal("/ui/", recorder.Header().Get("Location"))
+}
+
+func Test_GH43_UI_servesEmbeddedFiles(t *testing.T) {
+	assertion := assert.New(t)
+	router := newTestRouter(t)
+
+	for _, path := range []string{"/ui/", "/ui/app.js", "/ui/style.css"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assertion.Equal(http.StatusOK, recorder.Code, path)
+		assertion.NotEmpty(recorder.Body.String(), path)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+
+	assertion.Equal("text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assertion.Contains(recorder.Body.String(), `<script src="app.js">`)
+}
+
+func Test_GH43_UI_configurationContainsDownloads(t *testing.T) {
+	assertion := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	newTestRouter(t).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ui/config.json", nil))
+
+	assertion.Equal(http.StatusOK, recorder.Code)
+	assertion.JSONEq(`{"downloads": true}`, recorder.Body.String())
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dreitier/backmon/storage"
+	"github.com/gorilla/mux"
+)
+
+// APIError is the body of all non-200 responses of the v2 API, see #41
+type APIError struct {
+	Status int `json:"status"`
+	// machine-readable reason, e.g. `disk_not_found`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// registerV2 registers the structured resources of the v2 API
+func registerV2(router *mux.Router) {
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "The requested resource does not exist.")
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "The method is not allowed for the requested resource.")
+	})
+
+	router.HandleFunc("/environments", V2EnvironmentsHandler).Methods(HttpMethodGet)
+	router.HandleFunc("/disks", V2DisksHandler).Methods(HttpMethodGet)
+	router.HandleFunc("/disks/{disk}", V2DiskHandler).Methods(HttpMethodGet)
+	router.HandleFunc("/disks/{disk}/directories/{dir}", V2DirectoryHandler).Methods(HttpMethodGet)
+	router.HandleFunc("/disks/{disk}/directories/{dir}/files/{file}", V2FileHandler).Methods(HttpMethodGet)
+	router.HandleFunc("/disks/{disk}/directories/{dir}/files/{file}/groups/{group}", V2GroupHandler).Methods(HttpMethodGet)
+}
+
+// V2EnvironmentsHandler returns all environments with the names of their disks
+func V2EnvironmentsHandler(w http.ResponseWriter, r *http.Request) {
+	environments := []storage.EnvironmentResource{}
+
+	// #48: scoped principals only see the disks they may access
+	for _, environment := range storage.DescribeEnvironments() {
+		disks := make([]string, 0, len(environment.Disks))
+
+		for _, disk := range environment.Disks {
+			if allowed(r, environment.Name, disk) {
+				disks = append(disks, disk)
+			}
+		}
+
+		if len(disks) > 0 || allowed(r, environment.Name, "") {
+			environment.Disks = disks
+			environments = append(environments, environment)
+		}
+	}
+
+	writeData(w, environments)
+}
+
+// V2DisksHandler returns all disks with their health and the result of their latest scan
+func V2DisksHandler(w http.ResponseWriter, r *http.Request) {
+	disks := []storage.DiskResource{}
+
+	for _, disk := range storage.DescribeDisks() {
+		if allowed(r, disk.Environment, disk.Name) {
+			disks = append(disks, disk)
+		}
+	}
+
+	writeData(w, disks)
+}
+
+// V2DiskHandler returns a disk with its directories and file definitions
+func V2DiskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unescape(vars)
+
+	disk, err := storage.DescribeDisk(vars["disk"])
+	writeResource(w, disk, err)
+}
+
+// V2DirectoryHandler returns a directory with its file definitions and their groups
+func V2DirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unescape(vars)
+
+	dir, err := storage.DescribeDirectory(vars["disk"], vars["dir"])
+	writeResource(w, dir, err)
+}
+
+// V2FileHandler returns a file definition with its schedule, retention and groups
+func V2FileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unescape(vars)
+
+	file, err := storage.DescribeFileDefinition(vars["disk"], vars["dir"], vars["file"])
+	writeResource(w, file, err)
+}
+
+// V2GroupHandler returns a group with all of its backup files
+func V2GroupHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	unescape(vars)
+
+	group, err := storage.DescribeGroup(vars["disk"], vars["dir"], vars["file"], vars["group"])
+	writeResource(w, group, err)
+}
+
+func writeResource(w http.ResponseWriter, resource interface{}, err error) {
+	if err == nil {
+		writeData(w, resource)
+		return
+	}
+
+	// #41: the disk exists in several environments
+	if errors.Is(err, storage.ErrDiskAmbiguous) {
+		writeError(w, http.StatusConflict, "disk_ambiguous", err.Error())
+		return
+	}
+
+	for code, target := range map[string]error{
+		"disk_not_found":      storage.ErrDiskNotFound,
+		"directory_not_found": storage.ErrDirectoryNotFound,
+		"file_not_found":      storage.ErrFileNotFound,
+		"group_not_found":     storage.ErrGroupNotFound,
+	} {
+		if errors.Is(err, target) {
+			writeError(w, http.StatusNotFound, code, err.Error())
+			return
+		}
+	}
+
+	writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	data, _ := json.Marshal(errorResponse{Error: APIError{Status: status, Code: code, Message: message}})
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}